@@ -1,80 +1,282 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"cosmos/config"
+	"cosmos/internal/auth"
+	"cosmos/internal/buildinfo"
 	"cosmos/internal/handler"
+	"cosmos/internal/logging"
+	"cosmos/internal/mail"
+	"cosmos/internal/metrics"
+	"cosmos/internal/repository"
+	"cosmos/internal/tracing"
+	"cosmos/migrations"
 	"cosmos/pkg/database"
 
 	"github.com/joho/godotenv"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 )
 
 func main() {
-	// Загружаем .env файл
-	if err := godotenv.Load(); err != nil {
-		log.Println("Файл .env не найден")
-	}
+	// Загружаем .env файл; сообщение пишется после настройки логов
+	envErr := godotenv.Load()
 
 	// Загружаем конфигурацию
 	cfg := config.Load()
 
-	// Подключаемся к БД
-	err := database.Connect(cfg)
+	// Логи: LOG_FORMAT=text|json, LOG_LEVEL=debug|info|warn|error
+	logger, err := logging.Setup(os.Stderr, cfg.LogFormat, cfg.LogLevel)
 	if err != nil {
-		log.Fatalf("Ошибка подключения к БД: %v", err)
+		fatal("Ошибка настройки логов", "err", err)
+	}
+	slog.SetDefault(logger)
+	if envErr != nil {
+		slog.Info("Файл .env не найден, настройки берутся из окружения")
+	}
+
+	// cosmos migrate up|down|status - миграции схемы вместо запуска сервера
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(cfg, os.Args[2:]); err != nil {
+			fatal("Ошибка выполнения migrate", "err", err)
+		}
+		return
 	}
-	defer database.Close()
 
-	// Получаем соединение через функцию
-	db := database.GetDB()
+	auth.AccessTokenTTL = cfg.AccessTokenTTL
+	auth.RefreshTokenTTL = cfg.RefreshTokenTTL
+	auth.PasswordHashParams.Memory = uint32(cfg.Argon2Memory)
+	auth.PasswordHashParams.Iterations = uint32(cfg.Argon2Iterations)
+	auth.PasswordHashParams.Parallelism = uint8(min(cfg.Argon2Parallelism, 255))
+
+	// Ключи подписи токенов
+	keyring, err := auth.LoadKeyring(auth.KeyringConfig{
+		Dir:         cfg.JWTKeysDir,
+		ActiveKeyID: cfg.JWTActiveKeyID,
+		Secret:      cfg.JWTSecret,
+	})
+	if err != nil {
+		fatal("Ошибка загрузки ключей JWT", "err", err)
+	}
+	if keyring.Empty() {
+		if cfg.IsProduction() {
+			fatal("APP_ENV=production: задайте ключ подписи через JWT_KEYS_DIR или JWT_SECRET")
+		}
+		if keyring, err = auth.NewDevKeyring(); err != nil {
+			fatal("Ошибка создания ключа JWT", "err", err)
+		}
+		slog.Warn("Ключ JWT не задан, используется временный ключ (только для разработки)", "kid", keyring.Active().ID)
+	}
+	auth.SetKeyring(keyring)
+
+	if err := serve(cfg); err != nil {
+		fatal("Сервер остановлен с ошибкой", "err", err)
+	}
+}
+
+// serve - подключение к БД и работа HTTP сервера до сигнала SIGINT или SIGTERM.
+// Ошибки возвращаются, а не завершают процесс, чтобы отложенное закрытие пула БД
+// выполнялось в любом случае.
+func serve(cfg *config.Config) error {
+	// Трассировка; накопленные спаны отправляются после закрытия БД
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.TracesExporter)
+	if err != nil {
+		return fmt.Errorf("ошибка настройки трассировки: %w", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Warn("Не удалось отправить трассы", "err", err)
+		}
+	}()
+
+	// Подключаемся к БД; пул закрывается после остановки HTTP сервера
+	db, err := database.Open(context.Background(), cfg)
+	if err != nil {
+		return fmt.Errorf("ошибка подключения к БД: %w", err)
+	}
+	defer func() {
+		db.Close()
+		slog.Info("Подключение к базе данных закрыто")
+	}()
+
+	// Ключ шифрования секретов TOTP в БД
+	totpSecrets, err := loadTOTPSecretBox(cfg)
+	if err != nil {
+		return err
+	}
 
 	// Создаем обработчик
-	h := handler.NewHandler(db)
-
-	// Настраиваем маршруты
-	http.HandleFunc("/", h.HomeHandler)
-	http.HandleFunc("/planets", h.PlanetsHandler)
-	http.HandleFunc("/planets/", h.PlanetDetailHandler)
-	http.HandleFunc("/galaxies", h.GalaxiesHandler)
-	http.HandleFunc("/galaxies/", h.GalaxyDetailHandler)
-
-	// Авторизация
-	http.HandleFunc("/admin/login", h.AdminLoginHandler)
-	http.HandleFunc("/admin/logout", h.AdminLogoutHandler)
-
-	// Админ-панель
-	http.HandleFunc("/admin", h.AdminDashboardHandler)
-
-	// Планеты
-	http.HandleFunc("/admin/planets", h.AdminPlanetsHandler)
-	http.HandleFunc("/admin/planets/new", h.AdminNewPlanetHandler)
-	http.HandleFunc("/admin/planets/delete/", h.AdminDeletePlanetHandler)
-	http.HandleFunc("/admin/planets/edit/", h.AdminEditPlanetHandler)
-
-	// Галактики
-	http.HandleFunc("/admin/galaxies", h.AdminGalaxiesHandler)
-	http.HandleFunc("/admin/galaxies/new", h.AdminNewGalaxyHandler)
-	http.HandleFunc("/admin/galaxies/delete/", h.AdminDeleteGalaxyHandler)
-	http.HandleFunc("/admin/galaxies/edit/", h.AdminEditGalaxyHandler)
-
-	// Пользователи
-	http.HandleFunc("/admin/users", h.AdminUsersHandler)
-	http.HandleFunc("/admin/users/new", h.AdminNewUserHandler)
-	http.HandleFunc("/admin/users/delete/", h.AdminDeleteUserHandler)
-	http.HandleFunc("/admin/users/edit/", h.AdminEditUserHandler)
-	http.HandleFunc("/admin/users/view/", h.AdminUserDetailHandler)
-
-	// Статические файлы
-	fs := http.FileServer(http.Dir("static"))
-	http.Handle("/static/", http.StripPrefix("/static/", fs))
+	h := handler.NewHandler(db, totpSecrets)
+
+	// Секреты TOTP, сохраненные до шифрования, шифруются при запуске, но не временным
+	// ключом: иначе после перезапуска их было бы не прочитать. Ошибка не мешает работе:
+	// такие секреты по-прежнему читаются и будут зашифрованы при следующем запуске.
+	if cfg.TOTPEncryptionKey != "" {
+		if n, err := repository.NewPostgresTOTP(db, totpSecrets).EncryptPlaintextSecrets(context.Background()); err != nil {
+			slog.Warn("Не удалось зашифровать секреты TOTP", "err", err)
+		} else if n > 0 {
+			slog.Info("Секреты TOTP зашифрованы", "count", n)
+		}
+	}
+	h.RequestTimeout = cfg.RequestTimeout
+
+	// /readyz проверяет, что схема БД соответствует встроенным миграциям
+	migrator, err := database.NewMigrator(db, migrations.FS)
+	if err != nil {
+		return fmt.Errorf("ошибка чтения миграций: %w", err)
+	}
+	h.Readiness.Add("migrations", migrator.Current)
+
+	// Метрики пула соединений и размера каталога для /metrics
+	metrics.Registry.MustRegister(
+		collectors.NewDBStatsCollector(db, cfg.DBName),
+		metrics.NewCatalogCollector(h.Planets, h.Galaxies),
+	)
+
+	h.LoginPolicy.MaxFailures = cfg.LoginMaxFailures
+	h.LoginPolicy.MaxIPFailures = cfg.LoginMaxIPFailures
+	h.LoginPolicy.Lockout = cfg.LoginLockout
+	h.PasswordPolicy.MinLength = cfg.PasswordMinLength
+	h.PasswordPolicy.ForbidUsername = cfg.PasswordForbidUsername
+	if cfg.PasswordDenylistFile != "" {
+		if err := h.PasswordPolicy.LoadDenylist(cfg.PasswordDenylistFile); err != nil {
+			return fmt.Errorf("ошибка загрузки PASSWORD_DENYLIST_FILE: %w", err)
+		}
+		slog.Info("Загружен список запрещенных паролей", "count", len(h.PasswordPolicy.Denylist))
+	}
+	h.BaseURL = cfg.SiteURL()
+	h.CookieSecure = cfg.CookieSecure
+	h.CookieSameSite = cfg.SameSite()
+	if h.CookieSameSite == http.SameSiteNoneMode && !h.CookieSecure {
+		return errors.New("COOKIE_SAMESITE=none требует COOKIE_SECURE=true: браузеры отбрасывают такие cookie без Secure")
+	}
+	h.Security = handler.SecurityPolicy{
+		CSP:               cfg.SecurityCSP,
+		CSPReportOnly:     cfg.SecurityCSPReportOnly,
+		HSTS:              cfg.SecurityHSTS,
+		FrameOptions:      cfg.SecurityFrameOptions,
+		ReferrerPolicy:    cfg.SecurityReferrerPolicy,
+		PermissionsPolicy: cfg.SecurityPermissionsPolicy,
+	}
+
+	// Отправка писем
+	mailer, err := mail.New(mail.Config{
+		Driver:   cfg.MailDriver,
+		From:     cfg.MailFrom,
+		Host:     cfg.SMTPHost,
+		Port:     cfg.SMTPPort,
+		Username: cfg.SMTPUsername,
+		Password: cfg.SMTPPassword,
+		Dir:      cfg.MailDir,
+	})
+	if err != nil {
+		return fmt.Errorf("ошибка настройки почты: %w", err)
+	}
+	h.Mailer = mailer
+
+	// Вход через OpenID Connect
+	if cfg.OIDCEnabled() {
+		roleMapping, err := auth.ParseRoleMapping(cfg.OIDCRoleMapping)
+		if err != nil {
+			return fmt.Errorf("ошибка настройки OIDC_ROLE_MAPPING: %w", err)
+		}
+		h.OIDC, err = auth.NewOIDCProvider(auth.OIDCConfig{
+			Name:         cfg.OIDCProviderName,
+			IssuerURL:    cfg.OIDCIssuerURL,
+			ClientID:     cfg.OIDCClientID,
+			ClientSecret: cfg.OIDCClientSecret,
+			RedirectURL:  cfg.OIDCCallbackURL(),
+			Scopes:       strings.Fields(cfg.OIDCScopes),
+			GroupsClaim:  cfg.OIDCGroupsClaim,
+			RoleMapping:  roleMapping,
+			DefaultRole:  cfg.OIDCDefaultRole,
+		})
+		if err != nil {
+			return fmt.Errorf("ошибка настройки OIDC: %w", err)
+		}
+		slog.Info("Вход через SSO включен", "issuer", cfg.OIDCIssuerURL)
+	}
 
 	// Запуск сервера
-	log.Printf("Сервер запущен на http://localhost:%s", cfg.AppPort)
-	log.Printf("База данных: %s", cfg.DBName)
+	// Маршруты и middleware собраны в handler.Routes
+	server := &http.Server{
+		Addr:              ":" + cfg.AppPort,
+		Handler:           h.Routes(),
+		ReadTimeout:       cfg.HTTPReadTimeout,
+		ReadHeaderTimeout: cfg.HTTPReadHeaderTimeout,
+		WriteTimeout:      cfg.HTTPWriteTimeout,
+		IdleTimeout:       cfg.HTTPIdleTimeout,
+		MaxHeaderBytes:    cfg.HTTPMaxHeaderBytes,
+		ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		build := buildinfo.Get()
+		slog.Info("Сервер запущен", "url", "http://localhost:"+cfg.AppPort, "db", cfg.DBName,
+			"version", build.Version, "commit", build.Commit)
+		serverErr <- server.ListenAndServe()
+	}()
 
-	if err := http.ListenAndServe(":"+cfg.AppPort, nil); err != nil {
-		log.Fatalf("Ошибка запуска сервера: %v", err)
+	select {
+	case err := <-serverErr:
+		return fmt.Errorf("ошибка запуска сервера: %w", err)
+	case <-ctx.Done():
+	}
+	stop() // повторный Ctrl+C завершит процесс сразу
+
+	// Новые соединения больше не принимаются, текущие запросы дорабатывают
+	// не дольше SHUTDOWN_TIMEOUT; пул БД закрывается после них (defer выше)
+	slog.Info("Получен сигнал остановки, завершаем обработку запросов", "timeout", cfg.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("запросы не завершились за %s: %w", cfg.ShutdownTimeout, err)
+	}
+	if err := h.WaitBackground(shutdownCtx); err != nil {
+		return fmt.Errorf("фоновые задачи не завершились за %s: %w", cfg.ShutdownTimeout, err)
+	}
+
+	slog.Info("Сервер остановлен")
+	return nil
+}
+
+// fatal - запись об ошибке и завершение процесса: аналог log.Fatal для slog
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// loadTOTPSecretBox - шифрование секретов TOTP ключом TOTP_ENCRYPTION_KEY. В production
+// ключ обязателен; при разработке без него берется временный, и настроенная 2FA
+// перестает работать после перезапуска.
+func loadTOTPSecretBox(cfg *config.Config) (*auth.SecretBox, error) {
+	if cfg.TOTPEncryptionKey == "" {
+		if cfg.IsProduction() {
+			return nil, errors.New("APP_ENV=production: задайте TOTP_ENCRYPTION_KEY (openssl rand -base64 32)")
+		}
+		slog.Warn("TOTP_ENCRYPTION_KEY не задан, секреты 2FA шифруются временным ключом (только для разработки)")
+		return auth.NewDevSecretBox()
+	}
+
+	key, err := auth.ParseSecretBoxKey(cfg.TOTPEncryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка настройки TOTP_ENCRYPTION_KEY: %w", err)
 	}
+	return auth.NewSecretBox(key)
 }
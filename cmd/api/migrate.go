@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"cosmos/config"
+	"cosmos/migrations"
+	"cosmos/pkg/database"
+)
+
+const migrateUsage = "использование: cosmos migrate up | down [N] | status"
+
+// errMigrateUsage - неверные аргументы подкоманды migrate
+var errMigrateUsage = errors.New(migrateUsage)
+
+// runMigrate - подкоманда migrate: применение, откат и состояние миграций схемы
+func runMigrate(cfg *config.Config, args []string) error {
+	if len(args) == 0 || (args[0] != "up" && args[0] != "down" && args[0] != "status") {
+		return errMigrateUsage
+	}
+
+	ctx := context.Background()
+	db, err := database.Open(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("ошибка подключения к БД: %w", err)
+	}
+	defer db.Close()
+
+	migrator, err := database.NewMigrator(db, migrations.FS)
+	if err != nil {
+		return fmt.Errorf("ошибка чтения миграций: %w", err)
+	}
+
+	switch args[0] {
+	case "up":
+		count, err := migrator.Up(ctx)
+		if err != nil {
+			return fmt.Errorf("ошибка применения миграций: %w", err)
+		}
+		if count == 0 {
+			slog.Info("Схема БД актуальна, новых миграций нет")
+		} else {
+			slog.Info("Миграции применены", "count", count)
+		}
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return errMigrateUsage
+			}
+		}
+		count, err := migrator.Down(ctx, steps)
+		if err != nil {
+			return fmt.Errorf("ошибка отката миграций: %w", err)
+		}
+		slog.Info("Миграции откачены", "count", count)
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return fmt.Errorf("ошибка получения состояния миграций: %w", err)
+		}
+		printMigrationStatus(statuses)
+
+	default:
+		return errMigrateUsage
+	}
+	return nil
+}
+
+func printMigrationStatus(statuses []database.MigrationStatus) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ВЕРСИЯ\tИМЯ\tСОСТОЯНИЕ\tПРИМЕНЕНА")
+	for _, s := range statuses {
+		state, appliedAt := "ожидает", ""
+		if s.AppliedAt != nil {
+			state, appliedAt = "применена", s.AppliedAt.Format("2006-01-02 15:04:05")
+			if s.Modified {
+				state = "изменена после применения"
+			}
+		}
+		fmt.Fprintf(w, "%03d\t%s\t%s\t%s\n", s.Version, s.Name, state, appliedAt)
+	}
+	w.Flush()
+}
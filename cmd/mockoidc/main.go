@@ -0,0 +1,66 @@
+// mockoidc - локальный OpenID Connect провайдер для разработки и проверки входа через SSO.
+// Вход подтверждается автоматически от имени пользователя из флагов, PKCE (S256)
+// проверяется так же, как у настоящего провайдера (см. internal/auth/oidctest).
+//
+//	go run ./cmd/mockoidc -groups cosmos-admins
+//
+//	OIDC_ISSUER_URL=http://localhost:9999 OIDC_CLIENT_ID=cosmos \
+//	OIDC_ROLE_MAPPING=cosmos-admins=admin go run ./cmd/api
+package main
+
+import (
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+
+	"cosmos/internal/auth/oidctest"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:9999", "адрес сервера")
+	issuer := flag.String("issuer", "", "issuer (по умолчанию http://<addr>)")
+	clientID := flag.String("client-id", "cosmos", "client_id")
+	clientSecret := flag.String("client-secret", "", "client_secret; пусто - публичный клиент")
+	subject := flag.String("sub", "mock-user-1", "subject пользователя")
+	email := flag.String("email", "astronaut@example.com", "email пользователя")
+	emailVerified := flag.Bool("email-verified", true, "подтвержден ли email")
+	username := flag.String("username", "astronaut", "preferred_username")
+	groups := flag.String("groups", "", "группы через запятую")
+	flag.Parse()
+
+	if *issuer == "" {
+		*issuer = "http://" + *addr
+	}
+
+	p, err := oidctest.New(strings.TrimRight(*issuer, "/"), *clientID, *clientSecret, jwt.MapClaims{
+		"sub":                *subject,
+		"email":              *email,
+		"email_verified":     *emailVerified,
+		"preferred_username": *username,
+		"groups":             splitGroups(*groups),
+	})
+	if err != nil {
+		slog.Error("Ошибка создания ключа", "err", err)
+		os.Exit(1)
+	}
+
+	slog.Info("Тестовый OIDC провайдер", "issuer", p.Issuer, "client_id", p.ClientID, "sub", *subject, "groups", p.Claims["groups"])
+	if err := http.ListenAndServe(*addr, p.Handler()); err != nil {
+		slog.Error("Ошибка запуска сервера", "err", err)
+		os.Exit(1)
+	}
+}
+
+func splitGroups(s string) []string {
+	groups := []string{}
+	for _, g := range strings.Split(s, ",") {
+		if g = strings.TrimSpace(g); g != "" {
+			groups = append(groups, g)
+		}
+	}
+	return groups
+}
@@ -1,7 +1,12 @@
 package config
 
 import (
+	"log/slog"
+	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
@@ -11,7 +16,81 @@ type Config struct {
 	DBPassword string
 	DBName     string
 	DBSSLMode  string
+	DBDSN      string // строка подключения целиком (DB_DSN или DATABASE_URL); важнее DB_HOST и т.п.
 	AppPort    string
+	AppEnv     string // development или production
+	BaseURL    string // внешний адрес сайта для ссылок в письмах
+
+	LogLevel  string // debug, info, warn или error
+	LogFormat string // text или json; по умолчанию json в production
+
+	// HTTP сервер
+	HTTPReadTimeout       time.Duration // чтение всего запроса вместе с телом
+	HTTPReadHeaderTimeout time.Duration // чтение заголовков запроса
+	HTTPWriteTimeout      time.Duration // от конца чтения заголовков до конца ответа
+	HTTPIdleTimeout       time.Duration // ожидание следующего запроса в keep-alive соединении
+	HTTPMaxHeaderBytes    int           // максимальный размер заголовков запроса
+	ShutdownTimeout       time.Duration // ожидание текущих запросов при остановке
+
+	TracesExporter string // otlp, stdout или none
+
+	// Пул соединений с БД
+	DBMaxOpenConns    int           // не больше стольких соединений одновременно
+	DBMaxIdleConns    int           // сколько простаивающих соединений держать открытыми
+	DBConnMaxLifetime time.Duration // соединение пересоздается после этого срока
+	DBConnMaxIdleTime time.Duration // простаивающее дольше соединение закрывается
+	DBConnectTimeout  time.Duration // сколько ждать БД при запуске, повторяя попытки
+	DBConnectAttempts int           // не больше стольких попыток подключения при запуске; 0 - пока не истечет DBConnectTimeout
+	RequestTimeout    time.Duration // предельное время обработки запроса, в том числе запросов к БД
+
+	CookieSecure   bool   // cookie только по HTTPS; по умолчанию включено в production
+	CookieSameSite string // lax, strict или none
+
+	// Заголовки безопасности; пустое значение отключает заголовок
+	SecurityCSP               string // {nonce} заменяется на nonce запроса
+	SecurityCSPReportOnly     bool
+	SecurityHSTS              string
+	SecurityFrameOptions      string
+	SecurityReferrerPolicy    string
+	SecurityPermissionsPolicy string
+
+	JWTSecret      string // HMAC секрет (kid "default")
+	JWTKeysDir     string // каталог с ключами <kid>.pem / <kid>.key
+	JWTActiveKeyID string // kid ключа, которым подписываются новые токены
+
+	TOTPEncryptionKey string // ключ AES-256 для секретов TOTP в БД, hex или base64
+
+	AccessTokenTTL  time.Duration // срок жизни JWT токена доступа
+	RefreshTokenTTL time.Duration // срок жизни сессии (refresh токена)
+
+	LoginMaxFailures   int           // неудачных входов по логину до блокировки
+	LoginMaxIPFailures int           // неудачных входов с одного IP до блокировки
+	LoginLockout       time.Duration // длительность блокировки
+
+	PasswordMinLength      int    // минимальная длина нового пароля
+	PasswordForbidUsername bool   // запрет логина в пароле
+	PasswordDenylistFile   string // файл с запрещенными паролями, по одному в строке
+	Argon2Memory           int    // KiB
+	Argon2Iterations       int
+	Argon2Parallelism      int
+
+	MailDriver   string // smtp, file или stdout
+	MailFrom     string
+	MailDir      string // каталог писем для MAIL_DRIVER=file
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+
+	OIDCIssuerURL    string // пусто - вход через OpenID Connect выключен
+	OIDCClientID     string
+	OIDCClientSecret string
+	OIDCRedirectURL  string // по умолчанию SiteURL() + /auth/oidc/callback
+	OIDCScopes       string // через пробел
+	OIDCGroupsClaim  string
+	OIDCRoleMapping  string // группа=роль через запятую, побеждает первое совпадение
+	OIDCDefaultRole  string // роль без подходящей группы; пусто - вход запрещен
+	OIDCProviderName string // название на кнопке входа
 }
 
 func Load() *Config {
@@ -22,8 +101,153 @@ func Load() *Config {
 		DBPassword: getEnv("DB_PASSWORD", "123"),
 		DBName:     getEnv("DB_NAME", "cosmos"),
 		DBSSLMode:  getEnv("DB_SSL_MODE", "disable"),
+		DBDSN:      getEnv("DB_DSN", getEnv("DATABASE_URL", "")),
 		AppPort:    getEnv("APP_PORT", "8080"),
+		AppEnv:     getEnv("APP_ENV", "development"),
+		BaseURL:    getEnv("APP_BASE_URL", ""),
+
+		LogLevel:  strings.ToLower(getEnv("LOG_LEVEL", "info")),
+		LogFormat: strings.ToLower(getEnv("LOG_FORMAT", defaultLogFormat())),
+
+		HTTPReadTimeout:       getEnvDuration("HTTP_READ_TIMEOUT", 15*time.Second),
+		HTTPReadHeaderTimeout: getEnvDuration("HTTP_READ_HEADER_TIMEOUT", 5*time.Second),
+		HTTPWriteTimeout:      getEnvDuration("HTTP_WRITE_TIMEOUT", 30*time.Second),
+		HTTPIdleTimeout:       getEnvDuration("HTTP_IDLE_TIMEOUT", 2*time.Minute),
+		HTTPMaxHeaderBytes:    getEnvInt("HTTP_MAX_HEADER_BYTES", 1<<20),
+		ShutdownTimeout:       getEnvDuration("SHUTDOWN_TIMEOUT", 20*time.Second),
+
+		TracesExporter: strings.ToLower(getEnv("OTEL_TRACES_EXPORTER", "none")),
+
+		DBMaxOpenConns:    getEnvNonNegativeInt("DB_MAX_OPEN_CONNS", 25),
+		DBMaxIdleConns:    getEnvNonNegativeInt("DB_MAX_IDLE_CONNS", 10),
+		DBConnMaxLifetime: getEnvDuration("DB_CONN_MAX_LIFETIME", 30*time.Minute),
+		DBConnMaxIdleTime: getEnvDuration("DB_CONN_MAX_IDLE_TIME", 5*time.Minute),
+		DBConnectTimeout:  getEnvDuration("DB_CONNECT_TIMEOUT", 30*time.Second),
+		DBConnectAttempts: getEnvNonNegativeInt("DB_CONNECT_ATTEMPTS", 0),
+		RequestTimeout:    getEnvDuration("REQUEST_TIMEOUT", 20*time.Second),
+
+		CookieSecure:   getEnvBool("COOKIE_SECURE", getEnv("APP_ENV", "development") == "production"),
+		CookieSameSite: strings.ToLower(getEnv("COOKIE_SAMESITE", "lax")),
+
+		SecurityCSP: getEnv("SECURITY_CSP", "default-src 'self'; script-src 'self' 'nonce-{nonce}'; "+
+			"style-src 'self' 'nonce-{nonce}'; img-src 'self' data:; object-src 'none'; base-uri 'self'; "+
+			"form-action 'self'; frame-ancestors 'none'"),
+		SecurityCSPReportOnly:     getEnvBool("SECURITY_CSP_REPORT_ONLY", false),
+		SecurityHSTS:              getEnv("SECURITY_HSTS", "max-age=31536000; includeSubDomains"),
+		SecurityFrameOptions:      getEnv("SECURITY_FRAME_OPTIONS", "DENY"),
+		SecurityReferrerPolicy:    getEnv("SECURITY_REFERRER_POLICY", "strict-origin-when-cross-origin"),
+		SecurityPermissionsPolicy: getEnv("SECURITY_PERMISSIONS_POLICY", "camera=(), microphone=(), geolocation=(), payment=(), usb=()"),
+
+		JWTSecret:      getEnv("JWT_SECRET", ""),
+		JWTKeysDir:     getEnv("JWT_KEYS_DIR", ""),
+		JWTActiveKeyID: getEnv("JWT_ACTIVE_KID", ""),
+
+		TOTPEncryptionKey: getEnv("TOTP_ENCRYPTION_KEY", ""),
+
+		AccessTokenTTL:  getEnvDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTokenTTL: getEnvDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+
+		LoginMaxFailures:   getEnvInt("LOGIN_MAX_FAILURES", 5),
+		LoginMaxIPFailures: getEnvInt("LOGIN_MAX_IP_FAILURES", 20),
+		LoginLockout:       getEnvDuration("LOGIN_LOCKOUT", 15*time.Minute),
+
+		PasswordMinLength:      getEnvInt("PASSWORD_MIN_LENGTH", 8),
+		PasswordForbidUsername: getEnvBool("PASSWORD_FORBID_USERNAME", true),
+		PasswordDenylistFile:   getEnv("PASSWORD_DENYLIST_FILE", ""),
+		Argon2Memory:           getEnvInt("PASSWORD_ARGON2_MEMORY", 64*1024),
+		Argon2Iterations:       getEnvInt("PASSWORD_ARGON2_ITERATIONS", 3),
+		Argon2Parallelism:      getEnvInt("PASSWORD_ARGON2_PARALLELISM", 2),
+
+		MailDriver:   getEnv("MAIL_DRIVER", "stdout"),
+		MailFrom:     getEnv("MAIL_FROM", "Cosmos Explorer <noreply@localhost>"),
+		MailDir:      getEnv("MAIL_DIR", "mail"),
+		SMTPHost:     getEnv("SMTP_HOST", "localhost"),
+		SMTPPort:     getEnv("SMTP_PORT", "1025"),
+		SMTPUsername: getEnv("SMTP_USERNAME", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+
+		OIDCIssuerURL:    getEnv("OIDC_ISSUER_URL", ""),
+		OIDCClientID:     getEnv("OIDC_CLIENT_ID", ""),
+		OIDCClientSecret: getEnv("OIDC_CLIENT_SECRET", ""),
+		OIDCRedirectURL:  getEnv("OIDC_REDIRECT_URL", ""),
+		OIDCScopes:       getEnv("OIDC_SCOPES", "openid profile email groups"),
+		OIDCGroupsClaim:  getEnv("OIDC_GROUPS_CLAIM", "groups"),
+		OIDCRoleMapping:  getEnv("OIDC_ROLE_MAPPING", ""),
+		OIDCDefaultRole:  getEnv("OIDC_DEFAULT_ROLE", ""),
+		OIDCProviderName: getEnv("OIDC_PROVIDER_NAME", "SSO"),
+	}
+}
+
+// DSN - строка подключения к PostgreSQL: DB_DSN или DATABASE_URL, если заданы,
+// иначе собранная из DB_HOST, DB_PORT и т.п. Значения берутся в кавычки,
+// поэтому пароль может содержать пробелы и кавычки.
+func (c *Config) DSN() string {
+	if c.DBDSN != "" {
+		return c.DBDSN
+	}
+
+	quote := strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+	params := []struct{ key, value string }{
+		{"host", c.DBHost},
+		{"port", c.DBPort},
+		{"user", c.DBUser},
+		{"password", c.DBPassword},
+		{"dbname", c.DBName},
+		{"sslmode", c.DBSSLMode},
+	}
+	parts := make([]string, 0, len(params))
+	for _, p := range params {
+		parts = append(parts, p.key+"='"+quote.Replace(p.value)+"'")
 	}
+	return strings.Join(parts, " ")
+}
+
+// SiteURL - адрес сайта для ссылок в письмах; по умолчанию localhost с портом приложения
+func (c *Config) SiteURL() string {
+	if c.BaseURL != "" {
+		return strings.TrimRight(c.BaseURL, "/")
+	}
+	return "http://localhost:" + c.AppPort
+}
+
+// OIDCEnabled - настроен ли вход через OpenID Connect
+func (c *Config) OIDCEnabled() bool {
+	return c.OIDCIssuerURL != ""
+}
+
+// OIDCCallbackURL - адрес возврата от провайдера, зарегистрированный у клиента
+func (c *Config) OIDCCallbackURL() string {
+	if c.OIDCRedirectURL != "" {
+		return c.OIDCRedirectURL
+	}
+	return c.SiteURL() + "/auth/oidc/callback"
+}
+
+// SameSite - режим SameSite для cookie; некорректное значение заменяется на lax
+func (c *Config) SameSite() http.SameSite {
+	switch c.CookieSameSite {
+	case "lax":
+		return http.SameSiteLaxMode
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	}
+	slog.Warn("Некорректное значение COOKIE_SAMESITE, используется lax", "value", c.CookieSameSite)
+	return http.SameSiteLaxMode
+}
+
+// IsProduction - запуск в боевом режиме, где небезопасные значения по умолчанию запрещены
+func (c *Config) IsProduction() bool {
+	return c.AppEnv == "production"
+}
+
+// defaultLogFormat - JSON для сборщиков логов в production, текст при разработке
+func defaultLogFormat() string {
+	if getEnv("APP_ENV", "development") == "production" {
+		return "json"
+	}
+	return "text"
 }
 
 func getEnv(key, defaultValue string) string {
@@ -32,3 +256,52 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		slog.Warn("Некорректное значение переменной окружения, используется значение по умолчанию", "key", key, "value", value, "default", defaultValue)
+		return defaultValue
+	}
+	return d
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		slog.Warn("Некорректное значение переменной окружения, используется значение по умолчанию", "key", key, "value", value, "default", defaultValue)
+		return defaultValue
+	}
+	return b
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	return getEnvIntMin(key, defaultValue, 1)
+}
+
+// getEnvNonNegativeInt - целое >= 0 для настроек, где 0 имеет смысл
+// (DB_MAX_OPEN_CONNS=0 - без ограничения, DB_MAX_IDLE_CONNS=0 - не держать простаивающие)
+func getEnvNonNegativeInt(key string, defaultValue int) int {
+	return getEnvIntMin(key, defaultValue, 0)
+}
+
+func getEnvIntMin(key string, defaultValue, minValue int) int {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < minValue {
+		slog.Warn("Некорректное значение переменной окружения, используется значение по умолчанию", "key", key, "value", value, "default", defaultValue)
+		return defaultValue
+	}
+	return n
+}
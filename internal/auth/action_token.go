@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Назначения одноразовых токенов из писем
+const (
+	PurposePasswordReset = "password_reset"
+	PurposeVerifyEmail   = "verify_email"
+)
+
+// Сроки действия одноразовых токенов
+const (
+	PasswordResetTTL = time.Hour
+	VerifyEmailTTL   = 48 * time.Hour
+)
+
+// ActionClaims - подписанный токен действия из письма. Одноразовость обеспечивает
+// запись с ID (jti) в таблице user_tokens: подпись и срок проверяются здесь, а
+// использован ли токен - при погашении записи.
+type ActionClaims struct {
+	Email string `json:"email"` // адрес, на который отправлено письмо
+	jwt.RegisteredClaims
+}
+
+// UserID - ID пользователя из subject
+func (c *ActionClaims) UserID() int {
+	id, _ := strconv.Atoi(c.Subject)
+	return id
+}
+
+// GenerateActionToken - токен действия для пользователя; возвращает токен и его jti
+func GenerateActionToken(purpose string, userID int, email string, ttl time.Duration) (token, jti string, err error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	jti = hex.EncodeToString(buf)
+
+	now := time.Now()
+	claims := &ActionClaims{
+		Email: email,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Subject:   strconv.Itoa(userID),
+			Audience:  jwt.ClaimStrings{purpose},
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+
+	token, err = CurrentKeyring().sign(claims)
+	return token, jti, err
+}
+
+// ValidateActionToken - проверка подписи, срока и назначения токена действия
+func ValidateActionToken(tokenString, purpose string) (*ActionClaims, error) {
+	keyring := CurrentKeyring()
+	if keyring == nil {
+		return nil, errNoSigningKey
+	}
+
+	claims := &ActionClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, keyring.keyFunc,
+		jwt.WithValidMethods([]string{AlgHS256, AlgRS256, AlgEdDSA}),
+		jwt.WithAudience(purpose), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.ID == "" || claims.UserID() == 0 {
+		return nil, errors.New("неполный токен действия")
+	}
+	return claims, nil
+}
@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"strings"
+)
+
+// APIKeyPrefix - узнаваемое начало API ключа: по нему ключ отличается от JWT,
+// а утекший ключ проще найти сканерами секретов
+const APIKeyPrefix = "cosmos_"
+
+// apiKeyDisplayLength - сколько первых символов ключа хранится открыто для показа в списке
+const apiKeyDisplayLength = len(APIKeyPrefix) + 6
+
+// Scopes API ключей
+const (
+	ScopeWritePlanets  = "write:planets"
+	ScopeWriteGalaxies = "write:galaxies"
+	ScopeReadUsers     = "read:users"
+	ScopeAdminUsers    = "admin:users"
+)
+
+// Scopes - все scopes, которые можно выдать API ключу
+var Scopes = []string{ScopeWritePlanets, ScopeWriteGalaxies, ScopeReadUsers, ScopeAdminUsers}
+
+// ValidScope - известен ли scope
+func ValidScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// NewAPIKey - новый API ключ, его хэш для БД и открытый префикс для показа
+func NewAPIKey() (key, hash, displayPrefix string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", "", err
+	}
+	key = APIKeyPrefix + base64.RawURLEncoding.EncodeToString(buf)
+	return key, HashToken(key), key[:apiKeyDisplayLength], nil
+}
+
+// IsAPIKey - похожа ли строка на API ключ, а не на JWT
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
+
+// HasScope - разрешено ли действие. Токену сессии разрешено все, что позволяет роль,
+// API ключу - только выданные scopes.
+func (c *Claims) HasScope(scope string) bool {
+	if c.APIKeyID == 0 {
+		return true
+	}
+	for _, s := range c.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// apiKeyFromHeaders - ключ из X-API-Key или Authorization: ApiKey
+func apiKeyFromHeaders(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	if scheme, key, ok := strings.Cut(r.Header.Get("Authorization"), " "); ok && strings.EqualFold(scheme, "ApiKey") {
+		return strings.TrimSpace(key)
+	}
+	return ""
+}
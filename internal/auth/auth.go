@@ -1,72 +1,85 @@
 package auth
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
-	"log"
 	"net/http"
-	"os"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
-	"golang.org/x/crypto/bcrypt"
 )
 
-var jwtSecret = []byte(os.Getenv("JWT_SECRET"))
+// Время жизни токенов; переопределяются из конфигурации при старте
+var (
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 30 * 24 * time.Hour
+)
 
-func init() {
-	if len(jwtSecret) == 0 {
-		jwtSecret = []byte("default_secret_key_change_in_production")
-		log.Println("⚠️  Используется дефолтный JWT_SECRET. Установите JWT_SECRET в .env файле!")
-	}
-}
+// MFATokenTTL - сколько ждать второй фактор после ввода пароля
+const MFATokenTTL = 5 * time.Minute
+
+// mfaAudience - аудитория промежуточного токена: им нельзя войти как токеном доступа
+const mfaAudience = "mfa"
+
+// Издатель и аудитория токена доступа. Остальные токены (MFA, действия, OIDC)
+// подписаны тем же ключом, поэтому ValidateToken требует оба значения:
+// без них такой токен прошел бы проверку как токен доступа.
+const (
+	tokenIssuer    = "cosmos"
+	accessAudience = "access"
+)
 
 // Claims - структура для JWT токена
 type Claims struct {
-	Username string `json:"username"`
-	Role     string `json:"role"`
-	UserID   int    `json:"user_id"`
+	Username  string `json:"username"`
+	Role      string `json:"role"`
+	UserID    int    `json:"user_id"`
+	SessionID int64  `json:"sid"` // запись в таблице sessions, отзыв которой делает токен недействительным
 	jwt.RegisteredClaims
-}
 
-// HashPassword - хэширование пароля
-func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	return string(bytes), err
+	// Заполняются только при входе по API ключу, в JWT не попадают
+	APIKeyID int      `json:"-"`
+	Scopes   []string `json:"-"`
 }
 
-// CheckPassword - проверка пароля
-func CheckPassword(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
-}
-
-// GenerateToken - создание JWT токена
-func GenerateToken(username, role string, userID int) (string, error) {
-	expirationTime := time.Now().Add(24 * time.Hour)
+// GenerateToken - создание короткоживущего JWT токена доступа для сессии
+func GenerateToken(username, role string, userID int, sessionID int64) (string, error) {
+	expirationTime := time.Now().Add(AccessTokenTTL)
 
 	claims := &Claims{
-		Username: username,
-		Role:     role,
-		UserID:   userID,
+		Username:  username,
+		Role:      role,
+		UserID:    userID,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    tokenIssuer,
+			Audience:  jwt.ClaimStrings{accessAudience},
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
+	return CurrentKeyring().sign(claims)
 }
 
 // ValidateToken - проверка JWT токена
 func ValidateToken(tokenString string) (*Claims, error) {
+	keyring := CurrentKeyring()
+	if keyring == nil {
+		return nil, errNoSigningKey
+	}
+
 	claims := &Claims{}
 
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return jwtSecret, nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, claims, keyring.keyFunc,
+		jwt.WithValidMethods([]string{AlgHS256, AlgRS256, AlgEdDSA}),
+		jwt.WithIssuer(tokenIssuer), jwt.WithAudience(accessAudience), jwt.WithExpirationRequired())
 
 	if err != nil {
 		return nil, err
@@ -79,7 +92,38 @@ func ValidateToken(tokenString string) (*Claims, error) {
 	return claims, nil
 }
 
-// GetTokenFromRequest - получение токена из запроса
+// GenerateMFAToken - промежуточный токен после проверки пароля, пока не введен второй фактор
+func GenerateMFAToken(userID int) (string, error) {
+	now := time.Now()
+	claims := &jwt.RegisteredClaims{
+		Subject:   strconv.Itoa(userID),
+		Audience:  jwt.ClaimStrings{mfaAudience},
+		ExpiresAt: jwt.NewNumericDate(now.Add(MFATokenTTL)),
+		IssuedAt:  jwt.NewNumericDate(now),
+	}
+	return CurrentKeyring().sign(claims)
+}
+
+// ValidateMFAToken - ID пользователя из промежуточного токена
+func ValidateMFAToken(tokenString string) (int, error) {
+	keyring := CurrentKeyring()
+	if keyring == nil {
+		return 0, errNoSigningKey
+	}
+
+	claims := &jwt.RegisteredClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, keyring.keyFunc,
+		jwt.WithValidMethods([]string{AlgHS256, AlgRS256, AlgEdDSA}),
+		jwt.WithAudience(mfaAudience), jwt.WithExpirationRequired())
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.Atoi(claims.Subject)
+}
+
+// GetTokenFromRequest - получение токена из запроса: JWT из cookie или
+// Authorization: Bearer, либо API ключ (см. IsAPIKey) из X-API-Key или Authorization: ApiKey
 func GetTokenFromRequest(r *http.Request) string {
 	// Пробуем получить из cookie
 	if cookie, err := r.Cookie("auth_token"); err == nil {
@@ -92,5 +136,22 @@ func GetTokenFromRequest(r *http.Request) string {
 		return authHeader[7:]
 	}
 
-	return ""
+	return apiKeyFromHeaders(r)
+}
+
+// NewRefreshToken - случайный refresh токен и его хэш для хранения в БД
+func NewRefreshToken() (token, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(buf)
+	return token, HashToken(token), nil
+}
+
+// HashToken - SHA-256 хэш случайного токена. В отличие от паролей, у токенов
+// высокая энтропия, поэтому медленный хэш не нужен.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// useTestKeyring - отдельный ключ подписи на время теста
+func useTestKeyring(t *testing.T) *Keyring {
+	t.Helper()
+	kr, err := NewDevKeyring()
+	if err != nil {
+		t.Fatalf("NewDevKeyring: %v", err)
+	}
+	prev := CurrentKeyring()
+	SetKeyring(kr)
+	t.Cleanup(func() { SetKeyring(prev) })
+	return kr
+}
+
+func TestValidateTokenAccessToken(t *testing.T) {
+	useTestKeyring(t)
+
+	token, err := GenerateToken("alice", RoleAdmin, 7, 42)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	claims, err := ValidateToken(token)
+	if err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+	if claims.Username != "alice" || claims.UserID != 7 || claims.SessionID != 42 || claims.Role != RoleAdmin {
+		t.Errorf("claims = %+v", claims)
+	}
+}
+
+// Токены другого назначения подписаны тем же ключом, но входом служить не должны
+func TestValidateTokenRejectsOtherTokens(t *testing.T) {
+	kr := useTestKeyring(t)
+
+	mfa, err := GenerateMFAToken(7)
+	if err != nil {
+		t.Fatalf("GenerateMFAToken: %v", err)
+	}
+	reset, _, err := GenerateActionToken(PurposePasswordReset, 7, "alice@example.com", time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateActionToken: %v", err)
+	}
+
+	now := time.Now()
+	noAudience, err := kr.sign(&Claims{
+		Username: "alice", Role: RoleAdmin, UserID: 7,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    tokenIssuer,
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherIssuer, err := kr.sign(&Claims{
+		Username: "alice", Role: RoleAdmin, UserID: 7,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "someone-else",
+			Audience:  jwt.ClaimStrings{accessAudience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	noExpiry, err := kr.sign(&Claims{
+		Username: "alice", Role: RoleAdmin, UserID: 7,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:   tokenIssuer,
+			Audience: jwt.ClaimStrings{accessAudience},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		"mfa":          mfa,
+		"action":       reset,
+		"no audience":  noAudience,
+		"other issuer": otherIssuer,
+		"no expiry":    noExpiry,
+	}
+	for name, token := range tests {
+		if _, err := ValidateToken(token); err == nil {
+			t.Errorf("%s: ValidateToken принял токен", name)
+		}
+	}
+
+	// И наоборот: токен доступа не подходит как MFA токен
+	access, err := GenerateToken("alice", RoleAdmin, 7, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ValidateMFAToken(access); err == nil {
+		t.Error("ValidateMFAToken принял токен доступа")
+	}
+}
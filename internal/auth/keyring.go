@@ -0,0 +1,333 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Поддерживаемые алгоритмы подписи
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// minHMACSecretLength - минимальная длина HMAC секрета в байтах
+const minHMACSecretLength = 32
+
+// legacySecretKeyID - kid ключа, заданного через JWT_SECRET
+const legacySecretKeyID = "default"
+
+// Key - ключ подписи или проверки токенов
+type Key struct {
+	ID        string
+	Algorithm string
+	signKey   any // nil - ключ только для проверки (выведенный из оборота или чужой публичный)
+	verifyKey any
+}
+
+// CanSign - можно ли подписывать этим ключом
+func (k *Key) CanSign() bool {
+	return k.signKey != nil
+}
+
+func (k *Key) method() jwt.SigningMethod {
+	switch k.Algorithm {
+	case AlgRS256:
+		return jwt.SigningMethodRS256
+	case AlgEdDSA:
+		return jwt.SigningMethodEdDSA
+	default:
+		return jwt.SigningMethodHS256
+	}
+}
+
+// Keyring - набор ключей: одним (активным) подписываются новые токены,
+// остальные принимаются при проверке по заголовку kid, пока не истекут
+// выпущенные ими токены.
+type Keyring struct {
+	keys   map[string]*Key
+	active *Key
+}
+
+// KeyringConfig - источники ключей
+type KeyringConfig struct {
+	// Dir - каталог с ключами: <kid>.pem - RSA или Ed25519 ключ (закрытый ключ
+	// подписывает, PUBLIC KEY только проверяет), <kid>.key - HMAC секрет
+	Dir string
+	// ActiveKeyID - kid ключа для подписи; можно не задавать, если подписывающий ключ один
+	ActiveKeyID string
+	// Secret - HMAC секрет из JWT_SECRET (kid "default")
+	Secret string
+}
+
+// NewKeyring - набор из готовых ключей; activeID пустой - единственный подписывающий ключ
+func NewKeyring(activeID string, keys ...*Key) (*Keyring, error) {
+	kr := &Keyring{keys: map[string]*Key{}}
+	for _, key := range keys {
+		if _, exists := kr.keys[key.ID]; exists {
+			return nil, fmt.Errorf("ключ с kid %q задан дважды", key.ID)
+		}
+		kr.keys[key.ID] = key
+	}
+
+	if activeID != "" {
+		key, ok := kr.keys[activeID]
+		if !ok {
+			return nil, fmt.Errorf("активный ключ %q не найден", activeID)
+		}
+		if !key.CanSign() {
+			return nil, fmt.Errorf("активный ключ %q не содержит закрытой части", activeID)
+		}
+		kr.active = key
+		return kr, nil
+	}
+
+	for _, key := range kr.keys {
+		if !key.CanSign() {
+			continue
+		}
+		if kr.active != nil {
+			return nil, errors.New("несколько ключей подписи: укажите активный через JWT_ACTIVE_KID")
+		}
+		kr.active = key
+	}
+	return kr, nil
+}
+
+// LoadKeyring - чтение ключей из каталога и JWT_SECRET. Пустой набор (Empty) не ошибка:
+// решение, можно ли работать без ключей, принимает вызывающий.
+func LoadKeyring(cfg KeyringConfig) (*Keyring, error) {
+	var keys []*Key
+
+	if cfg.Dir != "" {
+		dirKeys, err := loadKeyDir(cfg.Dir)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, dirKeys...)
+	}
+
+	if cfg.Secret != "" {
+		key, err := NewHMACKey(legacySecretKeyID, []byte(cfg.Secret))
+		if err != nil {
+			return nil, fmt.Errorf("JWT_SECRET: %w", err)
+		}
+		keys = append(keys, key)
+	}
+
+	return NewKeyring(cfg.ActiveKeyID, keys...)
+}
+
+// NewDevKeyring - одноразовый Ed25519 ключ для разработки. Токены доступа
+// перестают действовать после перезапуска, сессии продлеваются по refresh токену.
+func NewDevKeyring() (*Keyring, error) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, err
+	}
+
+	key := &Key{ID: "dev-" + hex.EncodeToString(suffix), Algorithm: AlgEdDSA, signKey: private, verifyKey: private.Public()}
+	return NewKeyring(key.ID, key)
+}
+
+// NewHMACKey - симметричный ключ HS256
+func NewHMACKey(id string, secret []byte) (*Key, error) {
+	if len(secret) < minHMACSecretLength {
+		return nil, fmt.Errorf("HMAC секрет %q короче %d байт", id, minHMACSecretLength)
+	}
+	return &Key{ID: id, Algorithm: AlgHS256, signKey: secret, verifyKey: secret}, nil
+}
+
+// ParsePEMKey - ключ RS256/EdDSA из PEM: закрытый (PKCS#1/PKCS#8) или публичный (PKIX)
+func ParsePEMKey(id string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("ключ %q: PEM блок не найден", id)
+	}
+
+	var parsed any
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("ключ %q: неподдерживаемый PEM блок %q", id, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ключ %q: %w", id, err)
+	}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		return &Key{ID: id, Algorithm: AlgRS256, signKey: k, verifyKey: &k.PublicKey}, nil
+	case *rsa.PublicKey:
+		return &Key{ID: id, Algorithm: AlgRS256, verifyKey: k}, nil
+	case ed25519.PrivateKey:
+		return &Key{ID: id, Algorithm: AlgEdDSA, signKey: k, verifyKey: k.Public()}, nil
+	case ed25519.PublicKey:
+		return &Key{ID: id, Algorithm: AlgEdDSA, verifyKey: k}, nil
+	}
+	return nil, fmt.Errorf("ключ %q: поддерживаются только RSA и Ed25519", id)
+}
+
+func loadKeyDir(dir string) ([]*Key, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("каталог ключей: %w", err)
+	}
+
+	var keys []*Key
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		ext := filepath.Ext(entry.Name())
+		id := strings.TrimSuffix(entry.Name(), ext)
+		if ext != ".pem" && ext != ".key" {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		var key *Key
+		if ext == ".key" {
+			key, err = NewHMACKey(id, []byte(strings.TrimSpace(string(data))))
+		} else {
+			key, err = ParsePEMKey(id, data)
+		}
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// Empty - в наборе нет ключа для подписи
+func (kr *Keyring) Empty() bool {
+	return kr == nil || kr.active == nil
+}
+
+// Active - ключ, которым подписываются новые токены
+func (kr *Keyring) Active() *Key {
+	return kr.active
+}
+
+// sign - подпись claims активным ключом с заголовком kid
+func (kr *Keyring) sign(claims jwt.Claims) (string, error) {
+	if kr.Empty() {
+		return "", errNoSigningKey
+	}
+	token := jwt.NewWithClaims(kr.active.method(), claims)
+	token.Header["kid"] = kr.active.ID
+	return token.SignedString(kr.active.signKey)
+}
+
+// keyFunc - выбор ключа проверки по kid; алгоритм токена должен совпадать с алгоритмом ключа,
+// иначе публичный RSA ключ можно было бы использовать как HMAC секрет
+func (kr *Keyring) keyFunc(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := kr.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("неизвестный kid %q", kid)
+	}
+	if token.Method.Alg() != key.Algorithm {
+		return nil, fmt.Errorf("алгоритм %s не соответствует ключу %q", token.Method.Alg(), kid)
+	}
+	return key.verifyKey, nil
+}
+
+// JWK - публичный ключ в формате RFC 7517
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+}
+
+// JWKSet - документ /.well-known/jwks.json
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS - публичные части асимметричных ключей. HMAC секреты не публикуются.
+func (kr *Keyring) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	if kr == nil {
+		return set
+	}
+
+	for _, key := range kr.keys {
+		jwk := JWK{KeyID: key.ID, Use: "sig", Algorithm: key.Algorithm}
+		switch pub := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].KeyID < set.Keys[j].KeyID })
+	return set
+}
+
+var errNoSigningKey = errors.New("ключи подписи токенов не настроены")
+
+var (
+	keyringMu      sync.RWMutex
+	currentKeyring *Keyring
+)
+
+// SetKeyring - установка набора ключей, которым подписываются и проверяются токены
+func SetKeyring(kr *Keyring) {
+	keyringMu.Lock()
+	defer keyringMu.Unlock()
+	currentKeyring = kr
+}
+
+// CurrentKeyring - текущий набор ключей (nil, если не установлен)
+func CurrentKeyring() *Keyring {
+	keyringMu.RLock()
+	defer keyringMu.RUnlock()
+	return currentKeyring
+}
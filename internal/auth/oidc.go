@@ -0,0 +1,265 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+)
+
+// OIDCFlowTTL - сколько ждать возврата от провайдера после начала входа
+const OIDCFlowTTL = 10 * time.Minute
+
+// oidcFlowAudience - аудитория токена с состоянием входа через OIDC
+const oidcFlowAudience = "oidc_flow"
+
+// OIDCRoleMapping - группа провайдера и соответствующая ей роль cosmos
+type OIDCRoleMapping struct {
+	Group string
+	Role  string
+}
+
+// ParseRoleMapping - разбор "группа=роль,группа=роль". Порядок важен:
+// пользователю достается роль первой подходящей группы.
+func ParseRoleMapping(s string) ([]OIDCRoleMapping, error) {
+	var mappings []OIDCRoleMapping
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		group, role, ok := strings.Cut(pair, "=")
+		group, role = strings.TrimSpace(group), strings.TrimSpace(role)
+		if !ok || group == "" || role == "" {
+			return nil, fmt.Errorf("некорректное соответствие группы и роли %q: ожидается группа=роль", pair)
+		}
+		mappings = append(mappings, OIDCRoleMapping{Group: group, Role: role})
+	}
+	return mappings, nil
+}
+
+// OIDCConfig - настройки входа через OpenID Connect
+type OIDCConfig struct {
+	Name         string // название провайдера на кнопке входа
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string // пусто - публичный клиент, защищенный только PKCE
+	RedirectURL  string
+	Scopes       []string
+	GroupsClaim  string // claim со списком групп, обычно groups
+	RoleMapping  []OIDCRoleMapping
+	DefaultRole  string // роль без подходящей группы; пусто - вход запрещен
+}
+
+// OIDCIdentity - пользователь, подтвержденный провайдером
+type OIDCIdentity struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Username      string
+	Groups        []string
+}
+
+// OIDCProvider - вход по authorization code с PKCE
+type OIDCProvider struct {
+	cfg OIDCConfig
+
+	mu       sync.Mutex
+	provider *oidc.Provider // заполняется при первом обращении (discovery)
+}
+
+// NewOIDCProvider - провайдер по настройкам. Discovery выполняется при первом входе,
+// поэтому недоступный при старте провайдер не мешает запуску сервера.
+func NewOIDCProvider(cfg OIDCConfig) (*OIDCProvider, error) {
+	if cfg.IssuerURL == "" || cfg.ClientID == "" || cfg.RedirectURL == "" {
+		return nil, errors.New("OIDC: нужны адрес провайдера, client_id и redirect URL")
+	}
+	if cfg.GroupsClaim == "" {
+		cfg.GroupsClaim = "groups"
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{oidc.ScopeOpenID, "profile", "email"}
+	}
+	if cfg.Name == "" {
+		cfg.Name = "SSO"
+	}
+	return &OIDCProvider{cfg: cfg}, nil
+}
+
+// Name - название провайдера для интерфейса
+func (p *OIDCProvider) Name() string {
+	return p.cfg.Name
+}
+
+// Issuer - адрес провайдера
+func (p *OIDCProvider) Issuer() string {
+	return p.cfg.IssuerURL
+}
+
+func (p *OIDCProvider) discover(ctx context.Context) (*oidc.Provider, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.provider == nil {
+		provider, err := oidc.NewProvider(ctx, p.cfg.IssuerURL)
+		if err != nil {
+			return nil, fmt.Errorf("OIDC discovery %s: %w", p.cfg.IssuerURL, err)
+		}
+		p.provider = provider
+	}
+	return p.provider, nil
+}
+
+func (p *OIDCProvider) oauth2Config(provider *oidc.Provider) *oauth2.Config {
+	endpoint := provider.Endpoint()
+	if p.cfg.ClientSecret == "" {
+		// Публичный клиент передает client_id в теле запроса, без Basic авторизации
+		endpoint.AuthStyle = oauth2.AuthStyleInParams
+	}
+	return &oauth2.Config{
+		ClientID:     p.cfg.ClientID,
+		ClientSecret: p.cfg.ClientSecret,
+		RedirectURL:  p.cfg.RedirectURL,
+		Endpoint:     endpoint,
+		Scopes:       p.cfg.Scopes,
+	}
+}
+
+// OIDCFlow - состояние входа между переходом к провайдеру и возвратом
+type OIDCFlow struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"` // PKCE code_verifier
+	Next     string `json:"next"`
+	jwt.RegisteredClaims
+}
+
+// Begin - адрес авторизации у провайдера и подписанное состояние для cookie
+func (p *OIDCProvider) Begin(ctx context.Context, next string) (authURL, flowToken string, err error) {
+	provider, err := p.discover(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	flow := &OIDCFlow{
+		State:    randomString(),
+		Nonce:    randomString(),
+		Verifier: oauth2.GenerateVerifier(),
+		Next:     next,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{oidcFlowAudience},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(OIDCFlowTTL)),
+		},
+	}
+
+	flowToken, err = CurrentKeyring().sign(flow)
+	if err != nil {
+		return "", "", err
+	}
+
+	authURL = p.oauth2Config(provider).AuthCodeURL(flow.State,
+		oauth2.S256ChallengeOption(flow.Verifier), oidc.Nonce(flow.Nonce))
+	return authURL, flowToken, nil
+}
+
+// ParseFlow - состояние входа из cookie
+func ParseFlow(flowToken string) (*OIDCFlow, error) {
+	keyring := CurrentKeyring()
+	if keyring == nil {
+		return nil, errNoSigningKey
+	}
+
+	flow := &OIDCFlow{}
+	_, err := jwt.ParseWithClaims(flowToken, flow, keyring.keyFunc,
+		jwt.WithValidMethods([]string{AlgHS256, AlgRS256, AlgEdDSA}),
+		jwt.WithAudience(oidcFlowAudience), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+	return flow, nil
+}
+
+// Exchange - обмен кода на токены и проверка ID токена (подпись, аудитория, nonce)
+func (p *OIDCProvider) Exchange(ctx context.Context, flow *OIDCFlow, code string) (*OIDCIdentity, error) {
+	provider, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := p.oauth2Config(provider).Exchange(ctx, code, oauth2.VerifierOption(flow.Verifier))
+	if err != nil {
+		return nil, fmt.Errorf("обмен кода: %w", err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.New("провайдер не вернул id_token")
+	}
+
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: p.cfg.ClientID}).Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("проверка id_token: %w", err)
+	}
+	if idToken.Nonce != flow.Nonce {
+		return nil, errors.New("nonce id_token не совпадает")
+	}
+
+	var claims map[string]any
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, err
+	}
+
+	identity := &OIDCIdentity{
+		Issuer:  idToken.Issuer,
+		Subject: idToken.Subject,
+		Groups:  stringsClaim(claims[p.cfg.GroupsClaim]),
+	}
+	identity.Email, _ = claims["email"].(string)
+	identity.EmailVerified, _ = claims["email_verified"].(bool)
+	identity.Username, _ = claims["preferred_username"].(string)
+	return identity, nil
+}
+
+// RoleFor - роль по группам пользователя; false - доступ не предусмотрен
+func (p *OIDCProvider) RoleFor(groups []string) (string, bool) {
+	for _, m := range p.cfg.RoleMapping {
+		for _, g := range groups {
+			if g == m.Group {
+				return m.Role, true
+			}
+		}
+	}
+	return p.cfg.DefaultRole, p.cfg.DefaultRole != ""
+}
+
+// stringsClaim - claim со списком строк; некоторые провайдеры отдают одну группу строкой
+func stringsClaim(v any) []string {
+	switch value := v.(type) {
+	case string:
+		return []string{value}
+	case []any:
+		var out []string
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func randomString() string {
+	buf := make([]byte, 24)
+	rand.Read(buf)
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
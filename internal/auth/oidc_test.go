@@ -0,0 +1,173 @@
+package auth_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"cosmos/internal/auth"
+	"cosmos/internal/auth/oidctest"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+)
+
+const testRedirectURL = "http://cosmos.test/auth/oidc/callback"
+
+// newOIDC - тестовый провайдер на httptest и клиент cosmos для него
+func newOIDC(t *testing.T) (*auth.OIDCProvider, *oidctest.Provider) {
+	t.Helper()
+
+	kr, err := auth.NewDevKeyring()
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := auth.CurrentKeyring()
+	auth.SetKeyring(kr)
+	t.Cleanup(func() { auth.SetKeyring(prev) })
+
+	idp, err := oidctest.New("", "cosmos", "", jwt.MapClaims{
+		"sub":                "user-1",
+		"email":              "astronaut@example.com",
+		"email_verified":     true,
+		"preferred_username": "astronaut",
+		"groups":             []string{"cosmos-admins"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(idp.Handler())
+	t.Cleanup(srv.Close)
+	idp.Issuer = srv.URL
+
+	p, err := auth.NewOIDCProvider(auth.OIDCConfig{
+		IssuerURL:   srv.URL,
+		ClientID:    "cosmos",
+		RedirectURL: testRedirectURL,
+		RoleMapping: []auth.OIDCRoleMapping{{Group: "cosmos-admins", Role: auth.RoleAdmin}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p, idp
+}
+
+// authorize - переход по адресу авторизации; возвращает параметры возврата на redirect URL
+func authorize(t *testing.T, authURL string) url.Values {
+	t.Helper()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize: статус %d", resp.StatusCode)
+	}
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := location.Scheme + "://" + location.Host + location.Path; got != testRedirectURL {
+		t.Fatalf("возврат на %q, want %q", got, testRedirectURL)
+	}
+	return location.Query()
+}
+
+// begin - начало входа: адрес авторизации, состояние из cookie и ответ провайдера
+func begin(t *testing.T, p *auth.OIDCProvider) (*auth.OIDCFlow, url.Values, url.Values) {
+	t.Helper()
+	authURL, flowToken, err := p.Begin(context.Background(), "/planets")
+	if err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	flow, err := auth.ParseFlow(flowToken)
+	if err != nil {
+		t.Fatalf("ParseFlow: %v", err)
+	}
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return flow, u.Query(), authorize(t, authURL)
+}
+
+func TestOIDCLoginCallback(t *testing.T) {
+	p, _ := newOIDC(t)
+	flow, request, callback := begin(t, p)
+
+	if request.Get("code_challenge_method") != "S256" || request.Get("code_challenge") == "" {
+		t.Errorf("нет PKCE в запросе авторизации: %v", request)
+	}
+	if request.Get("code_challenge") == flow.Verifier {
+		t.Error("code_verifier передан провайдеру открыто")
+	}
+	if request.Get("nonce") != flow.Nonce || flow.Nonce == "" {
+		t.Errorf("nonce = %q, в состоянии %q", request.Get("nonce"), flow.Nonce)
+	}
+	if callback.Get("state") != flow.State || flow.Next != "/planets" {
+		t.Errorf("state = %q, в состоянии %q (next %q)", callback.Get("state"), flow.State, flow.Next)
+	}
+
+	identity, err := p.Exchange(context.Background(), flow, callback.Get("code"))
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if identity.Subject != "user-1" || identity.Email != "astronaut@example.com" || !identity.EmailVerified || identity.Username != "astronaut" {
+		t.Errorf("identity = %+v", identity)
+	}
+	if role, ok := p.RoleFor(identity.Groups); !ok || role != auth.RoleAdmin {
+		t.Errorf("RoleFor(%v) = %q, %v", identity.Groups, role, ok)
+	}
+
+	// Код одноразовый
+	if _, err := p.Exchange(context.Background(), flow, callback.Get("code")); err == nil {
+		t.Error("повторный обмен того же кода прошел")
+	}
+}
+
+func TestOIDCExchangeWrongVerifier(t *testing.T) {
+	p, _ := newOIDC(t)
+	flow, _, callback := begin(t, p)
+
+	// Перехваченный код без code_verifier из cookie пользователя бесполезен
+	flow.Verifier = oauth2.GenerateVerifier()
+	if _, err := p.Exchange(context.Background(), flow, callback.Get("code")); err == nil {
+		t.Fatal("обмен с чужим code_verifier прошел")
+	}
+}
+
+func TestOIDCExchangeNonceMismatch(t *testing.T) {
+	p, _ := newOIDC(t)
+	flow, _, callback := begin(t, p)
+
+	// id_token выпущен для другого входа (nonce из чужого состояния)
+	flow.Nonce = "other-nonce"
+	_, err := p.Exchange(context.Background(), flow, callback.Get("code"))
+	if err == nil || !strings.Contains(err.Error(), "nonce") {
+		t.Fatalf("Exchange с чужим nonce: err = %v", err)
+	}
+}
+
+func TestParseFlowRejectsForeignTokens(t *testing.T) {
+	newOIDC(t)
+
+	access, err := auth.GenerateToken("alice", auth.RoleAdmin, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mfa, err := auth.GenerateMFAToken(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, token := range map[string]string{"access": access, "mfa": mfa, "мусор": "not-a-token"} {
+		if _, err := auth.ParseFlow(token); err == nil {
+			t.Errorf("%s: ParseFlow принял токен", name)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+// Package oidctest - OpenID Connect провайдер в памяти для разработки и тестов входа
+// через SSO. Вход подтверждается автоматически от имени пользователя с заданными
+// claims, PKCE (S256) проверяется так же, как у настоящего провайдера.
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"cosmos/internal/auth"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const keyID = "mockoidc"
+
+// codeTTL - срок жизни кода авторизации
+const codeTTL = time.Minute
+
+// authRequest - выданный код авторизации и параметры запроса, к которому он относится
+type authRequest struct {
+	ClientID      string
+	RedirectURI   string
+	CodeChallenge string
+	Nonce         string
+	ExpiresAt     time.Time
+}
+
+// Provider - тестовый провайдер. Issuer должен совпадать с адресом, по которому
+// провайдер доступен; при запуске через httptest его задают после старта сервера.
+type Provider struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string        // пусто - публичный клиент
+	Claims       jwt.MapClaims // данные пользователя для id_token (sub, email, groups...)
+
+	key *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]authRequest
+}
+
+// New - провайдер с новым ключом подписи RS256
+func New(issuer, clientID, clientSecret string, claims jwt.MapClaims) (*Provider, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	return &Provider{
+		Issuer:       issuer,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Claims:       claims,
+		key:          key,
+		codes:        map[string]authRequest{},
+	}, nil
+}
+
+// Handler - discovery, JWKS, authorize и token эндпоинты
+func (p *Provider) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", p.discoveryHandler)
+	mux.HandleFunc("GET /jwks", p.jwksHandler)
+	mux.HandleFunc("GET /authorize", p.authorizeHandler)
+	mux.HandleFunc("POST /token", p.tokenHandler)
+	return mux
+}
+
+func (p *Provider) discoveryHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                p.Issuer,
+		"authorization_endpoint":                p.Issuer + "/authorize",
+		"token_endpoint":                        p.Issuer + "/token",
+		"jwks_uri":                              p.Issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"scopes_supported":                      []string{"openid", "profile", "email", "groups"},
+	})
+}
+
+func (p *Provider) jwksHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, auth.JWKSet{Keys: []auth.JWK{{
+		KeyType:   "RSA",
+		KeyID:     keyID,
+		Use:       "sig",
+		Algorithm: auth.AlgRS256,
+		N:         base64.RawURLEncoding.EncodeToString(p.key.N.Bytes()),
+		E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(p.key.E)).Bytes()),
+	}}})
+}
+
+// authorizeHandler - вход подтверждается сразу, клиент получает код на redirect_uri
+func (p *Provider) authorizeHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	redirectURI, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || redirectURI.Scheme == "" {
+		http.Error(w, "некорректный redirect_uri", http.StatusBadRequest)
+		return
+	}
+	if q.Get("client_id") != p.ClientID {
+		http.Error(w, "неизвестный client_id", http.StatusBadRequest)
+		return
+	}
+
+	params := redirectURI.Query()
+	params.Set("state", q.Get("state"))
+
+	switch {
+	case q.Get("response_type") != "code":
+		params.Set("error", "unsupported_response_type")
+	case q.Get("code_challenge") == "" || q.Get("code_challenge_method") != "S256":
+		params.Set("error", "invalid_request")
+		params.Set("error_description", "нужен PKCE с методом S256")
+	default:
+		code := randomString()
+		p.mu.Lock()
+		p.codes[code] = authRequest{
+			ClientID:      p.ClientID,
+			RedirectURI:   q.Get("redirect_uri"),
+			CodeChallenge: q.Get("code_challenge"),
+			Nonce:         q.Get("nonce"),
+			ExpiresAt:     time.Now().Add(codeTTL),
+		}
+		p.mu.Unlock()
+		params.Set("code", code)
+	}
+
+	redirectURI.RawQuery = params.Encode()
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+// tokenHandler - обмен кода на id_token с проверкой code_verifier
+func (p *Provider) tokenHandler(w http.ResponseWriter, r *http.Request) {
+	if r.FormValue("grant_type") != "authorization_code" {
+		tokenError(w, "unsupported_grant_type", "")
+		return
+	}
+
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID, clientSecret = r.FormValue("client_id"), r.FormValue("client_secret")
+	}
+	if clientID != p.ClientID || clientSecret != p.ClientSecret {
+		w.Header().Set("WWW-Authenticate", `Basic realm="mockoidc"`)
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	// Код одноразовый: удаляется при первом предъявлении
+	p.mu.Lock()
+	req, found := p.codes[r.FormValue("code")]
+	delete(p.codes, r.FormValue("code"))
+	p.mu.Unlock()
+
+	switch {
+	case !found || time.Now().After(req.ExpiresAt):
+		tokenError(w, "invalid_grant", "код неизвестен или истек")
+		return
+	case r.FormValue("redirect_uri") != req.RedirectURI:
+		tokenError(w, "invalid_grant", "redirect_uri не совпадает")
+		return
+	case s256(r.FormValue("code_verifier")) != req.CodeChallenge:
+		tokenError(w, "invalid_grant", "code_verifier не соответствует code_challenge")
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss": p.Issuer,
+		"aud": req.ClientID,
+		"iat": now.Unix(),
+		"exp": now.Add(time.Hour).Unix(),
+	}
+	for k, v := range p.Claims {
+		claims[k] = v
+	}
+	if req.Nonce != "" {
+		claims["nonce"] = req.Nonce
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = keyID
+	idToken, err := token.SignedString(p.key)
+	if err != nil {
+		slog.Error("Ошибка подписи id_token", "err", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	slog.Debug("Выдан id_token", "sub", p.Claims["sub"])
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+func tokenError(w http.ResponseWriter, code, description string) {
+	body := map[string]string{"error": code}
+	if description != "" {
+		body["error_description"] = description
+	}
+	writeJSON(w, http.StatusBadRequest, body)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func s256(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func randomString() string {
+	buf := make([]byte, 24)
+	rand.Read(buf)
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Argon2Params - параметры argon2id для новых хэшей паролей
+type Argon2Params struct {
+	Memory      uint32 // KiB
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2Params - 64 MiB, 3 прохода, 2 потока (выше минимума рекомендаций OWASP)
+func DefaultArgon2Params() Argon2Params {
+	return Argon2Params{
+		Memory:      64 * 1024,
+		Iterations:  3,
+		Parallelism: 2,
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+}
+
+// PasswordHashParams - параметры новых хэшей; переопределяются из конфигурации при старте
+var PasswordHashParams = DefaultArgon2Params()
+
+// argon2idPrefix - начало хэша argon2id в формате PHC:
+// $argon2id$v=19$m=65536,t=3,p=2$<соль>$<хэш> (base64 без дополнения)
+const argon2idPrefix = "$argon2id$"
+
+var errInvalidHash = errors.New("некорректный формат хэша argon2id")
+
+// HashPassword - хэширование пароля argon2id
+func HashPassword(password string) (string, error) {
+	p := PasswordHashParams
+
+	salt := make([]byte, p.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2idPrefix, argon2.Version,
+		p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// CheckPassword - проверка пароля по хэшу argon2id или bcrypt (хэши, созданные до
+// перехода на argon2id). С любым другим значением, в том числе хэшем-заглушкой
+// пользователей SSO, пароль не подходит.
+func CheckPassword(password, hash string) bool {
+	switch {
+	case strings.HasPrefix(hash, argon2idPrefix):
+		p, salt, key, err := decodeArgon2Hash(hash)
+		if err != nil {
+			return false
+		}
+		computed := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, uint32(len(key)))
+		return subtle.ConstantTimeCompare(computed, key) == 1
+
+	case isBcryptHash(hash):
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+	}
+	return false
+}
+
+// NeedsRehash - хэш создан bcrypt или с параметрами argon2id, отличными от текущих,
+// и после успешного входа его стоит пересчитать
+func NeedsRehash(hash string) bool {
+	if isBcryptHash(hash) {
+		return true
+	}
+	if !strings.HasPrefix(hash, argon2idPrefix) {
+		return false // не пароль (например, пользователь SSO)
+	}
+
+	p, salt, key, err := decodeArgon2Hash(hash)
+	if err != nil {
+		return true
+	}
+	current := PasswordHashParams
+	return p.Memory != current.Memory || p.Iterations != current.Iterations || p.Parallelism != current.Parallelism ||
+		uint32(len(salt)) != current.SaltLength || uint32(len(key)) != current.KeyLength
+}
+
+func isBcryptHash(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+// decodeArgon2Hash - разбор хэша argon2id в формате PHC
+func decodeArgon2Hash(hash string) (p Argon2Params, salt, key []byte, err error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return p, nil, nil, errInvalidHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, errInvalidHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return p, nil, nil, errInvalidHash
+	}
+	if p.Memory == 0 || p.Iterations == 0 || p.Parallelism == 0 {
+		return p, nil, nil, errInvalidHash
+	}
+
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return p, nil, nil, errInvalidHash
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(key) == 0 {
+		return p, nil, nil, errInvalidHash
+	}
+	p.SaltLength, p.KeyLength = uint32(len(salt)), uint32(len(key))
+	return p, salt, key, nil
+}
@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// PasswordPolicy - требования к новым паролям. Действующие пароли при входе
+// не проверяются: требования применяются при регистрации и смене пароля.
+type PasswordPolicy struct {
+	MinLength      int                 // минимум символов (не байт)
+	MaxLength      int                 // максимум символов, защита от очень дорогого хэширования
+	ForbidUsername bool                // пароль не должен содержать логин
+	Denylist       map[string]struct{} // утекшие и распространенные пароли, в нижнем регистре
+}
+
+// DefaultPasswordPolicy - от 8 до 128 символов, без логина; список запрещенных паролей
+// подключается через LoadDenylist
+func DefaultPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{
+		MinLength:      8,
+		MaxLength:      128,
+		ForbidUsername: true,
+	}
+}
+
+// minUsernameInPassword - более короткие логины встречаются в паролях случайно
+const minUsernameInPassword = 3
+
+// Validate - проверка нового пароля пользователя username
+func (p PasswordPolicy) Validate(username, password string) error {
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		return fmt.Errorf("Пароль должен быть не менее %d символов", p.MinLength)
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		return fmt.Errorf("Пароль должен быть не длиннее %d символов", p.MaxLength)
+	}
+
+	lower := strings.ToLower(password)
+	if p.ForbidUsername && utf8.RuneCountInString(username) >= minUsernameInPassword &&
+		strings.Contains(lower, strings.ToLower(username)) {
+		return errors.New("Пароль не должен содержать логин")
+	}
+	if _, found := p.Denylist[lower]; found {
+		return errors.New("Этот пароль есть в списках утекших паролей, выберите другой")
+	}
+	return nil
+}
+
+// LoadDenylist - загрузка списка запрещенных паролей: по одному в строке,
+// пустые строки и строки с # пропускаются
+func (p *PasswordPolicy) LoadDenylist(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	denylist := map[string]struct{}{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		denylist[strings.ToLower(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("чтение %s: %w", path, err)
+	}
+
+	p.Denylist = denylist
+	return nil
+}
@@ -0,0 +1,21 @@
+package auth
+
+// Роли, на которые опирается код. Остальные роли и их права хранятся в БД
+// (таблицы roles, permissions, role_permissions).
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user" // роль при самостоятельной регистрации
+)
+
+// Права доступа
+const (
+	PermAdminAccess    = "admin.access"
+	PermPlanetsWrite   = "planets.write"
+	PermPlanetsDelete  = "planets.delete"
+	PermGalaxiesWrite  = "galaxies.write"
+	PermGalaxiesDelete = "galaxies.delete"
+	PermUsersView      = "users.view"
+	PermUsersManage    = "users.manage"
+	PermSessionsManage = "sessions.manage"
+	PermAPIKeysManage  = "api_keys.manage"
+)
@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// SecretBoxKeySize - длина ключа SecretBox в байтах (AES-256)
+const SecretBoxKeySize = 32
+
+// sealedPrefix - начало зашифрованного значения; по нему оно отличается от
+// сохраненного до шифрования открытого текста (в base32 секретах нет ":")
+const sealedPrefix = "enc:v1:"
+
+var errSecretBoxOpen = errors.New("не удалось расшифровать секрет: неверный ключ или поврежденные данные")
+
+// SecretBox - шифрование секретов для хранения в БД (AES-256-GCM). Шифротекст
+// привязан к associated (например, к id пользователя): перенесенный в чужую
+// запись, он не расшифруется.
+type SecretBox struct {
+	aead cipher.AEAD
+}
+
+// NewSecretBox - шифрование ключом из SecretBoxKeySize байт
+func NewSecretBox(key []byte) (*SecretBox, error) {
+	if len(key) != SecretBoxKeySize {
+		return nil, fmt.Errorf("ключ шифрования должен быть длиной %d байт, получено %d", SecretBoxKeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &SecretBox{aead: aead}, nil
+}
+
+// ParseSecretBoxKey - ключ из настройки: 32 байта в hex (64 символа) или base64
+func ParseSecretBoxKey(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if len(s) == hex.EncodedLen(SecretBoxKeySize) {
+		if key, err := hex.DecodeString(s); err == nil {
+			return key, nil
+		}
+	}
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if key, err := enc.DecodeString(s); err == nil && len(key) == SecretBoxKeySize {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("ожидается ключ из %d байт в hex или base64 (например, openssl rand -base64 32)", SecretBoxKeySize)
+}
+
+// NewDevSecretBox - случайный ключ для разработки. Зашифрованные им секреты
+// после перезапуска не расшифровать.
+func NewDevSecretBox() (*SecretBox, error) {
+	key := make([]byte, SecretBoxKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return NewSecretBox(key)
+}
+
+// Seal - шифрование plaintext со случайным nonce: "enc:v1:" + base64(nonce + шифротекст)
+func (b *SecretBox) Seal(plaintext, associated string) (string, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := b.aead.Seal(nonce, nonce, []byte(plaintext), []byte(associated))
+	return sealedPrefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Open - расшифровка значения Seal с тем же associated
+func (b *SecretBox) Open(sealed, associated string) (string, error) {
+	encoded, ok := strings.CutPrefix(sealed, sealedPrefix)
+	if !ok {
+		return "", errSecretBoxOpen
+	}
+	data, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil || len(data) < b.aead.NonceSize() {
+		return "", errSecretBoxOpen
+	}
+	nonce, ciphertext := data[:b.aead.NonceSize()], data[b.aead.NonceSize():]
+	plaintext, err := b.aead.Open(nil, nonce, ciphertext, []byte(associated))
+	if err != nil {
+		return "", errSecretBoxOpen
+	}
+	return string(plaintext), nil
+}
+
+// Sealed - зашифровано ли значение (иначе это открытый текст, сохраненный до шифрования)
+func (b *SecretBox) Sealed(value string) bool {
+	return strings.HasPrefix(value, sealedPrefix)
+}
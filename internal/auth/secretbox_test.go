@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestSecretBoxRoundTrip(t *testing.T) {
+	box, err := NewDevSecretBox()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sealed, err := box.Seal(rfc6238Secret, "users.totp_secret:7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !box.Sealed(sealed) || strings.Contains(sealed, rfc6238Secret) {
+		t.Fatalf("Seal = %q", sealed)
+	}
+	if again, _ := box.Seal(rfc6238Secret, "users.totp_secret:7"); again == sealed {
+		t.Error("одинаковый шифротекст для одного секрета: nonce не случайный")
+	}
+
+	opened, err := box.Open(sealed, "users.totp_secret:7")
+	if err != nil || opened != rfc6238Secret {
+		t.Fatalf("Open = %q, %v", opened, err)
+	}
+
+	// Шифротекст другого пользователя, чужой ключ, поврежденные данные и открытый текст не расшифровываются
+	other, _ := NewDevSecretBox()
+	tampered := sealed[:len(sealed)-2] + "AA"
+	if tampered == sealed {
+		tampered = sealed[:len(sealed)-2] + "BB"
+	}
+	for name, open := range map[string]func() (string, error){
+		"другой пользователь": func() (string, error) { return box.Open(sealed, "users.totp_secret:8") },
+		"другой ключ":         func() (string, error) { return other.Open(sealed, "users.totp_secret:7") },
+		"поврежденные данные": func() (string, error) { return box.Open(tampered, "users.totp_secret:7") },
+		"открытый текст":      func() (string, error) { return box.Open(rfc6238Secret, "users.totp_secret:7") },
+	} {
+		if got, err := open(); err == nil {
+			t.Errorf("%s: расшифровано %q", name, got)
+		}
+	}
+	if box.Sealed(rfc6238Secret) {
+		t.Error("base32 секрет принят за зашифрованный")
+	}
+}
+
+func TestParseSecretBoxKey(t *testing.T) {
+	key := make([]byte, SecretBoxKeySize)
+	for i := range key {
+		key[i] = byte(i)
+	}
+
+	for _, s := range []string{
+		hex.EncodeToString(key),
+		base64.StdEncoding.EncodeToString(key),
+		base64.RawURLEncoding.EncodeToString(key),
+		" " + base64.StdEncoding.EncodeToString(key) + "\n",
+	} {
+		got, err := ParseSecretBoxKey(s)
+		if err != nil || string(got) != string(key) {
+			t.Errorf("ParseSecretBoxKey(%q) = %x, %v", s, got, err)
+		}
+	}
+
+	for _, s := range []string{"", "short", hex.EncodeToString(key[:16]), base64.StdEncoding.EncodeToString(append(key, 0))} {
+		if _, err := ParseSecretBoxKey(s); err == nil {
+			t.Errorf("ParseSecretBoxKey(%q) без ошибки", s)
+		}
+	}
+	if _, err := NewSecretBox(key[:16]); err == nil {
+		t.Error("NewSecretBox принял ключ AES-128")
+	}
+}
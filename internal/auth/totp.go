@@ -0,0 +1,173 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Clock - источник текущего времени; в тестах подменяется фиксированным
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc - функция как Clock
+type ClockFunc func() time.Time
+
+// Now - текущее время по функции
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+// SystemClock - системные часы
+var SystemClock Clock = ClockFunc(time.Now)
+
+// TOTPIssuer - название сервиса в приложении-аутентификаторе
+const TOTPIssuer = "Cosmos Explorer"
+
+// totpSecretSize - длина секрета TOTP в байтах (160 бит, как рекомендует RFC 4226)
+const totpSecretSize = 20
+
+// RecoveryCodeCount - сколько одноразовых кодов восстановления выдается за раз
+const RecoveryCodeCount = 10
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TOTP - генерация и проверка одноразовых кодов по RFC 6238 (HMAC-SHA1)
+type TOTP struct {
+	Period time.Duration // шаг времени
+	Digits int           // длина кода
+	Skew   int           // сколько соседних шагов принимать из-за расхождения часов
+	Clock  Clock
+}
+
+// NewTOTP - параметры, которые понимают все распространенные приложения: 30 секунд, 6 цифр
+func NewTOTP(clock Clock) *TOTP {
+	return &TOTP{Period: 30 * time.Second, Digits: 6, Skew: 1, Clock: clock}
+}
+
+// GenerateTOTPSecret - случайный секрет в base32 без выравнивания
+func GenerateTOTPSecret() (string, error) {
+	buf := make([]byte, totpSecretSize)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(buf), nil
+}
+
+// Step - номер шага времени для момента t
+func (t *TOTP) Step(at time.Time) int64 {
+	return at.Unix() / int64(t.Period/time.Second)
+}
+
+// CodeAt - код для момента времени at
+func (t *TOTP) CodeAt(secret string, at time.Time) (string, error) {
+	key, err := decodeTOTPSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return t.code(key, t.Step(at)), nil
+}
+
+// Validate - проверка кода по текущему времени Clock. Возвращает шаг, которому
+// соответствует код: шаг не больше lastStep уже использован и повторно не принимается.
+func (t *TOTP) Validate(secret, code string, lastStep int64) (int64, bool) {
+	code = strings.ReplaceAll(code, " ", "")
+	if len(code) != t.Digits {
+		return 0, false
+	}
+
+	key, err := decodeTOTPSecret(secret)
+	if err != nil {
+		return 0, false
+	}
+
+	current := t.Step(t.Clock.Now())
+	for i := -t.Skew; i <= t.Skew; i++ {
+		step := current + int64(i)
+		if step <= lastStep {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(t.code(key, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// URI - otpauth:// ссылка для QR-кода приложения-аутентификатора
+func (t *TOTP) URI(account, secret string) string {
+	label := url.PathEscape(TOTPIssuer + ":" + account)
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", TOTPIssuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", strconv.Itoa(t.Digits))
+	params.Set("period", strconv.Itoa(int(t.Period/time.Second)))
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
+
+// code - HOTP (RFC 4226) для номера шага
+func (t *TOTP) code(key []byte, step int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < t.Digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", t.Digits, value%mod)
+}
+
+func decodeTOTPSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.TrimRight(strings.ReplaceAll(secret, " ", ""), "="))
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil {
+		return nil, fmt.Errorf("некорректный секрет TOTP: %w", err)
+	}
+	return key, nil
+}
+
+// NewRecoveryCodes - одноразовые коды восстановления вида xxxxx-xxxxx и их хэши для БД
+func NewRecoveryCodes(n int) (codes, hashes []string, err error) {
+	// 32 символа без l, o, 0 и 1: младшие 5 бит байта выбирают символ без перекоса
+	const alphabet = "abcdefghijkmnpqrstuvwxyz23456789"
+
+	for i := 0; i < n; i++ {
+		buf := make([]byte, 10)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+		for j := range buf {
+			buf[j] = alphabet[buf[j]&31]
+		}
+		code := string(buf[:5]) + "-" + string(buf[5:])
+		codes = append(codes, code)
+		hashes = append(hashes, HashToken(code))
+	}
+	return codes, hashes, nil
+}
+
+// NormalizeRecoveryCode - код восстановления в том виде, в котором хранится его хэш
+func NormalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), " ", ""))
+	if len(code) == 10 && !strings.Contains(code, "-") {
+		code = code[:5] + "-" + code[5:]
+	}
+	return code
+}
@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret - ключ тестовых векторов RFC 6238 для SHA-1 ("12345678901234567890") в base32
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// fixedClock - часы, стоящие на моменте unix
+func fixedClock(unix int64) Clock {
+	return ClockFunc(func() time.Time { return time.Unix(unix, 0) })
+}
+
+// RFC 6238, приложение B: 8 цифр, шаг 30 секунд, HMAC-SHA1
+func TestTOTPRFC6238Vectors(t *testing.T) {
+	vectors := []struct {
+		unix int64
+		code string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+
+	for _, v := range vectors {
+		totp := &TOTP{Period: 30 * time.Second, Digits: 8, Clock: fixedClock(v.unix)}
+
+		code, err := totp.CodeAt(rfc6238Secret, time.Unix(v.unix, 0))
+		if err != nil {
+			t.Fatalf("CodeAt(%d): %v", v.unix, err)
+		}
+		if code != v.code {
+			t.Errorf("CodeAt(%d) = %s, want %s", v.unix, code, v.code)
+		}
+
+		step, ok := totp.Validate(rfc6238Secret, v.code, 0)
+		if !ok || step != v.unix/30 {
+			t.Errorf("Validate(%d) = %d, %v; want %d, true", v.unix, step, ok, v.unix/30)
+		}
+	}
+}
+
+func TestTOTPSkew(t *testing.T) {
+	const now = 1111111111 // шаг 37037037
+	totp := NewTOTP(fixedClock(now))
+	current := totp.Step(time.Unix(now, 0))
+
+	tests := []struct {
+		offset int64 // в шагах от текущего
+		ok     bool
+	}{
+		{-2, false},
+		{-1, true},
+		{0, true},
+		{1, true},
+		{2, false},
+	}
+	for _, tt := range tests {
+		code, err := totp.CodeAt(rfc6238Secret, time.Unix(now+tt.offset*30, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		step, ok := totp.Validate(rfc6238Secret, code, 0)
+		if ok != tt.ok {
+			t.Errorf("шаг %+d: Validate = %v, want %v", tt.offset, ok, tt.ok)
+		}
+		if ok && step != current+tt.offset {
+			t.Errorf("шаг %+d: Validate вернул шаг %d, want %d", tt.offset, step, current+tt.offset)
+		}
+	}
+
+	// Без допуска принимается только текущий шаг
+	totp.Skew = 0
+	prev, _ := totp.CodeAt(rfc6238Secret, time.Unix(now-30, 0))
+	if _, ok := totp.Validate(rfc6238Secret, prev, 0); ok {
+		t.Error("Skew=0: принят код предыдущего шага")
+	}
+}
+
+func TestTOTPReplay(t *testing.T) {
+	const now = 1234567890
+	totp := NewTOTP(fixedClock(now))
+
+	code, err := totp.CodeAt(rfc6238Secret, time.Unix(now, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	step, ok := totp.Validate(rfc6238Secret, code, 0)
+	if !ok {
+		t.Fatal("код не принят")
+	}
+
+	// Тот же код после входа: его шаг уже сохранен как lastStep
+	if _, ok := totp.Validate(rfc6238Secret, code, step); ok {
+		t.Error("повторно принят код шага lastStep")
+	}
+
+	// Код предыдущего шага в пределах допуска тоже старше lastStep
+	prev, _ := totp.CodeAt(rfc6238Secret, time.Unix(now-30, 0))
+	if _, ok := totp.Validate(rfc6238Secret, prev, step); ok {
+		t.Error("принят код шага раньше lastStep")
+	}
+
+	// Следующий шаг после lastStep принимается
+	totp.Clock = fixedClock(now + 30)
+	next, _ := totp.CodeAt(rfc6238Secret, time.Unix(now+30, 0))
+	if got, ok := totp.Validate(rfc6238Secret, next, step); !ok || got != step+1 {
+		t.Errorf("код следующего шага: Validate = %d, %v", got, ok)
+	}
+}
+
+func TestTOTPValidateInput(t *testing.T) {
+	const now = 1234567890
+	totp := NewTOTP(fixedClock(now))
+	code, _ := totp.CodeAt(rfc6238Secret, time.Unix(now, 0))
+
+	if _, ok := totp.Validate(rfc6238Secret, code[:3]+" "+code[3:], 0); !ok {
+		t.Error("не принят код с пробелом")
+	}
+	if _, ok := totp.Validate(strings.ToLower(rfc6238Secret), code, 0); !ok {
+		t.Error("не принят секрет в нижнем регистре")
+	}
+	for _, bad := range []string{"", code[:5], code + "0", "abcdef"} {
+		if _, ok := totp.Validate(rfc6238Secret, bad, 0); ok {
+			t.Errorf("принят код %q", bad)
+		}
+	}
+	if _, ok := totp.Validate("not base32!", code, 0); ok {
+		t.Error("принят код для некорректного секрета")
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, hashes, err := NewRecoveryCodes(RecoveryCodeCount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != RecoveryCodeCount || len(hashes) != RecoveryCodeCount {
+		t.Fatalf("выдано %d кодов и %d хэшей", len(codes), len(hashes))
+	}
+
+	seen := map[string]bool{}
+	for i, code := range codes {
+		if len(code) != 11 || code[5] != '-' {
+			t.Errorf("код %q не в формате xxxxx-xxxxx", code)
+		}
+		if seen[code] {
+			t.Errorf("код %q выдан дважды", code)
+		}
+		seen[code] = true
+
+		// Код, введенный без дефиса и заглавными, находит тот же хэш
+		typed := strings.ToUpper(strings.ReplaceAll(code, "-", ""))
+		if HashToken(NormalizeRecoveryCode(typed)) != hashes[i] {
+			t.Errorf("NormalizeRecoveryCode(%q) не совпадает с хэшем %q", typed, code)
+		}
+	}
+}
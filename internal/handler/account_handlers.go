@@ -0,0 +1,270 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+
+	"cosmos/internal/auth"
+	"cosmos/internal/models"
+	"cosmos/internal/repository"
+)
+
+// errInvalidCredentials - неверный логин или пароль (без уточнения, что именно)
+var errInvalidCredentials = errors.New("неверный логин или пароль")
+
+// dummyPasswordHash - хэш для сверки пароля несуществующего пользователя
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, err := auth.HashPassword("cosmos-dummy-password")
+	if err != nil {
+		slog.Error("Ошибка создания фиктивного хэша", "err", err)
+	}
+	return hash
+})
+
+// RegisterHandler - регистрация обычного пользователя
+func (h *Handler) RegisterHandler(w http.ResponseWriter, r *http.Request) {
+	// Вошедшему пользователю регистрация не нужна
+	if h.currentViewer(r) != nil {
+		http.Redirect(w, r, "/profile", http.StatusFound)
+		return
+	}
+
+	type RegisterPageData struct {
+		models.PageData
+		Form  models.User
+		Error string
+	}
+
+	data := RegisterPageData{
+		PageData: models.PageData{
+			Title:       "Регистрация",
+			CurrentPage: "register",
+		},
+	}
+
+	if r.Method == http.MethodPost {
+		username := r.FormValue("username")
+		email := r.FormValue("email")
+		password := r.FormValue("password")
+		passwordConfirm := r.FormValue("password_confirm")
+
+		data.Form.Username = username
+		data.Form.Email = email
+
+		// Самостоятельно можно зарегистрироваться только с ролью "user"
+		if err := h.validateUserFields(r.Context(), username, email, password, auth.RoleUser, true); err != nil {
+			data.Error = err.Error()
+		} else if password != passwordConfirm {
+			data.Error = "Пароли не совпадают"
+		} else if exists, err := h.Users.Exists(r.Context(), username, email, 0); err != nil {
+			slog.ErrorContext(r.Context(), "Ошибка проверки пользователя", "err", err)
+			data.Error = "Ошибка сервера"
+		} else if exists {
+			data.Error = "Пользователь с таким логином или email уже существует"
+		} else {
+			user, err := h.createUser(r.Context(), username, email, password, auth.RoleUser)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Ошибка регистрации пользователя", "err", err)
+				data.Error = "Ошибка сохранения в базу данных"
+			} else {
+				slog.InfoContext(r.Context(), "Зарегистрирован пользователь", "user", user.Username, "id", user.ID)
+
+				if err := h.sendVerificationMail(r.Context(), user); err != nil {
+					slog.ErrorContext(r.Context(), "Ошибка отправки письма подтверждения", "err", err)
+				}
+
+				// Сразу выполняем вход (со вторым шагом, если 2FA обязательна для роли)
+				h.completeLogin(w, r, user, "/profile")
+				return
+			}
+		}
+	}
+
+	h.render(w, r, &data)
+}
+
+// loginPageData - страница входа; ее же показывает вход через SSO при ошибке
+type loginPageData struct {
+	models.PageData
+	LoginName string
+	Next      string
+	Error     string
+	SSOName   string // название провайдера на кнопке входа через SSO
+}
+
+func (h *Handler) newLoginPageData(next string) loginPageData {
+	return loginPageData{
+		PageData: models.PageData{
+			Title:       "Вход",
+			CurrentPage: "login",
+		},
+		Next:    next,
+		SSOName: h.ssoName(),
+	}
+}
+
+// LoginHandler - вход для пользователей любой роли
+func (h *Handler) LoginHandler(w http.ResponseWriter, r *http.Request) {
+	next := safeRedirectTarget(r.FormValue("next"), "/profile")
+
+	if h.currentViewer(r) != nil {
+		http.Redirect(w, r, next, http.StatusFound)
+		return
+	}
+
+	data := h.newLoginPageData(next)
+
+	if r.Method == http.MethodPost {
+		username := r.FormValue("username")
+		password := r.FormValue("password")
+		data.LoginName = username
+
+		user, err := h.attemptLogin(r, username, password)
+		if err != nil {
+			if errors.Is(err, errInvalidCredentials) {
+				data.Error = "Неверный логин или пароль"
+			} else {
+				slog.ErrorContext(r.Context(), "Ошибка запроса пользователя", "err", err)
+				data.Error = "Ошибка сервера"
+			}
+		} else {
+			h.completeLogin(w, r, user, next)
+			return
+		}
+	}
+
+	h.render(w, r, &data)
+}
+
+// LogoutHandler - выход с отзывом сессии и возврат на главную
+func (h *Handler) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	h.endSession(w, r)
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+// ProfileHandler - профиль вошедшего пользователя: просмотр, смена email и пароля
+func (h *Handler) ProfileHandler(w http.ResponseWriter, r *http.Request) {
+	claims := requestClaims(r)
+
+	user, err := h.Users.Get(r.Context(), claims.UserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			// Пользователь удален - токен больше не действителен
+			h.clearAuthCookie(w)
+			http.Redirect(w, r, "/login", http.StatusFound)
+			return
+		}
+		slog.ErrorContext(r.Context(), "Ошибка получения профиля", "err", err)
+		http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+		return
+	}
+
+	type ProfilePageData struct {
+		models.PageData
+		Profile models.User
+		Error   string
+		Success string
+	}
+
+	data := ProfilePageData{
+		PageData: models.PageData{
+			Title:       "Профиль",
+			CurrentPage: "profile",
+		},
+		Profile: user,
+	}
+	data.Success = r.URL.Query().Get("success")
+	data.Error = r.URL.Query().Get("error")
+
+	if r.Method == http.MethodPost {
+		email := r.FormValue("email")
+		currentPassword := r.FormValue("current_password")
+		newPassword := r.FormValue("new_password")
+
+		if ok, err := h.verifyUserPassword(r.Context(), user.ID, currentPassword); err != nil {
+			slog.ErrorContext(r.Context(), "Ошибка проверки пароля", "err", err)
+			data.Error = "Ошибка сервера"
+		} else if !ok {
+			data.Error = "Текущий пароль указан неверно"
+		} else if email == "" {
+			data.Error = "Email обязателен"
+		} else if err := h.validatePassword(user.Username, newPassword, false); err != nil {
+			data.Error = err.Error()
+		} else if exists, err := h.Users.Exists(r.Context(), user.Username, email, user.ID); err != nil || exists {
+			data.Error = "Email уже занят другим пользователем"
+		} else if err := h.updateUser(r.Context(), user.ID, user.Username, email, user.Role, newPassword); err != nil {
+			slog.ErrorContext(r.Context(), "Ошибка обновления профиля", "err", err)
+			data.Error = "Ошибка сохранения в базу данных"
+		} else {
+			data.Success = "Профиль обновлен"
+			if email != user.Email {
+				data.Profile.Email = email
+				data.Profile.EmailVerified = false
+				if err := h.sendVerificationMail(r.Context(), data.Profile); err != nil {
+					slog.ErrorContext(r.Context(), "Ошибка отправки письма подтверждения", "err", err)
+				} else {
+					data.Success = "Профиль обновлен. Подтвердите новый адрес по ссылке из письма"
+				}
+			}
+		}
+	}
+
+	h.render(w, r, &data)
+}
+
+// checkCredentials - поиск пользователя по логину и проверка пароля.
+// Для неизвестного логина и неверного пароля возвращает одну и ту же ошибку.
+func (h *Handler) checkCredentials(ctx context.Context, username, password string) (models.User, error) {
+	user, err := h.Users.GetByUsername(ctx, username)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			// Сверяем с фиктивным хэшем, чтобы время ответа не выдавало, существует ли логин
+			auth.CheckPassword(password, dummyPasswordHash())
+			return user, errInvalidCredentials
+		}
+		return user, err
+	}
+
+	if !auth.CheckPassword(password, user.PasswordHash) {
+		return user, errInvalidCredentials
+	}
+
+	// Пароль известен только сейчас, поэтому старый bcrypt хэш или хэш с устаревшими
+	// параметрами argon2id пересчитывается при входе
+	if auth.NeedsRehash(user.PasswordHash) {
+		h.rehashPassword(ctx, user, password)
+	}
+
+	return user, nil
+}
+
+// rehashPassword - замена хэша пароля на argon2id с текущими параметрами. Ошибка
+// только логируется: вход уже состоялся, хэш обновится при следующем входе.
+func (h *Handler) rehashPassword(ctx context.Context, user models.User, password string) {
+	hash, err := auth.HashPassword(password)
+	replaced := false
+	if err == nil {
+		// Условие на старый хэш: пароль могли сменить параллельно
+		replaced, err = h.Users.ReplacePasswordHash(ctx, user.ID, user.PasswordHash, hash)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Ошибка обновления хэша пароля", "user", user.Username, "err", err)
+		return
+	}
+	if !replaced {
+		return
+	}
+	slog.InfoContext(ctx, "Хэш пароля обновлен до argon2id", "user", user.Username)
+}
+
+// verifyUserPassword - проверка пароля пользователя по ID
+func (h *Handler) verifyUserPassword(ctx context.Context, id int, password string) (bool, error) {
+	user, err := h.Users.Get(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	return auth.CheckPassword(password, user.PasswordHash), nil
+}
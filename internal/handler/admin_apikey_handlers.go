@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"cosmos/internal/auth"
+	"cosmos/internal/models"
+	"cosmos/internal/repository"
+)
+
+// apiKeyDateLayout - формат поля срока действия в форме
+const apiKeyDateLayout = "2006-01-02"
+
+// AdminAPIKeysHandler - список API ключей и выпуск нового
+func (h *Handler) AdminAPIKeysHandler(w http.ResponseWriter, r *http.Request) {
+	h.setEncoding(w)
+
+	type APIKeyForm struct {
+		UserID  int
+		Name    string
+		Scopes  map[string]bool
+		Expires string
+	}
+
+	type APIKeysPageData struct {
+		models.PageData
+		Keys       []models.APIKey
+		AllScopes  []string
+		Form       APIKeyForm
+		CreatedKey string // открытый ключ, показывается один раз после выпуска
+	}
+
+	data := APIKeysPageData{
+		PageData: models.PageData{
+			Title:       "API ключи",
+			CurrentPage: "admin_api_keys",
+			IsAdmin:     true,
+			Success:     r.URL.Query().Get("success"),
+		},
+		AllScopes: auth.Scopes,
+		Form:      APIKeyForm{Scopes: map[string]bool{}},
+	}
+
+	if r.Method == http.MethodPost {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Некорректная форма", http.StatusBadRequest)
+			return
+		}
+
+		userID, _ := strconv.Atoi(r.FormValue("user_id"))
+		name := strings.TrimSpace(r.FormValue("name"))
+		scopes := r.Form["scopes"]
+		expires := r.FormValue("expires")
+
+		data.Form = APIKeyForm{UserID: userID, Name: name, Scopes: map[string]bool{}, Expires: expires}
+		for _, scope := range scopes {
+			data.Form.Scopes[scope] = true
+		}
+
+		expiresAt, err := parseAPIKeyExpiry(expires)
+		if err != nil {
+			data.Error = err.Error()
+		} else if err := validateAPIKeyFields(name, scopes); err != nil {
+			data.Error = err.Error()
+		} else if _, err := h.Users.Get(r.Context(), userID); err != nil {
+			data.Error = "Выберите владельца ключа"
+		} else {
+			apiKey, key, err := h.createAPIKey(r.Context(), userID, name, scopes, expiresAt)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Ошибка создания API ключа", "err", err)
+				data.Error = "Ошибка сохранения в базу данных"
+			} else {
+				slog.InfoContext(r.Context(), "Выпущен API ключ",
+					"prefix", apiKey.Prefix, "id", apiKey.ID, "owner_id", userID, "scopes", scopes)
+				data.CreatedKey = key
+				data.Success = "Ключ «" + name + "» создан. Скопируйте его сейчас: повторно он показан не будет."
+				data.Form = APIKeyForm{Scopes: map[string]bool{}}
+			}
+		}
+	}
+
+	keys, err := h.APIKeys.List(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка получения API ключей", "err", err)
+		http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+		return
+	}
+	data.Keys = keys
+
+	users, err := h.Users.List(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка получения пользователей", "err", err)
+		http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+		return
+	}
+	data.Users = users
+
+	h.render(w, r, &data)
+}
+
+// AdminDeleteAPIKeyHandler - отзыв API ключа (POST /admin/api-keys/delete/{id})
+func (h *Handler) AdminDeleteAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.APIKeys.Delete(r.Context(), id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		slog.ErrorContext(r.Context(), "Ошибка удаления API ключа", "id", id, "err", err)
+		http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "API ключ отозван", "id", id)
+	http.Redirect(w, r, "/admin/api-keys?success="+url.QueryEscape("Ключ отозван"), http.StatusFound)
+}
+
+// parseAPIKeyExpiry - дата из формы; ключ действует до конца указанного дня. Пусто - бессрочный.
+func parseAPIKeyExpiry(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	day, err := time.ParseInLocation(apiKeyDateLayout, value, time.Local)
+	if err != nil {
+		return nil, errors.New("Некорректная дата окончания действия")
+	}
+
+	expiresAt := day.AddDate(0, 0, 1)
+	if !expiresAt.After(time.Now()) {
+		return nil, errors.New("Дата окончания действия должна быть в будущем")
+	}
+	return &expiresAt, nil
+}
@@ -1,8 +1,8 @@
 package handler
 
 import (
-	"database/sql"
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
 
 	"cosmos/internal/auth"
@@ -13,13 +13,9 @@ import (
 func (h *Handler) AdminLoginHandler(w http.ResponseWriter, r *http.Request) {
 	h.setEncoding(w)
 
-	log.Printf("🔐 Запрос на вход: %s", r.Method)
-
 	// Если уже авторизован - редирект в админку
-	if token := auth.GetTokenFromRequest(r); token != "" {
-		log.Printf("🔐 Найден токен в запросе")
-		if claims, err := auth.ValidateToken(token); err == nil && claims.Role == "admin" {
-			log.Printf("✅ Уже авторизован как %s", claims.Username)
+	if claims, err := h.authenticate(r); err == nil {
+		if ok, _ := h.Roles.HasPermission(r.Context(), claims.Role, auth.PermAdminAccess); ok {
 			http.Redirect(w, r, "/admin", http.StatusFound)
 			return
 		}
@@ -30,6 +26,7 @@ func (h *Handler) AdminLoginHandler(w http.ResponseWriter, r *http.Request) {
 		models.PageData
 		Username string
 		Error    string
+		SSOName  string
 	}
 
 	data := LoginPageData{
@@ -37,87 +34,56 @@ func (h *Handler) AdminLoginHandler(w http.ResponseWriter, r *http.Request) {
 			Title:       "Вход в админ-панель",
 			CurrentPage: "admin_login",
 		},
+		SSOName: h.ssoName(),
 	}
 
 	if r.Method == http.MethodPost {
 		username := r.FormValue("username")
 		password := r.FormValue("password")
 
-		log.Printf("🔐 Попытка входа: %s", username)
+		slog.DebugContext(r.Context(), "Попытка входа в админку", "user", username)
 
 		data.Username = username
 
-		// Ищем пользователя в БД
-		var user models.User
-		err := h.DB.QueryRow("SELECT id, username, password_hash, role FROM users WHERE username = $1",
-			username).Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Role)
+		user, err := h.attemptLogin(r, username, password)
+		var canAccess bool
+		if err == nil {
+			canAccess, err = h.Roles.HasPermission(r.Context(), user.Role, auth.PermAdminAccess)
+		}
 
 		if err != nil {
-			if err == sql.ErrNoRows {
+			if errors.Is(err, errInvalidCredentials) {
 				data.Error = "Неверный логин или пароль"
-				log.Printf("Пользователь не найден: %s", username)
 			} else {
-				log.Printf("Ошибка запроса пользователя: %v", err)
+				slog.ErrorContext(r.Context(), "Ошибка запроса пользователя", "err", err)
 				data.Error = "Ошибка сервера"
 			}
-		} else if !auth.CheckPassword(password, user.PasswordHash) {
-			data.Error = "Неверный логин или пароль"
-			log.Printf("Неверный пароль для: %s", username)
-		} else if user.Role != "admin" {
+		} else if !canAccess {
 			data.Error = "У вас нет прав администратора"
-			log.Printf("Не админ: %s (роль: %s)", username, user.Role)
+			slog.WarnContext(r.Context(), "Вход в админку без права доступа", "user", username, "role", user.Role)
 		} else {
-			log.Printf("✅ Успешная проверка логина/пароля для: %s", username)
-
-			// Создаем JWT токен
-			token, err := auth.GenerateToken(user.Username, user.Role, user.ID)
-			if err != nil {
-				log.Printf("Ошибка создания токена: %v", err)
-				http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
-				return
-			}
-
-			log.Printf("✅ Токен создан для: %s", user.Username)
-
-			// Сохраняем токен в cookie
-			http.SetCookie(w, &http.Cookie{
-				Name:     "auth_token",
-				Value:    token,
-				Path:     "/",
-				HttpOnly: true,
-				MaxAge:   24 * 60 * 60, // 24 часа
-			})
+			slog.InfoContext(r.Context(), "Вход в админку", "user", username)
 
-			log.Printf("✅ Cookie установлен, редирект на /admin")
-
-			http.Redirect(w, r, "/admin", http.StatusFound)
+			// Создаем сессию (или переходим ко второму фактору) и редиректим в админку
+			h.completeLogin(w, r, user, "/admin")
 			return
 		}
 	}
 
 	// Используем шаблон admin_login
-	err := h.Tmpl.ExecuteTemplate(w, "base.html", data)
-	if err != nil {
-		log.Printf("Ошибка выполнения шаблона admin_login: %v", err)
-		http.Error(w, "Ошибка отображения страницы", http.StatusInternalServerError)
-	}
+	h.render(w, r, &data)
 }
 
 // AdminDashboardHandler - главная страница админки
 func (h *Handler) AdminDashboardHandler(w http.ResponseWriter, r *http.Request) {
 	h.setEncoding(w)
 
-	// Проверяем авторизацию
-	claims, err := h.requireAdminAuth(w, r)
-	if err != nil {
-		return
-	}
+	claims := requestClaims(r)
 
-	// Получаем статистику
-	var planetCount, galaxyCount, adminCount int
-	h.DB.QueryRow("SELECT COUNT(*) FROM planets").Scan(&planetCount)
-	h.DB.QueryRow("SELECT COUNT(*) FROM galaxies").Scan(&galaxyCount)
-	h.DB.QueryRow("SELECT COUNT(*) FROM users WHERE role = 'admin'").Scan(&adminCount)
+	// Получаем статистику (при ошибке показываем 0)
+	planetCount, _ := h.Planets.Count(r.Context())
+	galaxyCount, _ := h.Galaxies.Count(r.Context())
+	adminCount, _ := h.Users.CountByRole(r.Context(), auth.RoleAdmin)
 
 	data := models.PageData{
 		Title:       "Админ-панель",
@@ -130,23 +96,13 @@ func (h *Handler) AdminDashboardHandler(w http.ResponseWriter, r *http.Request)
 		Role:        claims.Role,
 	}
 
-	err = h.Tmpl.ExecuteTemplate(w, "base.html", data)
-	if err != nil {
-		log.Printf("Ошибка выполнения шаблона admin_dashboard: %v", err)
-		http.Error(w, "Ошибка отображения страницы", http.StatusInternalServerError)
-	}
+	h.render(w, r, &data)
 }
 
 // AdminLogoutHandler - выход из админки
 func (h *Handler) AdminLogoutHandler(w http.ResponseWriter, r *http.Request) {
-	// Удаляем cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "auth_token",
-		Value:    "",
-		Path:     "/",
-		HttpOnly: true,
-		MaxAge:   -1, // Удалить cookie
-	})
+	// Отзываем сессию и удаляем cookie
+	h.endSession(w, r)
 
 	http.Redirect(w, r, "/admin/login", http.StatusFound)
 }
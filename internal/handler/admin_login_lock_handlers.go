@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+	"net/url"
+
+	"cosmos/internal/models"
+	"cosmos/internal/repository"
+)
+
+// AdminLoginLocksHandler - заблокированные логины и IP адреса
+func (h *Handler) AdminLoginLocksHandler(w http.ResponseWriter, r *http.Request) {
+	h.setEncoding(w)
+
+	// Заодно удаляем счетчики, которые уже истекли
+	if err := h.LoginThrottle.Purge(r.Context(), h.LoginPolicy.Lockout); err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка очистки счетчиков входа", "err", err)
+	}
+
+	locks, err := h.LoginThrottle.List(r.Context(), h.LoginPolicy.Lockout)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка получения блокировок входа", "err", err)
+		http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+		return
+	}
+
+	type LoginLocksPageData struct {
+		models.PageData
+		Locks  []models.LoginLock
+		Policy LoginPolicy
+	}
+
+	data := LoginLocksPageData{
+		PageData: models.PageData{
+			Title:       "Блокировки входа",
+			CurrentPage: "admin_login_locks",
+			IsAdmin:     true,
+			Success:     r.URL.Query().Get("success"),
+		},
+		Locks:  locks,
+		Policy: h.LoginPolicy,
+	}
+
+	h.render(w, r, &data)
+}
+
+// AdminUnlockLoginHandler - снятие блокировки логина или IP (POST kind, key)
+func (h *Handler) AdminUnlockLoginHandler(w http.ResponseWriter, r *http.Request) {
+	claims := requestClaims(r)
+
+	kind, key := r.FormValue("kind"), r.FormValue("key")
+	if kind != repository.ThrottleUser && kind != repository.ThrottleIP {
+		http.Error(w, "Неизвестный вид блокировки", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.LoginThrottle.Reset(r.Context(), kind, key); err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка снятия блокировки входа", "kind", kind, "key", key, "err", err)
+		http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Блокировка входа снята", "admin", claims.Username, "kind", kind, "key", key)
+	http.Redirect(w, r, "/admin/login-locks?"+url.Values{"success": {"Блокировка снята: " + key}}.Encode(), http.StatusFound)
+}
@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"cosmos/internal/models"
+)
+
+// AdminSessionsHandler - активные сессии пользователей; ?user=ID оставляет сессии одного пользователя
+func (h *Handler) AdminSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	h.setEncoding(w)
+
+	claims := requestClaims(r)
+
+	sessions, err := h.Sessions.ListActive(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка получения сессий", "err", err)
+		http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+		return
+	}
+
+	filterUserID, _ := strconv.Atoi(r.URL.Query().Get("user"))
+	if filterUserID > 0 {
+		filtered := sessions[:0]
+		for _, s := range sessions {
+			if s.UserID == filterUserID {
+				filtered = append(filtered, s)
+			}
+		}
+		sessions = filtered
+	}
+
+	type SessionsPageData struct {
+		models.PageData
+		Sessions         []models.Session
+		FilterUserID     int
+		CurrentSessionID int64
+	}
+
+	data := SessionsPageData{
+		PageData: models.PageData{
+			Title:       "Активные сессии",
+			CurrentPage: "admin_sessions",
+			IsAdmin:     true,
+			Success:     r.URL.Query().Get("success"),
+		},
+		Sessions:         sessions,
+		FilterUserID:     filterUserID,
+		CurrentSessionID: claims.SessionID,
+	}
+
+	h.render(w, r, &data)
+}
+
+// AdminRevokeSessionHandler - завершение одной сессии (POST /admin/sessions/revoke/{id})
+func (h *Handler) AdminRevokeSessionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	if err := h.Sessions.Revoke(r.Context(), id); err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка отзыва сессии", "session_id", id, "err", err)
+		http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Сессия завершена администратором", "session_id", id)
+	http.Redirect(w, r, sessionsRedirect(r, "Сессия завершена"), http.StatusFound)
+}
+
+// AdminRevokeUserSessionsHandler - завершение всех сессий пользователя (POST /admin/sessions/revoke-user/{id})
+func (h *Handler) AdminRevokeUserSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	revoked, err := h.Sessions.RevokeUser(r.Context(), userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка отзыва сессий пользователя", "target_user_id", userID, "err", err)
+		http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Администратор завершил сессии пользователя", "target_user_id", userID, "revoked_sessions", revoked)
+	http.Redirect(w, r, sessionsRedirect(r, "Завершено сессий: "+strconv.FormatInt(revoked, 10)), http.StatusFound)
+}
+
+// sessionsRedirect - возврат на список сессий с сохранением фильтра по пользователю
+func sessionsRedirect(r *http.Request, message string) string {
+	query := url.Values{"success": {message}}
+	if user := r.FormValue("user"); user != "" {
+		query.Set("user", user)
+	}
+	return "/admin/sessions?" + query.Encode()
+}
@@ -0,0 +1,182 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"cosmos/internal/auth"
+	"cosmos/internal/repository"
+	"cosmos/internal/router"
+
+	"github.com/lib/pq"
+)
+
+// maxAPIBodySize - максимальный размер тела JSON запроса
+const maxAPIBodySize = 1 << 20
+
+// apiError - тело ошибки, одинаковое для всех JSON эндпоинтов
+type apiError struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+// apiErrorResponse - обертка ошибки: {"error": {...}}
+type apiErrorResponse struct {
+	Error apiError `json:"error"`
+}
+
+// writeJSON - отправка JSON ответа
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if v == nil {
+		return
+	}
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("Ошибка кодирования JSON ответа", "err", err)
+	}
+}
+
+// writeAPIError - отправка ошибки в JSON формате
+func writeAPIError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, apiErrorResponse{Error: apiError{Status: status, Message: message}})
+}
+
+// writeMethodNotAllowed - 405 с заголовком Allow
+func writeMethodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeAPIError(w, http.StatusMethodNotAllowed, "Метод не поддерживается")
+}
+
+// decodeJSON - чтение JSON тела запроса в v
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxAPIBodySize)
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		if errors.Is(err, io.EOF) {
+			return errors.New("пустое тело запроса")
+		}
+		return errors.New("некорректный JSON: " + err.Error())
+	}
+	if dec.More() {
+		return errors.New("тело запроса должно содержать один JSON объект")
+	}
+	return nil
+}
+
+// apiPathID - числовой параметр {id} из шаблона маршрута; при ошибке отвечает 404
+func apiPathID(w http.ResponseWriter, r *http.Request, notFoundMessage string) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id <= 0 {
+		writeAPIError(w, http.StatusNotFound, notFoundMessage)
+		return 0, false
+	}
+	return id, true
+}
+
+// requireAPIAuth - middleware маршрута API: пропускает клиента с действующим токеном
+// или API ключом, а если задано permission - только при наличии у роли этого права.
+// API ключ, кроме того, должен иметь scope маршрута.
+func (h *Handler) requireAPIAuth(permission, scope string) router.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, err := h.apiClaims(w, r)
+			if err != nil {
+				return
+			}
+			if permission != "" && !h.apiAllowed(w, r, claims, permission, scope) {
+				return
+			}
+			next.ServeHTTP(w, withClaims(r, claims))
+		})
+	}
+}
+
+// apiAllowed - проверка права роли и scope API ключа; при отказе отвечает 403
+func (h *Handler) apiAllowed(w http.ResponseWriter, r *http.Request, claims *auth.Claims, permission, scope string) bool {
+	allowed, err := h.Roles.HasPermission(r.Context(), claims.Role, permission)
+	if err != nil {
+		writeDBError(w, r, err, "проверка прав")
+		return false
+	}
+	if !allowed {
+		writeAPIError(w, http.StatusForbidden, "Недостаточно прав: требуется "+permission)
+		return false
+	}
+
+	if claims.APIKeyID != 0 {
+		if scope == "" {
+			writeAPIError(w, http.StatusForbidden, "Операция недоступна для API ключей")
+			return false
+		}
+		if !claims.HasScope(scope) {
+			writeAPIError(w, http.StatusForbidden, "У API ключа нет scope "+scope)
+			return false
+		}
+	}
+
+	return true
+}
+
+// apiClaims - проверка Bearer токена (с его сессией) или API ключа любого пользователя
+func (h *Handler) apiClaims(w http.ResponseWriter, r *http.Request) (*auth.Claims, error) {
+	var claims *auth.Claims
+	var err error
+	if token := auth.GetTokenFromRequest(r); auth.IsAPIKey(token) {
+		claims, err = h.authenticateAPIKey(r.Context(), token)
+	} else {
+		claims, err = h.authenticate(r)
+	}
+	if err == nil {
+		return claims, nil
+	}
+
+	switch {
+	case errors.Is(err, errNotAuthenticated):
+		w.Header().Set("WWW-Authenticate", `Bearer realm="cosmos"`)
+		writeAPIError(w, http.StatusUnauthorized, "Требуется авторизация")
+	case errors.Is(err, errSessionInvalid), errors.Is(err, errSessionRace):
+		w.Header().Set("WWW-Authenticate", `Bearer realm="cosmos", error="invalid_token"`)
+		writeAPIError(w, http.StatusUnauthorized, "Неверный, просроченный или отозванный токен")
+	default:
+		writeAPIError(w, http.StatusInternalServerError, "Ошибка сервера")
+	}
+	return nil, err
+}
+
+// writeDBError - преобразование ошибки хранилища или PostgreSQL в JSON ответ
+func writeDBError(w http.ResponseWriter, r *http.Request, err error, context string) {
+	switch {
+	case errors.Is(err, repository.ErrConflict):
+		writeAPIError(w, http.StatusConflict, "Объект с такими уникальными полями уже существует")
+		return
+	case errors.Is(err, repository.ErrReference):
+		writeAPIError(w, http.StatusUnprocessableEntity, "Связанный объект не найден")
+		return
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code.Name() {
+		case "unique_violation":
+			writeAPIError(w, http.StatusConflict, "Объект с такими уникальными полями уже существует")
+			return
+		case "foreign_key_violation":
+			writeAPIError(w, http.StatusUnprocessableEntity, "Связанный объект не найден")
+			return
+		case "check_violation", "not_null_violation", "numeric_value_out_of_range", "invalid_text_representation":
+			writeAPIError(w, http.StatusUnprocessableEntity, "Некорректные данные: "+pqErr.Message)
+			return
+		}
+	}
+
+	slog.ErrorContext(r.Context(), "Ошибка БД", "op", context, "err", err)
+	writeAPIError(w, http.StatusInternalServerError, "Ошибка сервера")
+}
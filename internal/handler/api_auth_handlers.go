@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"cosmos/internal/auth"
+)
+
+// tokenRequest - тело запроса входа по логину и паролю
+type tokenRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	TOTPCode string `json:"totp_code,omitempty"` // код 2FA или код восстановления, если 2FA включена
+}
+
+// refreshRequest - тело запроса обновления токенов
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// tokenResponse - пара токенов сессии
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"` // срок жизни access_token в секундах
+}
+
+func newTokenResponse(accessToken, refreshToken string) tokenResponse {
+	return tokenResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(auth.AccessTokenTTL.Seconds()),
+	}
+}
+
+// apiIssueToken - вход по логину и паролю: новая сессия и пара токенов
+func (h *Handler) apiIssueToken(w http.ResponseWriter, r *http.Request) {
+	var payload tokenRequest
+	if err := decodeJSON(w, r, &payload); err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	user, err := h.attemptLogin(r, payload.Username, payload.Password)
+	if err != nil {
+		if errors.Is(err, errInvalidCredentials) {
+			writeAPIError(w, http.StatusUnauthorized, "Неверный логин или пароль")
+			return
+		}
+		writeDBError(w, r, err, "проверка логина")
+		return
+	}
+
+	state, err := h.TwoFactor.State(r.Context(), user.ID)
+	if err != nil {
+		writeDBError(w, r, err, "получение настроек 2FA")
+		return
+	}
+
+	if state.Enabled {
+		if payload.TOTPCode == "" {
+			writeAPIError(w, http.StatusUnauthorized, "Требуется код двухфакторной аутентификации (totp_code)")
+			return
+		}
+		ok, _, err := h.verifySecondFactor(r.Context(), user.ID, state, payload.TOTPCode)
+		if err != nil {
+			writeDBError(w, r, err, "проверка кода 2FA")
+			return
+		}
+		if !ok {
+			h.recordLoginFailure(r.Context(), user.Username, clientIP(r))
+			writeAPIError(w, http.StatusUnauthorized, "Неверный или уже использованный код 2FA")
+			return
+		}
+	} else if state.Required {
+		writeAPIError(w, http.StatusForbidden, "Для роли обязательна двухфакторная аутентификация: настройте ее на странице /profile/2fa")
+		return
+	}
+
+	accessToken, refreshToken, err := h.openSession(user, r)
+	if err != nil {
+		writeDBError(w, r, err, "создание сессии")
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Выданы токены API", "user", user.Username)
+	writeJSON(w, http.StatusOK, newTokenResponse(accessToken, refreshToken))
+}
+
+// apiRefreshToken - обмен refresh токена на новую пару; старый refresh токен больше не действует
+func (h *Handler) apiRefreshToken(w http.ResponseWriter, r *http.Request) {
+	var payload refreshRequest
+	if err := decodeJSON(w, r, &payload); err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if payload.RefreshToken == "" {
+		writeAPIError(w, http.StatusUnprocessableEntity, "refresh_token обязателен")
+		return
+	}
+
+	_, accessToken, refreshToken, err := h.rotateSession(payload.RefreshToken, r)
+	if err != nil {
+		if errors.Is(err, errSessionInvalid) || errors.Is(err, errSessionRace) {
+			writeAPIError(w, http.StatusUnauthorized, "Неверный, просроченный или отозванный refresh токен")
+			return
+		}
+		writeDBError(w, r, err, "обновление сессии")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newTokenResponse(accessToken, refreshToken))
+}
+
+// apiRevokeToken - выход: отзыв сессии текущего токена
+func (h *Handler) apiRevokeToken(w http.ResponseWriter, r *http.Request) {
+	claims := requestClaims(r)
+
+	if claims.APIKeyID != 0 {
+		writeAPIError(w, http.StatusBadRequest, "API ключ не связан с сессией; ключи отзываются в админ-панели")
+		return
+	}
+
+	if err := h.Sessions.Revoke(r.Context(), claims.SessionID); err != nil {
+		writeDBError(w, r, err, "отзыв сессии")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// JWKSHandler - публичные ключи для проверки токенов другими сервисами
+func (h *Handler) JWKSHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	writeJSON(w, http.StatusOK, auth.CurrentKeyring().JWKS())
+}
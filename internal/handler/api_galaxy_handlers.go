@@ -0,0 +1,230 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"cosmos/internal/models"
+	"cosmos/internal/repository"
+)
+
+const apiGalaxiesPath = "/api/v1/galaxies"
+
+// galaxyInput - тело запросов создания и изменения галактики без полей,
+// которые выставляет сервер (id и даты); как и у планет, они отклоняются с 400
+type galaxyInput struct {
+	Name                string   `json:"name"`
+	Type                string   `json:"type"`
+	DiameterLy          *float64 `json:"diameter_ly"`
+	MassSuns            *float64 `json:"mass_suns"`
+	DistanceFromEarthLy *float64 `json:"distance_from_earth_ly"`
+	DiscoveredYear      *int     `json:"discovered_year"`
+	Description         string   `json:"description"`
+}
+
+// galaxyInputFrom - текущие значения галактики для PATCH
+func galaxyInputFrom(g models.Galaxy) galaxyInput {
+	return galaxyInput{
+		Name:                g.Name,
+		Type:                g.Type,
+		DiameterLy:          g.DiameterLy,
+		MassSuns:            g.MassSuns,
+		DistanceFromEarthLy: g.DistanceFromEarthLy,
+		DiscoveredYear:      g.DiscoveredYear,
+		Description:         g.Description,
+	}
+}
+
+// galaxy - модель для сохранения в репозиторий
+func (in galaxyInput) galaxy() models.Galaxy {
+	return models.Galaxy{
+		Name:                in.Name,
+		Type:                in.Type,
+		DiameterLy:          in.DiameterLy,
+		MassSuns:            in.MassSuns,
+		DistanceFromEarthLy: in.DistanceFromEarthLy,
+		DiscoveredYear:      in.DiscoveredYear,
+		Description:         in.Description,
+	}
+}
+
+// galaxyDeleteResult - ответ на удаление галактики
+type galaxyDeleteResult struct {
+	ID              int   `json:"id"`
+	DetachedPlanets int64 `json:"detached_planets"`
+}
+
+func (h *Handler) apiListGalaxies(w http.ResponseWriter, r *http.Request) {
+	galaxies, err := h.Galaxies.List(r.Context(), repository.ByName)
+	if err != nil {
+		writeDBError(w, r, err, "список галактик")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, galaxies)
+}
+
+func (h *Handler) apiGetGalaxy(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiPathID(w, r, "Галактика не найдена")
+	if !ok {
+		return
+	}
+
+	galaxy, err := h.Galaxies.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			writeAPIError(w, http.StatusNotFound, "Галактика не найдена")
+			return
+		}
+		writeDBError(w, r, err, "получение галактики")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, galaxy)
+}
+
+func (h *Handler) apiListGalaxyPlanets(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiPathID(w, r, "Галактика не найдена")
+	if !ok {
+		return
+	}
+
+	// Отличаем пустую галактику от несуществующей
+	if _, err := h.Galaxies.Get(r.Context(), id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			writeAPIError(w, http.StatusNotFound, "Галактика не найдена")
+			return
+		}
+		writeDBError(w, r, err, "получение галактики")
+		return
+	}
+
+	planets, err := h.Planets.ListByGalaxy(r.Context(), id)
+	if err != nil {
+		writeDBError(w, r, err, "список планет галактики")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, planets)
+}
+
+func (h *Handler) apiCreateGalaxy(w http.ResponseWriter, r *http.Request) {
+	var input galaxyInput
+	if err := decodeJSON(w, r, &input); err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	galaxy := input.galaxy()
+
+	if err := validateGalaxy(galaxy); err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	if err := h.Galaxies.Create(r.Context(), &galaxy); err != nil {
+		writeDBError(w, r, err, "создание галактики")
+		return
+	}
+
+	created, err := h.Galaxies.Get(r.Context(), galaxy.ID)
+	if err != nil {
+		writeDBError(w, r, err, "получение созданной галактики")
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Галактика создана через API", "galaxy", created.Name, "id", created.ID)
+
+	w.Header().Set("Location", apiGalaxiesPath+"/"+strconv.Itoa(created.ID))
+	writeJSON(w, http.StatusCreated, created)
+}
+
+// apiReplaceGalaxy - PUT: полная замена, отсутствующие поля сбрасываются
+func (h *Handler) apiReplaceGalaxy(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiPathID(w, r, "Галактика не найдена")
+	if !ok {
+		return
+	}
+
+	var input galaxyInput
+	if err := decodeJSON(w, r, &input); err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.apiSaveGalaxy(w, r, id, input.galaxy())
+}
+
+// apiPatchGalaxy - PATCH: частичное обновление, явный null очищает поле
+func (h *Handler) apiPatchGalaxy(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiPathID(w, r, "Галактика не найдена")
+	if !ok {
+		return
+	}
+
+	galaxy, err := h.Galaxies.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			writeAPIError(w, http.StatusNotFound, "Галактика не найдена")
+			return
+		}
+		writeDBError(w, r, err, "получение галактики")
+		return
+	}
+
+	input := galaxyInputFrom(galaxy)
+	if err := decodeJSON(w, r, &input); err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.apiSaveGalaxy(w, r, id, input.galaxy())
+}
+
+// apiSaveGalaxy - общая часть PUT и PATCH
+func (h *Handler) apiSaveGalaxy(w http.ResponseWriter, r *http.Request, id int, galaxy models.Galaxy) {
+	if err := validateGalaxy(galaxy); err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	if err := h.Galaxies.Update(r.Context(), id, &galaxy); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			writeAPIError(w, http.StatusNotFound, "Галактика не найдена")
+			return
+		}
+		writeDBError(w, r, err, "обновление галактики")
+		return
+	}
+
+	updated, err := h.Galaxies.Get(r.Context(), id)
+	if err != nil {
+		writeDBError(w, r, err, "получение обновленной галактики")
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Галактика обновлена через API", "id", id)
+	writeJSON(w, http.StatusOK, updated)
+}
+
+// apiDeleteGalaxy - удаление галактики; планеты остаются, но теряют привязку
+func (h *Handler) apiDeleteGalaxy(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiPathID(w, r, "Галактика не найдена")
+	if !ok {
+		return
+	}
+
+	detached, err := h.Galaxies.DeleteDetachingPlanets(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			writeAPIError(w, http.StatusNotFound, "Галактика не найдена")
+			return
+		}
+		writeDBError(w, r, err, "удаление галактики")
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Галактика удалена через API", "id", id, "detached_planets", detached)
+	writeJSON(w, http.StatusOK, galaxyDeleteResult{ID: id, DetachedPlanets: detached})
+}
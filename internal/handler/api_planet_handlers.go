@@ -0,0 +1,209 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"cosmos/internal/models"
+	"cosmos/internal/repository"
+)
+
+const apiPlanetsPath = "/api/v1/planets"
+
+// planetInput - тело запросов создания и изменения планеты: только поля,
+// которые задает клиент. id, galaxy_name и даты выставляет сервер, и
+// DisallowUnknownFields в decodeJSON отклоняет их с 400.
+type planetInput struct {
+	Name              string  `json:"name"`
+	GalaxyID          *int    `json:"galaxy_id"`
+	Type              string  `json:"type"`
+	DiameterKm        float64 `json:"diameter_km"`
+	MassKg            float64 `json:"mass_kg"`
+	OrbitalPeriodDays float64 `json:"orbital_period_days"`
+	HasLife           bool    `json:"has_life"`
+	IsHabitable       bool    `json:"is_habitable"`
+	DiscoveredYear    *int    `json:"discovered_year"`
+	Description       string  `json:"description"`
+}
+
+// planetInputFrom - текущие значения планеты, поверх которых PATCH декодирует изменения
+func planetInputFrom(p models.Planet) planetInput {
+	return planetInput{
+		Name:              p.Name,
+		GalaxyID:          p.GalaxyID,
+		Type:              p.Type,
+		DiameterKm:        p.DiameterKm,
+		MassKg:            p.MassKg,
+		OrbitalPeriodDays: p.OrbitalPeriodDays,
+		HasLife:           p.HasLife,
+		IsHabitable:       p.IsHabitable,
+		DiscoveredYear:    p.DiscoveredYear,
+		Description:       p.Description,
+	}
+}
+
+// planet - модель для сохранения в репозиторий
+func (in planetInput) planet() models.Planet {
+	return models.Planet{
+		Name:              in.Name,
+		GalaxyID:          in.GalaxyID,
+		Type:              in.Type,
+		DiameterKm:        in.DiameterKm,
+		MassKg:            in.MassKg,
+		OrbitalPeriodDays: in.OrbitalPeriodDays,
+		HasLife:           in.HasLife,
+		IsHabitable:       in.IsHabitable,
+		DiscoveredYear:    in.DiscoveredYear,
+		Description:       in.Description,
+	}
+}
+
+func (h *Handler) apiListPlanets(w http.ResponseWriter, r *http.Request) {
+	planets, err := h.Planets.List(r.Context(), repository.ByName)
+	if err != nil {
+		writeDBError(w, r, err, "список планет")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, planets)
+}
+
+func (h *Handler) apiGetPlanet(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiPathID(w, r, "Планета не найдена")
+	if !ok {
+		return
+	}
+
+	planet, err := h.Planets.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			writeAPIError(w, http.StatusNotFound, "Планета не найдена")
+			return
+		}
+		writeDBError(w, r, err, "получение планеты")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, planet)
+}
+
+func (h *Handler) apiCreatePlanet(w http.ResponseWriter, r *http.Request) {
+	var input planetInput
+	if err := decodeJSON(w, r, &input); err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	planet := input.planet()
+
+	if err := validatePlanet(planet); err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	if err := h.Planets.Create(r.Context(), &planet); err != nil {
+		writeDBError(w, r, err, "создание планеты")
+		return
+	}
+
+	created, err := h.Planets.Get(r.Context(), planet.ID)
+	if err != nil {
+		writeDBError(w, r, err, "получение созданной планеты")
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Планета создана через API", "planet", created.Name, "id", created.ID)
+
+	w.Header().Set("Location", apiPlanetsPath+"/"+strconv.Itoa(created.ID))
+	writeJSON(w, http.StatusCreated, created)
+}
+
+// apiReplacePlanet - PUT: полная замена, отсутствующие поля сбрасываются
+func (h *Handler) apiReplacePlanet(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiPathID(w, r, "Планета не найдена")
+	if !ok {
+		return
+	}
+
+	var input planetInput
+	if err := decodeJSON(w, r, &input); err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.apiSavePlanet(w, r, id, input.planet())
+}
+
+// apiPatchPlanet - PATCH: частичное обновление в духе JSON Merge Patch (RFC 7396)
+func (h *Handler) apiPatchPlanet(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiPathID(w, r, "Планета не найдена")
+	if !ok {
+		return
+	}
+
+	planet, err := h.Planets.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			writeAPIError(w, http.StatusNotFound, "Планета не найдена")
+			return
+		}
+		writeDBError(w, r, err, "получение планеты")
+		return
+	}
+
+	// Поверх текущих данных декодируем только переданные поля,
+	// явный null сбрасывает nullable поля (galaxy_id, discovered_year)
+	input := planetInputFrom(planet)
+	if err := decodeJSON(w, r, &input); err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.apiSavePlanet(w, r, id, input.planet())
+}
+
+// apiSavePlanet - общая часть PUT и PATCH
+func (h *Handler) apiSavePlanet(w http.ResponseWriter, r *http.Request, id int, planet models.Planet) {
+	if err := validatePlanet(planet); err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	if err := h.Planets.Update(r.Context(), id, &planet); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			writeAPIError(w, http.StatusNotFound, "Планета не найдена")
+			return
+		}
+		writeDBError(w, r, err, "обновление планеты")
+		return
+	}
+
+	updated, err := h.Planets.Get(r.Context(), id)
+	if err != nil {
+		writeDBError(w, r, err, "получение обновленной планеты")
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Планета обновлена через API", "id", id)
+	writeJSON(w, http.StatusOK, updated)
+}
+
+func (h *Handler) apiDeletePlanet(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiPathID(w, r, "Планета не найдена")
+	if !ok {
+		return
+	}
+
+	if err := h.Planets.Delete(r.Context(), id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			writeAPIError(w, http.StatusNotFound, "Планета не найдена")
+			return
+		}
+		writeDBError(w, r, err, "удаление планеты")
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Планета удалена через API", "id", id)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,222 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"cosmos/internal/auth"
+	"cosmos/internal/models"
+	"cosmos/internal/openapi"
+	"cosmos/internal/router"
+)
+
+// Уровни доступа к эндпоинтам API; маршрутам с Permission нужен вход и право
+const (
+	apiAccessPublic = "" // без токена
+	apiAccessUser   = "user"
+)
+
+// APIRoute - строка таблицы маршрутов JSON API.
+// Из этой таблицы регистрируются обработчики и генерируется OpenAPI спецификация,
+// поэтому документация не может разойтись с реально обслуживаемыми маршрутами.
+type APIRoute struct {
+	Method      string
+	Path        string // шаблон в формате net/http: /api/v1/planets/{id}
+	OperationID string
+	Summary     string
+	Tag         string
+	Access      string
+	Permission  string // право роли, необходимое для вызова (см. requireAPIAuth)
+	Scope       string // scope, с которым маршрут доступен по API ключу; пусто - недоступен
+	Request     any    // тип тела запроса для спецификации
+	Response    any    // тип тела ответа для спецификации
+	Status      int    // код успешного ответа
+	Errors      []int  // дополнительные коды ошибок, кроме выводимых автоматически
+	Handler     http.HandlerFunc
+}
+
+// APIRoutes - таблица маршрутов JSON API
+func (h *Handler) APIRoutes() []APIRoute {
+	return []APIRoute{
+		// Авторизация
+		{Method: http.MethodPost, Path: "/api/v1/auth/token", OperationID: "issueToken", Summary: "Вход: выдача access и refresh токенов",
+			Tag: "auth", Request: tokenRequest{}, Response: tokenResponse{}, Errors: []int{http.StatusUnauthorized, http.StatusForbidden},
+			Handler: h.apiIssueToken},
+		{Method: http.MethodPost, Path: "/api/v1/auth/refresh", OperationID: "refreshToken", Summary: "Обновление токенов по refresh токену",
+			Tag: "auth", Request: refreshRequest{}, Response: tokenResponse{}, Errors: []int{http.StatusUnauthorized},
+			Handler: h.apiRefreshToken},
+		{Method: http.MethodPost, Path: "/api/v1/auth/logout", OperationID: "logout", Summary: "Выход: отзыв текущей сессии",
+			Tag: "auth", Access: apiAccessUser, Status: http.StatusNoContent, Errors: []int{http.StatusBadRequest},
+			Handler: h.apiRevokeToken},
+
+		// Планеты
+		{Method: http.MethodGet, Path: apiPlanetsPath, OperationID: "listPlanets", Summary: "Список планет",
+			Tag: "planets", Response: []models.Planet{}, Handler: h.apiListPlanets},
+		{Method: http.MethodPost, Path: apiPlanetsPath, OperationID: "createPlanet", Summary: "Создание планеты",
+			Tag: "planets", Permission: auth.PermPlanetsWrite, Scope: auth.ScopeWritePlanets, Request: planetInput{}, Response: models.Planet{},
+			Status: http.StatusCreated, Errors: []int{http.StatusConflict}, Handler: h.apiCreatePlanet},
+		{Method: http.MethodGet, Path: apiPlanetsPath + "/{id}", OperationID: "getPlanet", Summary: "Планета по ID",
+			Tag: "planets", Response: models.Planet{}, Handler: h.apiGetPlanet},
+		{Method: http.MethodPut, Path: apiPlanetsPath + "/{id}", OperationID: "replacePlanet", Summary: "Полная замена планеты",
+			Tag: "planets", Permission: auth.PermPlanetsWrite, Scope: auth.ScopeWritePlanets, Request: planetInput{}, Response: models.Planet{},
+			Errors: []int{http.StatusConflict}, Handler: h.apiReplacePlanet},
+		{Method: http.MethodPatch, Path: apiPlanetsPath + "/{id}", OperationID: "patchPlanet", Summary: "Частичное обновление планеты (merge patch)",
+			Tag: "planets", Permission: auth.PermPlanetsWrite, Scope: auth.ScopeWritePlanets, Request: planetInput{}, Response: models.Planet{},
+			Errors: []int{http.StatusConflict}, Handler: h.apiPatchPlanet},
+		{Method: http.MethodDelete, Path: apiPlanetsPath + "/{id}", OperationID: "deletePlanet", Summary: "Удаление планеты",
+			Tag: "planets", Permission: auth.PermPlanetsDelete, Scope: auth.ScopeWritePlanets, Status: http.StatusNoContent, Handler: h.apiDeletePlanet},
+
+		// Галактики
+		{Method: http.MethodGet, Path: apiGalaxiesPath, OperationID: "listGalaxies", Summary: "Список галактик",
+			Tag: "galaxies", Response: []models.Galaxy{}, Handler: h.apiListGalaxies},
+		{Method: http.MethodPost, Path: apiGalaxiesPath, OperationID: "createGalaxy", Summary: "Создание галактики",
+			Tag: "galaxies", Permission: auth.PermGalaxiesWrite, Scope: auth.ScopeWriteGalaxies, Request: galaxyInput{}, Response: models.Galaxy{},
+			Status: http.StatusCreated, Errors: []int{http.StatusConflict}, Handler: h.apiCreateGalaxy},
+		{Method: http.MethodGet, Path: apiGalaxiesPath + "/{id}", OperationID: "getGalaxy", Summary: "Галактика по ID",
+			Tag: "galaxies", Response: models.Galaxy{}, Handler: h.apiGetGalaxy},
+		{Method: http.MethodPut, Path: apiGalaxiesPath + "/{id}", OperationID: "replaceGalaxy", Summary: "Полная замена галактики",
+			Tag: "galaxies", Permission: auth.PermGalaxiesWrite, Scope: auth.ScopeWriteGalaxies, Request: galaxyInput{}, Response: models.Galaxy{},
+			Errors: []int{http.StatusConflict}, Handler: h.apiReplaceGalaxy},
+		{Method: http.MethodPatch, Path: apiGalaxiesPath + "/{id}", OperationID: "patchGalaxy", Summary: "Частичное обновление галактики (merge patch)",
+			Tag: "galaxies", Permission: auth.PermGalaxiesWrite, Scope: auth.ScopeWriteGalaxies, Request: galaxyInput{}, Response: models.Galaxy{},
+			Errors: []int{http.StatusConflict}, Handler: h.apiPatchGalaxy},
+		{Method: http.MethodDelete, Path: apiGalaxiesPath + "/{id}", OperationID: "deleteGalaxy", Summary: "Удаление галактики с отвязкой планет",
+			Tag: "galaxies", Permission: auth.PermGalaxiesDelete, Scope: auth.ScopeWriteGalaxies, Response: galaxyDeleteResult{}, Handler: h.apiDeleteGalaxy},
+		{Method: http.MethodGet, Path: apiGalaxiesPath + "/{id}/planets", OperationID: "listGalaxyPlanets", Summary: "Планеты галактики",
+			Tag: "galaxies", Response: []models.Planet{}, Handler: h.apiListGalaxyPlanets},
+
+		// Пользователи
+		{Method: http.MethodGet, Path: apiUsersPath, OperationID: "listUsers", Summary: "Список пользователей",
+			Tag: "users", Permission: auth.PermUsersView, Scope: auth.ScopeReadUsers, Response: []models.User{}, Handler: h.apiListUsers},
+		{Method: http.MethodPost, Path: apiUsersPath, OperationID: "createUser", Summary: "Создание пользователя",
+			Tag: "users", Permission: auth.PermUsersManage, Scope: auth.ScopeAdminUsers, Request: userPayload{}, Response: models.User{},
+			Status: http.StatusCreated, Errors: []int{http.StatusConflict}, Handler: h.apiCreateUser},
+		{Method: http.MethodGet, Path: apiUsersPath + "/{id}", OperationID: "getUser", Summary: "Пользователь по ID",
+			Tag: "users", Permission: auth.PermUsersView, Scope: auth.ScopeReadUsers, Response: models.User{}, Handler: h.apiGetUser},
+		{Method: http.MethodPut, Path: apiUsersPath + "/{id}", OperationID: "replaceUser", Summary: "Замена данных пользователя",
+			Tag: "users", Permission: auth.PermUsersManage, Scope: auth.ScopeAdminUsers, Request: userPayload{}, Response: models.User{},
+			Errors: []int{http.StatusConflict}, Handler: h.apiReplaceUser},
+		{Method: http.MethodPatch, Path: apiUsersPath + "/{id}", OperationID: "patchUser", Summary: "Частичное обновление пользователя",
+			Tag: "users", Permission: auth.PermUsersManage, Scope: auth.ScopeAdminUsers, Request: userPayload{}, Response: models.User{},
+			Errors: []int{http.StatusConflict}, Handler: h.apiPatchUser},
+		{Method: http.MethodDelete, Path: apiUsersPath + "/{id}", OperationID: "deleteUser", Summary: "Удаление пользователя",
+			Tag: "users", Permission: auth.PermUsersManage, Scope: auth.ScopeAdminUsers, Status: http.StatusNoContent, Handler: h.apiDeleteUser},
+		{Method: http.MethodGet, Path: "/api/v1/me", OperationID: "getMe", Summary: "Текущий пользователь",
+			Tag: "users", Access: apiAccessUser, Response: models.User{}, Handler: h.apiMe},
+		{Method: http.MethodPut, Path: "/api/v1/me/password", OperationID: "changeMyPassword", Summary: "Смена своего пароля",
+			Tag: "users", Access: apiAccessUser, Request: passwordChangePayload{}, Status: http.StatusNoContent,
+			Errors: []int{http.StatusForbidden}, Handler: h.apiChangeMyPassword},
+	}
+}
+
+// RegisterAPIRoutes - регистрация маршрутов API, спецификации и JSON 404/405 для /api/.
+// Маршруты с Access или Permission закрываются middleware requireAPIAuth.
+func (h *Handler) RegisterAPIRoutes(rt *router.Router) {
+	routes := h.APIRoutes()
+	for _, route := range routes {
+		pattern := route.Method + " " + route.Path
+		if route.Access == apiAccessPublic && route.Permission == "" {
+			rt.HandleFunc(pattern, route.Handler)
+			continue
+		}
+		rt.With(h.requireAPIAuth(route.Permission, route.Scope)).HandleFunc(pattern, route.Handler)
+	}
+
+	rt.HandleFunc("GET /api/openapi.json", h.OpenAPIHandler)
+	rt.HandleFunc("/api/", func(w http.ResponseWriter, r *http.Request) {
+		apiFallback(w, r, routes)
+	})
+}
+
+// OpenAPIHandler - сгенерированная спецификация OpenAPI
+func (h *Handler) OpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.OpenAPISpec())
+}
+
+// OpenAPISpec - документ OpenAPI, построенный по APIRoutes и структурам models
+func (h *Handler) OpenAPISpec() *openapi.Document {
+	routes := h.APIRoutes()
+	ops := make([]openapi.Operation, 0, len(routes))
+
+	for _, route := range routes {
+		ops = append(ops, openapi.Operation{
+			Method:      route.Method,
+			Path:        route.Path,
+			OperationID: route.OperationID,
+			Summary:     route.Summary,
+			Tag:         route.Tag,
+			Secured:     route.Access != apiAccessPublic || route.Permission != "",
+			Permission:  route.Permission,
+			Scope:       route.Scope,
+			Request:     route.Request,
+			Response:    route.Response,
+			Status:      route.Status,
+			Errors:      routeErrors(route),
+		})
+	}
+
+	info := openapi.Info{
+		Title:       "Cosmos API",
+		Version:     "1.0.0",
+		Description: "JSON API для планет, галактик и пользователей. Ошибки возвращаются в теле {\"error\": {...}}.",
+	}
+
+	return openapi.Build(info, apiErrorResponse{}, ops)
+}
+
+// routeErrors - коды ошибок, которые может вернуть маршрут
+func routeErrors(route APIRoute) []int {
+	errs := append([]int{http.StatusInternalServerError}, route.Errors...)
+	if strings.Contains(route.Path, "{") {
+		errs = append(errs, http.StatusNotFound)
+	}
+	if route.Request != nil {
+		errs = append(errs, http.StatusBadRequest, http.StatusUnprocessableEntity)
+	}
+	if route.Access != apiAccessPublic || route.Permission != "" {
+		errs = append(errs, http.StatusUnauthorized)
+	}
+	if route.Permission != "" {
+		errs = append(errs, http.StatusForbidden)
+	}
+	return errs
+}
+
+// apiFallback - JSON ответ для путей /api/, не совпавших ни с одним маршрутом:
+// 405 с Allow, если путь известен, но метод другой, иначе 404
+func apiFallback(w http.ResponseWriter, r *http.Request, routes []APIRoute) {
+	var allowed []string
+	for _, route := range routes {
+		if pathMatches(route.Path, r.URL.Path) {
+			allowed = append(allowed, route.Method)
+		}
+	}
+
+	if len(allowed) > 0 {
+		writeMethodNotAllowed(w, allowed...)
+		return
+	}
+	writeAPIError(w, http.StatusNotFound, "Ресурс не найден")
+}
+
+// pathMatches - совпадение пути с шаблоном, где {param} заменяет один сегмент
+func pathMatches(pattern, path string) bool {
+	patternParts := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathParts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(patternParts) != len(pathParts) {
+		return false
+	}
+
+	for i, part := range patternParts {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			if pathParts[i] == "" {
+				return false
+			}
+			continue
+		}
+		if part != pathParts[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"cosmos/internal/router"
+)
+
+// Каждый маршрут таблицы обслуживается своим обработчиком, а не JSON 404/405 для /api/
+func TestRegisterAPIRoutesServesEveryRoute(t *testing.T) {
+	a := newAPITest(t)
+
+	for _, route := range a.h.APIRoutes() {
+		path := strings.ReplaceAll(route.Path, "{id}", "1")
+		req := httptest.NewRequest(route.Method, path, strings.NewReader("{}"))
+		req = req.WithContext(router.TrackRoute(req.Context()))
+		rec := httptest.NewRecorder()
+		a.rt.ServeHTTP(rec, req)
+
+		if got := router.Route(req.Context()); got != route.Path {
+			t.Errorf("%s %s: обработан маршрутом %q (статус %d)", route.Method, route.Path, got, rec.Code)
+		}
+		if rec.Code == http.StatusMethodNotAllowed {
+			t.Errorf("%s %s: 405", route.Method, route.Path)
+		}
+	}
+
+	// Неизвестные пути и методы уходят в JSON fallback
+	for _, tt := range []struct {
+		method, path string
+		status       int
+	}{
+		{http.MethodGet, "/api/v1/unknown", http.StatusNotFound},
+		{http.MethodPost, "/api/v1/planets/1", http.StatusMethodNotAllowed},
+	} {
+		rec := httptest.NewRecorder()
+		a.rt.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		if rec.Code != tt.status || !strings.HasPrefix(rec.Header().Get("Content-Type"), "application/json") {
+			t.Errorf("%s %s: статус %d (%s), want %d JSON", tt.method, tt.path, rec.Code, rec.Header().Get("Content-Type"), tt.status)
+		}
+	}
+}
+
+func TestOpenAPISpec(t *testing.T) {
+	a := newAPITest(t)
+
+	rec := httptest.NewRecorder()
+	a.rt.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("статус %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+		Info    struct {
+			Title   string `json:"title"`
+			Version string `json:"version"`
+		} `json:"info"`
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Schemas map[string]json.RawMessage `json:"schemas"`
+		} `json:"components"`
+	}
+	raw := rec.Body.Bytes()
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		t.Fatalf("спецификация - не JSON: %v", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		t.Errorf("openapi = %q, want 3.x", doc.OpenAPI)
+	}
+	if doc.Info.Title == "" || doc.Info.Version == "" {
+		t.Errorf("info = %+v", doc.Info)
+	}
+
+	// Каждый маршрут таблицы описан в paths со своим operationId, и наоборот
+	seenIDs := map[string]bool{}
+	described := 0
+	for _, route := range a.h.APIRoutes() {
+		op, ok := doc.Paths[route.Path][strings.ToLower(route.Method)]
+		if !ok {
+			t.Errorf("%s %s нет в paths", route.Method, route.Path)
+			continue
+		}
+		var operation struct {
+			OperationID string                     `json:"operationId"`
+			Responses   map[string]json.RawMessage `json:"responses"`
+		}
+		if err := json.Unmarshal(op, &operation); err != nil {
+			t.Fatal(err)
+		}
+		if operation.OperationID != route.OperationID {
+			t.Errorf("%s %s: operationId %q, want %q", route.Method, route.Path, operation.OperationID, route.OperationID)
+		}
+		if seenIDs[operation.OperationID] {
+			t.Errorf("operationId %q повторяется", operation.OperationID)
+		}
+		seenIDs[operation.OperationID] = true
+		if len(operation.Responses) == 0 {
+			t.Errorf("%s %s: нет responses", route.Method, route.Path)
+		}
+		described++
+	}
+	total := 0
+	for _, ops := range doc.Paths {
+		total += len(ops)
+	}
+	if total != described {
+		t.Errorf("в paths %d операций, в таблице маршрутов %d", total, described)
+	}
+
+	// Все ссылки указывают на описанные схемы
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			if ref, ok := v["$ref"].(string); ok {
+				name, found := strings.CutPrefix(ref, "#/components/schemas/")
+				if _, exists := doc.Components.Schemas[name]; !found || !exists {
+					t.Errorf("ссылка %q не найдена в components.schemas", ref)
+				}
+			}
+			for _, item := range v {
+				walk(item)
+			}
+		case []any:
+			for _, item := range v {
+				walk(item)
+			}
+		}
+	}
+	var tree any
+	if err := json.Unmarshal(raw, &tree); err != nil {
+		t.Fatal(err)
+	}
+	walk(tree)
+}
@@ -0,0 +1,435 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"cosmos/internal/auth"
+	"cosmos/internal/models"
+	"cosmos/internal/repository"
+	"cosmos/internal/router"
+)
+
+// testPassword - пароль пользователей в тестах API
+const testPassword = "orbit-2024-secret"
+
+// apiTest - маршруты API над хранилищем в памяти
+type apiTest struct {
+	t   *testing.T
+	h   *Handler
+	mem *repository.Memory
+	rt  http.Handler
+}
+
+func newAPITest(t *testing.T) *apiTest {
+	t.Helper()
+	h, mem := newMemoryHandler(t)
+	rt := router.New()
+	h.RegisterAPIRoutes(rt)
+	return &apiTest{t: t, h: h, mem: mem, rt: rt}
+}
+
+// createUser - пользователь с паролем testPassword
+func (a *apiTest) createUser(username, role string) models.User {
+	a.t.Helper()
+	user, err := a.h.createUser(a.t.Context(), username, username+"@example.com", testPassword, role)
+	if err != nil {
+		a.t.Fatalf("создание пользователя %s: %v", username, err)
+	}
+	return user
+}
+
+// login - новый пользователь с ролью role и его access токен, выданный /api/v1/auth/token
+func (a *apiTest) login(username, role string) (models.User, string) {
+	a.t.Helper()
+	user := a.createUser(username, role)
+
+	rec := a.do("", http.MethodPost, "/api/v1/auth/token", tokenRequest{Username: username, Password: testPassword})
+	if rec.Code != http.StatusOK {
+		a.t.Fatalf("вход %s: статус %d: %s", username, rec.Code, rec.Body)
+	}
+	return user, decodeBody[tokenResponse](a.t, rec).AccessToken
+}
+
+// do - запрос к API с Bearer токеном (если задан); body кодируется в JSON,
+// строка отправляется как есть
+func (a *apiTest) do(token, method, path string, body any) *httptest.ResponseRecorder {
+	a.t.Helper()
+
+	var r io.Reader
+	switch b := body.(type) {
+	case nil:
+	case string:
+		r = strings.NewReader(b)
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			a.t.Fatal(err)
+		}
+		r = bytes.NewReader(data)
+	}
+
+	req := httptest.NewRequest(method, path, r)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	a.rt.ServeHTTP(rec, req)
+	return rec
+}
+
+// expect - статус ответа; тело ошибки API при несовпадении попадает в сообщение
+func (a *apiTest) expect(rec *httptest.ResponseRecorder, status int, what string) {
+	a.t.Helper()
+	if rec.Code != status {
+		a.t.Fatalf("%s: статус %d, want %d: %s", what, rec.Code, status, rec.Body)
+	}
+}
+
+func decodeBody[T any](t *testing.T, rec *httptest.ResponseRecorder) T {
+	t.Helper()
+	var v T
+	if err := json.Unmarshal(rec.Body.Bytes(), &v); err != nil {
+		t.Fatalf("ответ не JSON %T: %v: %s", v, err, rec.Body)
+	}
+	return v
+}
+
+func TestAPIPlanetsCRUD(t *testing.T) {
+	a := newAPITest(t)
+	_, token := a.login("editor", "editor")
+
+	rec := a.do(token, http.MethodPost, apiGalaxiesPath, galaxyInput{Name: "Млечный Путь", Type: "спиральная", Description: "Наша галактика"})
+	a.expect(rec, http.StatusCreated, "создание галактики")
+	galaxy := decodeBody[models.Galaxy](t, rec)
+
+	year := 1781
+	rec = a.do(token, http.MethodPost, apiPlanetsPath, planetInput{
+		Name: "Уран", GalaxyID: &galaxy.ID, Type: "ледяной гигант", DiameterKm: 50724,
+		DiscoveredYear: &year, Description: "Седьмая планета",
+	})
+	a.expect(rec, http.StatusCreated, "создание планеты")
+	planet := decodeBody[models.Planet](t, rec)
+	path := apiPlanetsPath + "/" + strconv.Itoa(planet.ID)
+	if rec.Header().Get("Location") != path {
+		t.Errorf("Location = %q, want %q", rec.Header().Get("Location"), path)
+	}
+	if planet.ID == 0 || planet.GalaxyName != galaxy.Name || planet.CreatedAt.IsZero() {
+		t.Errorf("созданная планета = %+v", planet)
+	}
+
+	// Чтение доступно без токена
+	rec = a.do("", http.MethodGet, path, nil)
+	a.expect(rec, http.StatusOK, "получение планеты")
+	if got := decodeBody[models.Planet](t, rec); got.Name != "Уран" || got.DiscoveredYear == nil || *got.DiscoveredYear != year {
+		t.Errorf("прочитанная планета = %+v", got)
+	}
+
+	rec = a.do("", http.MethodGet, apiPlanetsPath, nil)
+	a.expect(rec, http.StatusOK, "список планет")
+	if list := decodeBody[[]models.Planet](t, rec); len(list) != 1 || list[0].ID != planet.ID {
+		t.Errorf("список = %+v", list)
+	}
+
+	// PUT заменяет все поля: непереданные галактика и год сбрасываются
+	rec = a.do(token, http.MethodPut, path, planetInput{Name: "Уран", Type: "ледяной гигант", Description: "Без галактики"})
+	a.expect(rec, http.StatusOK, "замена планеты")
+	if got := decodeBody[models.Planet](t, rec); got.GalaxyID != nil || got.DiscoveredYear != nil || got.Description != "Без галактики" {
+		t.Errorf("после PUT = %+v", got)
+	}
+
+	// PATCH меняет только переданные поля
+	rec = a.do(token, http.MethodPatch, path, `{"has_life": true}`)
+	a.expect(rec, http.StatusOK, "изменение планеты")
+	if got := decodeBody[models.Planet](t, rec); !got.HasLife || got.Description != "Без галактики" || got.Type != "ледяной гигант" {
+		t.Errorf("после PATCH = %+v", got)
+	}
+
+	a.expect(a.do(token, http.MethodDelete, path, nil), http.StatusNoContent, "удаление планеты")
+	a.expect(a.do("", http.MethodGet, path, nil), http.StatusNotFound, "удаленная планета")
+}
+
+func TestAPIPlanetErrors(t *testing.T) {
+	a := newAPITest(t)
+	_, token := a.login("editor", "editor")
+	_, userToken := a.login("reader", auth.RoleUser)
+
+	valid := planetInput{Name: "Марс", Type: "земная", Description: "Красная планета"}
+	a.expect(a.do(token, http.MethodPost, apiPlanetsPath, valid), http.StatusCreated, "создание планеты")
+
+	missingGalaxy := 42
+	tests := []struct {
+		name         string
+		token        string
+		method, path string
+		body         any
+		status       int
+	}{
+		{"нет планеты", "", http.MethodGet, apiPlanetsPath + "/999", nil, http.StatusNotFound},
+		{"нечисловой ID", "", http.MethodGet, apiPlanetsPath + "/mars", nil, http.StatusNotFound},
+		{"замена несуществующей", token, http.MethodPut, apiPlanetsPath + "/999", valid, http.StatusNotFound},
+		{"удаление несуществующей", token, http.MethodDelete, apiPlanetsPath + "/999", nil, http.StatusNotFound},
+		{"занятое имя", token, http.MethodPost, apiPlanetsPath, valid, http.StatusConflict},
+		{"без названия", token, http.MethodPost, apiPlanetsPath, planetInput{Type: "земная", Description: "?"}, http.StatusUnprocessableEntity},
+		{"несуществующая галактика", token, http.MethodPost, apiPlanetsPath,
+			planetInput{Name: "Фаэтон", GalaxyID: &missingGalaxy, Type: "земная", Description: "?"}, http.StatusUnprocessableEntity},
+		{"неизвестное поле", token, http.MethodPost, apiPlanetsPath, `{"name": "Фаэтон", "id": 7}`, http.StatusBadRequest},
+		{"без токена", "", http.MethodPost, apiPlanetsPath, valid, http.StatusUnauthorized},
+		{"без права planets.write", userToken, http.MethodPost, apiPlanetsPath, valid, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := a.do(tt.token, tt.method, tt.path, tt.body)
+			if rec.Code != tt.status {
+				t.Fatalf("статус %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if body := decodeBody[map[string]any](t, rec); body["error"] == nil {
+				t.Errorf("в ответе нет error: %s", rec.Body)
+			}
+		})
+	}
+}
+
+func TestAPIGalaxiesCRUD(t *testing.T) {
+	a := newAPITest(t)
+	_, token := a.login("editor", "editor")
+
+	diameter := 220000.0
+	rec := a.do(token, http.MethodPost, apiGalaxiesPath, galaxyInput{Name: "Андромеда", Type: "спиральная", DiameterLy: &diameter, Description: "M31"})
+	a.expect(rec, http.StatusCreated, "создание галактики")
+	galaxy := decodeBody[models.Galaxy](t, rec)
+	path := apiGalaxiesPath + "/" + strconv.Itoa(galaxy.ID)
+
+	rec = a.do("", http.MethodGet, path, nil)
+	a.expect(rec, http.StatusOK, "получение галактики")
+	if got := decodeBody[models.Galaxy](t, rec); got.Name != "Андромеда" || got.DiameterLy == nil || *got.DiameterLy != diameter {
+		t.Errorf("прочитанная галактика = %+v", got)
+	}
+
+	rec = a.do(token, http.MethodPatch, path, `{"description": "Туманность Андромеды"}`)
+	a.expect(rec, http.StatusOK, "изменение галактики")
+	if got := decodeBody[models.Galaxy](t, rec); got.Description != "Туманность Андромеды" || got.DiameterLy == nil {
+		t.Errorf("после PATCH = %+v", got)
+	}
+
+	rec = a.do(token, http.MethodPut, path, galaxyInput{Name: "Андромеда", Type: "спиральная", Description: "M31"})
+	a.expect(rec, http.StatusOK, "замена галактики")
+	if got := decodeBody[models.Galaxy](t, rec); got.DiameterLy != nil {
+		t.Errorf("PUT сохранил непереданный диаметр: %+v", got)
+	}
+
+	rec = a.do(token, http.MethodPost, apiPlanetsPath, planetInput{Name: "PA-99-N2 b", GalaxyID: &galaxy.ID, Type: "газовый гигант", Description: "Кандидат"})
+	a.expect(rec, http.StatusCreated, "создание планеты")
+	planet := decodeBody[models.Planet](t, rec)
+
+	rec = a.do("", http.MethodGet, path+"/planets", nil)
+	a.expect(rec, http.StatusOK, "планеты галактики")
+	if list := decodeBody[[]models.Planet](t, rec); len(list) != 1 || list[0].ID != planet.ID {
+		t.Errorf("планеты галактики = %+v", list)
+	}
+
+	// Удаление галактики отвязывает ее планеты, а не удаляет их
+	rec = a.do(token, http.MethodDelete, path, nil)
+	a.expect(rec, http.StatusOK, "удаление галактики")
+	if got := decodeBody[galaxyDeleteResult](t, rec); got.ID != galaxy.ID || got.DetachedPlanets != 1 {
+		t.Errorf("результат удаления = %+v", got)
+	}
+	a.expect(a.do("", http.MethodGet, path, nil), http.StatusNotFound, "удаленная галактика")
+
+	rec = a.do("", http.MethodGet, apiPlanetsPath+"/"+strconv.Itoa(planet.ID), nil)
+	a.expect(rec, http.StatusOK, "отвязанная планета")
+	if got := decodeBody[models.Planet](t, rec); got.GalaxyID != nil {
+		t.Errorf("планета осталась в удаленной галактике: %+v", got)
+	}
+}
+
+func TestAPIGalaxyErrors(t *testing.T) {
+	a := newAPITest(t)
+	_, token := a.login("editor", "editor")
+	_, viewerToken := a.login("viewer", "viewer")
+
+	valid := galaxyInput{Name: "Треугольник", Type: "спиральная", Description: "M33"}
+	a.expect(a.do(token, http.MethodPost, apiGalaxiesPath, valid), http.StatusCreated, "создание галактики")
+
+	tests := []struct {
+		name         string
+		token        string
+		method, path string
+		body         any
+		status       int
+	}{
+		{"нет галактики", "", http.MethodGet, apiGalaxiesPath + "/999", nil, http.StatusNotFound},
+		{"планеты несуществующей", "", http.MethodGet, apiGalaxiesPath + "/999/planets", nil, http.StatusNotFound},
+		{"изменение несуществующей", token, http.MethodPatch, apiGalaxiesPath + "/999", `{"type": "эллиптическая"}`, http.StatusNotFound},
+		{"удаление несуществующей", token, http.MethodDelete, apiGalaxiesPath + "/999", nil, http.StatusNotFound},
+		{"занятое имя", token, http.MethodPost, apiGalaxiesPath, valid, http.StatusConflict},
+		{"без названия", token, http.MethodPost, apiGalaxiesPath, galaxyInput{Type: "спиральная"}, http.StatusUnprocessableEntity},
+		{"некорректный JSON", token, http.MethodPost, apiGalaxiesPath, `{"name":`, http.StatusBadRequest},
+		{"без права galaxies.write", viewerToken, http.MethodPost, apiGalaxiesPath, valid, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := a.do(tt.token, tt.method, tt.path, tt.body); rec.Code != tt.status {
+				t.Fatalf("статус %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+		})
+	}
+}
+
+func TestAPIUsersCRUD(t *testing.T) {
+	a := newAPITest(t)
+	_, token := a.login("root", auth.RoleAdmin)
+
+	rec := a.do(token, http.MethodPost, apiUsersPath, `{"username": "pilot", "email": "pilot@example.com", "password": "`+testPassword+`", "role": "editor"}`)
+	a.expect(rec, http.StatusCreated, "создание пользователя")
+	user := decodeBody[models.User](t, rec)
+	path := apiUsersPath + "/" + strconv.Itoa(user.ID)
+	if user.Role != "editor" || strings.Contains(rec.Body.String(), "password") {
+		t.Errorf("созданный пользователь: %s", rec.Body)
+	}
+
+	rec = a.do(token, http.MethodGet, path, nil)
+	a.expect(rec, http.StatusOK, "получение пользователя")
+	if got := decodeBody[models.User](t, rec); got.Username != "pilot" || got.Email != "pilot@example.com" {
+		t.Errorf("прочитанный пользователь = %+v", got)
+	}
+
+	rec = a.do(token, http.MethodGet, apiUsersPath, nil)
+	a.expect(rec, http.StatusOK, "список пользователей")
+	if list := decodeBody[[]models.User](t, rec); len(list) != 2 {
+		t.Errorf("пользователей %d, want 2", len(list))
+	}
+
+	rec = a.do(token, http.MethodPatch, path, `{"email": "pilot@cosmos.test"}`)
+	a.expect(rec, http.StatusOK, "изменение email")
+	if got := decodeBody[models.User](t, rec); got.Email != "pilot@cosmos.test" || got.Username != "pilot" || got.Role != "editor" {
+		t.Errorf("после PATCH = %+v", got)
+	}
+
+	rec = a.do(token, http.MethodPut, path, `{"username": "navigator", "email": "pilot@cosmos.test", "role": "viewer"}`)
+	a.expect(rec, http.StatusOK, "замена пользователя")
+	if got := decodeBody[models.User](t, rec); got.Username != "navigator" || got.Role != "viewer" {
+		t.Errorf("после PUT = %+v", got)
+	}
+
+	// Пароль при PUT без password не меняется
+	if ok, err := a.h.verifyUserPassword(t.Context(), user.ID, testPassword); err != nil || !ok {
+		t.Errorf("пароль после PUT: ok=%v, err=%v", ok, err)
+	}
+
+	a.expect(a.do(token, http.MethodDelete, path, nil), http.StatusNoContent, "удаление пользователя")
+	a.expect(a.do(token, http.MethodGet, path, nil), http.StatusNotFound, "удаленный пользователь")
+}
+
+func TestAPIUserErrors(t *testing.T) {
+	a := newAPITest(t)
+	admin, token := a.login("root", auth.RoleAdmin)
+	_, viewerToken := a.login("viewer", "viewer")
+	a.createUser("taken", auth.RoleUser)
+
+	newUser := func(username, role, password string) string {
+		return `{"username": "` + username + `", "email": "` + username + `@example.com", "password": "` + password + `", "role": "` + role + `"}`
+	}
+	self := apiUsersPath + "/" + strconv.Itoa(admin.ID)
+
+	tests := []struct {
+		name         string
+		token        string
+		method, path string
+		body         any
+		status       int
+	}{
+		{"нет пользователя", token, http.MethodGet, apiUsersPath + "/999", nil, http.StatusNotFound},
+		{"изменение несуществующего", token, http.MethodPatch, apiUsersPath + "/999", `{"email": "x@example.com"}`, http.StatusNotFound},
+		{"удаление несуществующего", token, http.MethodDelete, apiUsersPath + "/999", nil, http.StatusNotFound},
+		{"занятый логин", token, http.MethodPost, apiUsersPath, newUser("taken", auth.RoleUser, testPassword), http.StatusConflict},
+		{"неизвестная роль", token, http.MethodPost, apiUsersPath, newUser("cadet", "captain", testPassword), http.StatusUnprocessableEntity},
+		{"короткий пароль", token, http.MethodPost, apiUsersPath, newUser("cadet", auth.RoleUser, "1234"), http.StatusUnprocessableEntity},
+		{"пустой пароль", token, http.MethodPatch, self, `{"password": ""}`, http.StatusUnprocessableEntity},
+		{"своя роль", token, http.MethodPatch, self, `{"role": "viewer"}`, http.StatusForbidden},
+		{"без права users.manage", viewerToken, http.MethodPost, apiUsersPath, newUser("cadet", auth.RoleUser, testPassword), http.StatusForbidden},
+		{"без токена", "", http.MethodGet, apiUsersPath, nil, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := a.do(tt.token, tt.method, tt.path, tt.body); rec.Code != tt.status {
+				t.Fatalf("статус %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+		})
+	}
+}
+
+// Смена роли отзывает сессии пользователя: токен со старой ролью перестает действовать
+func TestAPIRoleChangeRevokesSessions(t *testing.T) {
+	a := newAPITest(t)
+	_, adminToken := a.login("root", auth.RoleAdmin)
+	editor, editorToken := a.login("editor", "editor")
+
+	a.expect(a.do(editorToken, http.MethodGet, "/api/v1/me", nil), http.StatusOK, "токен редактора")
+
+	rec := a.do(adminToken, http.MethodPatch, apiUsersPath+"/"+strconv.Itoa(editor.ID), `{"role": "viewer"}`)
+	a.expect(rec, http.StatusOK, "смена роли")
+
+	a.expect(a.do(editorToken, http.MethodGet, "/api/v1/me", nil), http.StatusUnauthorized, "токен после смены роли")
+}
+
+// Право users.manage без роли admin не позволяет менять и удалять администраторов:
+// иначе менеджер мог бы сменить админу email или пароль и войти от его имени
+func TestAPIManagerCannotTouchAdmins(t *testing.T) {
+	a := newAPITest(t)
+	a.mem.AddRole("manager", "Менеджер пользователей", auth.PermUsersView, auth.PermUsersManage)
+	manager, token := a.login("manager", "manager")
+	admin := a.createUser("second", auth.RoleAdmin)
+	crew := a.createUser("crew", auth.RoleUser)
+
+	_, key, err := a.h.createAPIKey(t.Context(), manager.ID, "sync", []string{auth.ScopeAdminUsers}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	adminPath := apiUsersPath + "/" + strconv.Itoa(admin.ID)
+	replace := `{"username": "second", "email": "mine@example.com", "password": "` + testPassword + `x", "role": "admin"}`
+
+	for _, caller := range []struct{ name, token string }{{"токен", token}, {"API ключ", key}} {
+		tests := []struct {
+			name   string
+			method string
+			body   any
+		}{
+			{"PATCH email", http.MethodPatch, `{"email": "mine@example.com"}`},
+			{"PATCH пароль", http.MethodPatch, `{"password": "` + testPassword + `x"}`},
+			{"PUT", http.MethodPut, replace},
+			{"DELETE", http.MethodDelete, nil},
+		}
+		for _, tt := range tests {
+			t.Run(caller.name+"/"+tt.name, func(t *testing.T) {
+				if rec := a.do(caller.token, tt.method, adminPath, tt.body); rec.Code != http.StatusForbidden {
+					t.Errorf("статус %d, want 403: %s", rec.Code, rec.Body)
+				}
+			})
+		}
+	}
+
+	stored, err := a.mem.Users().Get(t.Context(), admin.ID)
+	if err != nil {
+		t.Fatalf("администратор удален: %v", err)
+	}
+	if stored.Email != admin.Email || stored.PasswordHash != admin.PasswordHash {
+		t.Error("данные администратора изменены")
+	}
+
+	// Остальных пользователей менеджер по-прежнему меняет и удаляет, но не повышает до admin
+	crewPath := apiUsersPath + "/" + strconv.Itoa(crew.ID)
+	a.expect(a.do(token, http.MethodPatch, crewPath, `{"role": "admin"}`), http.StatusForbidden, "повышение до admin")
+	a.expect(a.do(token, http.MethodPatch, crewPath, `{"email": "crew2@example.com"}`), http.StatusOK, "изменение пользователя")
+	a.expect(a.do(key, http.MethodDelete, crewPath, nil), http.StatusNoContent, "удаление пользователя")
+}
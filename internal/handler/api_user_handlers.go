@@ -0,0 +1,313 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"cosmos/internal/auth"
+	"cosmos/internal/models"
+	"cosmos/internal/repository"
+)
+
+const apiUsersPath = "/api/v1/users"
+
+// userPayload - тело запросов создания и изменения пользователя.
+// Указатели позволяют PATCH отличить отсутствующее поле от пустого.
+type userPayload struct {
+	Username *string `json:"username"`
+	Email    *string `json:"email"`
+	Password *string `json:"password"`
+	Role     *string `json:"role"`
+}
+
+// passwordChangePayload - тело запроса смены собственного пароля
+type passwordChangePayload struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// apply - перенос переданных полей поверх пользователя; возвращает новый пароль
+func (p userPayload) apply(user *models.User) string {
+	if p.Username != nil {
+		user.Username = *p.Username
+	}
+	if p.Email != nil {
+		user.Email = *p.Email
+	}
+	if p.Role != nil {
+		user.Role = *p.Role
+	}
+	if p.Password != nil {
+		return *p.Password
+	}
+	return ""
+}
+
+// apiMe - текущий пользователь по токену
+func (h *Handler) apiMe(w http.ResponseWriter, r *http.Request) {
+	claims := requestClaims(r)
+
+	user, err := h.Users.Get(r.Context(), claims.UserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			writeAPIError(w, http.StatusUnauthorized, "Пользователь токена не найден")
+			return
+		}
+		writeDBError(w, r, err, "получение текущего пользователя")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, user)
+}
+
+// apiChangeMyPassword - смена собственного пароля
+func (h *Handler) apiChangeMyPassword(w http.ResponseWriter, r *http.Request) {
+	claims := requestClaims(r)
+
+	if claims.APIKeyID != 0 {
+		writeAPIError(w, http.StatusForbidden, "Пароль нельзя сменить по API ключу")
+		return
+	}
+
+	var payload passwordChangePayload
+	if err := decodeJSON(w, r, &payload); err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ok, err := h.verifyUserPassword(r.Context(), claims.UserID, payload.CurrentPassword)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			writeAPIError(w, http.StatusUnauthorized, "Пользователь токена не найден")
+			return
+		}
+		writeDBError(w, r, err, "получение пароля")
+		return
+	}
+
+	if !ok {
+		writeAPIError(w, http.StatusForbidden, "Текущий пароль указан неверно")
+		return
+	}
+
+	if err := h.validatePassword(claims.Username, payload.NewPassword, true); err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	if err := h.setUserPassword(r.Context(), claims.UserID, payload.NewPassword); err != nil {
+		writeDBError(w, r, err, "смена пароля")
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Пароль изменен через API", "user_id", claims.UserID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) apiListUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := h.Users.List(r.Context())
+	if err != nil {
+		writeDBError(w, r, err, "список пользователей")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, users)
+}
+
+func (h *Handler) apiGetUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiPathID(w, r, "Пользователь не найден")
+	if !ok {
+		return
+	}
+
+	user, err := h.Users.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			writeAPIError(w, http.StatusNotFound, "Пользователь не найден")
+			return
+		}
+		writeDBError(w, r, err, "получение пользователя")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, user)
+}
+
+func (h *Handler) apiCreateUser(w http.ResponseWriter, r *http.Request) {
+	var payload userPayload
+	if err := decodeJSON(w, r, &payload); err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var user models.User
+	password := payload.apply(&user)
+
+	if err := h.validateUserFields(r.Context(), user.Username, user.Email, password, user.Role, true); err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	if !apiCheckRoleChange(w, requestClaims(r), 0, auth.RoleUser, user.Role) {
+		return
+	}
+
+	if !h.apiCheckUserUnique(w, r, user, 0) {
+		return
+	}
+
+	created, err := h.createUser(r.Context(), user.Username, user.Email, password, user.Role)
+	if err != nil {
+		writeDBError(w, r, err, "создание пользователя")
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Пользователь создан через API", "user", created.Username, "id", created.ID, "role", created.Role)
+
+	w.Header().Set("Location", apiUsersPath+"/"+strconv.Itoa(created.ID))
+	writeJSON(w, http.StatusCreated, created)
+}
+
+// apiReplaceUser - PUT: логин, email и роль обязательны, пароль меняется, только если передан
+func (h *Handler) apiReplaceUser(w http.ResponseWriter, r *http.Request) {
+	h.apiUpdateUser(w, r, false)
+}
+
+// apiPatchUser - PATCH: меняются только переданные поля
+func (h *Handler) apiPatchUser(w http.ResponseWriter, r *http.Request) {
+	h.apiUpdateUser(w, r, true)
+}
+
+func (h *Handler) apiUpdateUser(w http.ResponseWriter, r *http.Request, partial bool) {
+	id, ok := apiPathID(w, r, "Пользователь не найден")
+	if !ok {
+		return
+	}
+
+	user, ok := h.apiGetManagedUser(w, r, id)
+	if !ok {
+		return
+	}
+
+	var payload userPayload
+	if err := decodeJSON(w, r, &payload); err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	oldRole := user.Role
+	if !partial {
+		user = models.User{ID: user.ID, CreatedAt: user.CreatedAt}
+	}
+	password := payload.apply(&user)
+
+	if payload.Password != nil && password == "" {
+		writeAPIError(w, http.StatusUnprocessableEntity, "Пароль не может быть пустым")
+		return
+	}
+
+	if err := h.validateUserFields(r.Context(), user.Username, user.Email, password, user.Role, false); err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	if !apiCheckRoleChange(w, requestClaims(r), id, oldRole, user.Role) {
+		return
+	}
+
+	if !h.apiCheckUserUnique(w, r, user, id) {
+		return
+	}
+
+	if err := h.updateUser(r.Context(), id, user.Username, user.Email, user.Role, password); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			writeAPIError(w, http.StatusNotFound, "Пользователь не найден")
+			return
+		}
+		writeDBError(w, r, err, "обновление пользователя")
+		return
+	}
+
+	// В ответе - сохраненные данные, а не тело запроса
+	updated, err := h.Users.Get(r.Context(), id)
+	if err != nil {
+		writeDBError(w, r, err, "получение обновленного пользователя")
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Пользователь обновлен через API", "id", id)
+	writeJSON(w, http.StatusOK, updated)
+}
+
+// apiCheckRoleChange - 403, если вызывающий не может выдать роль newRole
+// пользователю targetID (0 - новому), см. checkRoleChange
+func apiCheckRoleChange(w http.ResponseWriter, claims *auth.Claims, targetID int, oldRole, newRole string) bool {
+	if err := checkRoleChange(claims, targetID, oldRole, newRole); err != nil {
+		writeAPIError(w, http.StatusForbidden, err.Error())
+		return false
+	}
+	return true
+}
+
+// apiGetManagedUser - пользователь id, которого вызывающий может изменять и удалять;
+// 404, если его нет, и 403, если это администратор, а вызывающий - нет
+func (h *Handler) apiGetManagedUser(w http.ResponseWriter, r *http.Request, id int) (models.User, bool) {
+	user, err := h.Users.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			writeAPIError(w, http.StatusNotFound, "Пользователь не найден")
+			return user, false
+		}
+		writeDBError(w, r, err, "получение пользователя")
+		return user, false
+	}
+	if err := checkManageUser(requestClaims(r), user.Role); err != nil {
+		writeAPIError(w, http.StatusForbidden, err.Error())
+		return user, false
+	}
+	return user, true
+}
+
+func (h *Handler) apiDeleteUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiPathID(w, r, "Пользователь не найден")
+	if !ok {
+		return
+	}
+
+	// Нельзя удалить первого админа (ID=1)
+	if id == 1 {
+		writeAPIError(w, http.StatusBadRequest, "Нельзя удалить главного администратора")
+		return
+	}
+	if _, ok := h.apiGetManagedUser(w, r, id); !ok {
+		return
+	}
+
+	if err := h.Users.Delete(r.Context(), id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			writeAPIError(w, http.StatusNotFound, "Пользователь не найден")
+			return
+		}
+		writeDBError(w, r, err, "удаление пользователя")
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Пользователь удален через API", "id", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiCheckUserUnique - 409, если логин или email заняты другим пользователем
+func (h *Handler) apiCheckUserUnique(w http.ResponseWriter, r *http.Request, user models.User, excludeID int) bool {
+	exists, err := h.Users.Exists(r.Context(), user.Username, user.Email, excludeID)
+	if err != nil {
+		writeDBError(w, r, err, "проверка уникальности пользователя")
+		return false
+	}
+	if exists {
+		writeAPIError(w, http.StatusConflict, "Пользователь с таким логином или email уже существует")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"cosmos/internal/auth"
+	"cosmos/internal/models"
+	"cosmos/internal/repository"
+)
+
+// apiKeyLastUsedInterval - как часто обновлять last_used_at, чтобы не писать в БД на каждый запрос
+const apiKeyLastUsedInterval = time.Minute
+
+// validateAPIKeyFields - проверка названия и scopes ключа
+func validateAPIKeyFields(name string, scopes []string) error {
+	if name == "" {
+		return errors.New("Название ключа обязательно")
+	}
+	if len(name) > 100 {
+		return errors.New("Название ключа слишком длинное")
+	}
+	if len(scopes) == 0 {
+		return errors.New("Выберите хотя бы один scope")
+	}
+	for _, scope := range scopes {
+		if !auth.ValidScope(scope) {
+			return errors.New("Неизвестный scope: " + scope)
+		}
+	}
+	return nil
+}
+
+// createAPIKey - выпуск ключа; открытый ключ возвращается один раз и нигде не сохраняется
+func (h *Handler) createAPIKey(ctx context.Context, userID int, name string, scopes []string, expiresAt *time.Time) (models.APIKey, string, error) {
+	key, hash, prefix, err := auth.NewAPIKey()
+	if err != nil {
+		return models.APIKey{}, "", err
+	}
+
+	apiKey := models.APIKey{UserID: userID, Name: name, Prefix: prefix, Scopes: scopes, ExpiresAt: expiresAt}
+	if err := h.APIKeys.Create(ctx, &apiKey, hash); err != nil {
+		return models.APIKey{}, "", err
+	}
+	return apiKey, key, nil
+}
+
+// authenticateAPIKey - владелец и scopes действующего API ключа
+func (h *Handler) authenticateAPIKey(ctx context.Context, key string) (*auth.Claims, error) {
+	apiKey, role, err := h.APIKeys.GetByHash(ctx, auth.HashToken(key))
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, errSessionInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := h.APIKeys.Touch(ctx, apiKey.ID, apiKeyLastUsedInterval); err != nil {
+		slog.ErrorContext(ctx, "Ошибка обновления last_used_at API ключа", "api_key_id", apiKey.ID, "err", err)
+	}
+
+	return &auth.Claims{
+		Username: apiKey.Username,
+		Role:     role,
+		UserID:   apiKey.UserID,
+		APIKeyID: apiKey.ID,
+		Scopes:   apiKey.Scopes,
+	}, nil
+}
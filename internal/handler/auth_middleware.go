@@ -1,32 +1,258 @@
 package handler
 
 import (
+	"context"
 	"errors"
-	"log"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"strings"
+	"sync"
 
 	"cosmos/internal/auth"
+	"cosmos/internal/logging"
+	"cosmos/internal/models"
+	"cosmos/internal/router"
 )
 
-// requireAdminAuth - middleware для проверки авторизации админа
-func (h *Handler) requireAdminAuth(w http.ResponseWriter, r *http.Request) (*auth.Claims, error) {
-	token := auth.GetTokenFromRequest(r)
-	if token == "" {
-		http.Redirect(w, r, "/admin/login", http.StatusFound)
-		return nil, errors.New("не авторизован")
+// claimsKey - ключ контекста с пользователем, которого пропустил RequirePermission или RequireUser
+type claimsKey struct{}
+
+// RequirePermission - middleware страниц: пропускает только вошедших пользователей,
+// чья роль имеет право permission; гостя отправляет на страницу входа
+func (h *Handler) RequirePermission(permission string) router.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, err := h.requirePermission(w, r, permission)
+			if err != nil {
+				return
+			}
+			next.ServeHTTP(w, withClaims(r, claims))
+		})
 	}
+}
+
+// RequireUser - middleware страниц: пропускает любого вошедшего пользователя
+func (h *Handler) RequireUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, err := h.requireUserAuth(w, r)
+		if err != nil {
+			return
+		}
+		next.ServeHTTP(w, withClaims(r, claims))
+	})
+}
+
+func withClaims(r *http.Request, claims *auth.Claims) *http.Request {
+	logging.SetUserID(r.Context(), claims.UserID)
+	return r.WithContext(context.WithValue(r.Context(), claimsKey{}, claims))
+}
+
+// requestClaims - пользователь, проверенный middleware маршрута. Вызывается только
+// из обработчиков за RequirePermission, RequireUser или API middleware.
+func requestClaims(r *http.Request) *auth.Claims {
+	return r.Context().Value(claimsKey{}).(*auth.Claims)
+}
 
-	claims, err := auth.ValidateToken(token)
+// requirePermission - проверка, что пользователь вошел и его роль имеет право permission
+func (h *Handler) requirePermission(w http.ResponseWriter, r *http.Request, permission string) (*auth.Claims, error) {
+	claims, err := h.authenticate(r)
 	if err != nil {
 		http.Redirect(w, r, "/admin/login", http.StatusFound)
 		return nil, err
 	}
 
-	if claims.Role != "admin" {
+	ok, err := h.Roles.HasPermission(r.Context(), claims.Role, permission)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка проверки прав", "err", err)
+		http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+		return nil, err
+	}
+	if !ok {
+		slog.WarnContext(r.Context(), "Нет права", "permission", permission, "user", claims.Username, "role", claims.Role)
 		http.Error(w, "Доступ запрещен", http.StatusForbidden)
-		return nil, errors.New("не админ")
+		return nil, errors.New("нет права " + permission)
+	}
+
+	return claims, nil
+}
+
+// Cookie с токенами: короткоживущий JWT доступа и refresh токен сессии
+const (
+	authCookieName    = "auth_token"
+	refreshCookieName = "refresh_token"
+)
+
+// errNotAuthenticated - в запросе нет действующего токена
+var errNotAuthenticated = errors.New("не авторизован")
+
+// sessionState - результат проверки авторизации, общий для всех проверок одного запроса
+type sessionState struct {
+	w      http.ResponseWriter
+	once   sync.Once
+	claims *auth.Claims
+	err    error
+}
+
+type sessionStateKey struct{}
+
+// WithSession - middleware, позволяющее прозрачно обновлять просроченный токен доступа
+// по refresh cookie. Проверка выполняется лениво, при первом обращении обработчика
+// к авторизации, и один раз за запрос: иначе вторая проверка предъявила бы уже
+// замененный refresh токен.
+func (h *Handler) WithSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		state := &sessionState{w: w}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), sessionStateKey{}, state)))
+	})
+}
+
+// authenticate - пользователь запроса с проверкой серверной сессии
+func (h *Handler) authenticate(r *http.Request) (*auth.Claims, error) {
+	state, ok := r.Context().Value(sessionStateKey{}).(*sessionState)
+	if !ok {
+		// Без WithSession проверяем только токен доступа
+		return h.resolveSession(nil, r)
+	}
+
+	state.once.Do(func() {
+		state.claims, state.err = h.resolveSession(state.w, r)
+		if state.err == nil {
+			logging.SetUserID(r.Context(), state.claims.UserID)
+		}
+	})
+	return state.claims, state.err
+}
+
+// resolveSession - проверка токена доступа и сессии; если токен истек, а w задан,
+// сессия продлевается по refresh cookie с выдачей новых cookie
+func (h *Handler) resolveSession(w http.ResponseWriter, r *http.Request) (*auth.Claims, error) {
+	if token := auth.GetTokenFromRequest(r); token != "" {
+		if claims, err := auth.ValidateToken(token); err == nil {
+			valid, err := h.sessionValid(r.Context(), claims)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Ошибка проверки сессии", "err", err)
+				return nil, err
+			}
+			if valid {
+				return claims, nil
+			}
+		}
+
+		// Bearer токен API клиент обновляет сам через /api/v1/auth/refresh
+		if _, err := r.Cookie(authCookieName); err != nil {
+			return nil, errSessionInvalid
+		}
+	}
+
+	refresh, err := r.Cookie(refreshCookieName)
+	if w == nil || err != nil || refresh.Value == "" {
+		return nil, errNotAuthenticated
+	}
+
+	claims, accessToken, refreshToken, err := h.rotateSession(refresh.Value, r)
+	if err != nil {
+		switch {
+		case errors.Is(err, errSessionRace):
+			// Cookie уже обновил параллельный запрос - не трогаем их
+		case errors.Is(err, errSessionInvalid):
+			h.clearAuthCookie(w)
+		default:
+			slog.ErrorContext(r.Context(), "Ошибка обновления сессии", "err", err)
+		}
+		return nil, err
 	}
 
-	log.Printf("✅ Авторизован: %s (роль: %s)", claims.Username, claims.Role)
+	h.setAuthCookie(w, accessToken, refreshToken)
 	return claims, nil
 }
+
+// startSession - создание сессии при входе и сохранение токенов в cookie
+func (h *Handler) startSession(w http.ResponseWriter, r *http.Request, user models.User) error {
+	accessToken, refreshToken, err := h.openSession(user, r)
+	if err != nil {
+		return err
+	}
+	h.setAuthCookie(w, accessToken, refreshToken)
+	return nil
+}
+
+// endSession - отзыв сессии текущего запроса и удаление cookie
+func (h *Handler) endSession(w http.ResponseWriter, r *http.Request) {
+	// Токен доступа мог истечь, поэтому сессию ищем и по нему, и по refresh cookie
+	if token := auth.GetTokenFromRequest(r); token != "" {
+		if claims, err := auth.ValidateToken(token); err == nil && claims.SessionID != 0 {
+			if err := h.Sessions.Revoke(r.Context(), claims.SessionID); err != nil {
+				slog.ErrorContext(r.Context(), "Ошибка отзыва сессии", "err", err)
+			}
+		}
+	}
+	if refresh, err := r.Cookie(refreshCookieName); err == nil && refresh.Value != "" {
+		if err := h.Sessions.RevokeByToken(r.Context(), auth.HashToken(refresh.Value)); err != nil {
+			slog.ErrorContext(r.Context(), "Ошибка отзыва сессии", "err", err)
+		}
+	}
+
+	h.clearAuthCookie(w)
+}
+
+// currentViewer - пользователь из токена запроса или nil для гостя
+func (h *Handler) currentViewer(r *http.Request) *models.Viewer {
+	claims, err := h.authenticate(r)
+	if err != nil {
+		return nil
+	}
+
+	permissions, err := h.Roles.Permissions(r.Context(), claims.Role)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка получения прав роли", "role", claims.Role, "err", err)
+	}
+
+	return &models.Viewer{ID: claims.UserID, Username: claims.Username, Role: claims.Role, Permissions: permissions}
+}
+
+// requireUserAuth - проверка, что пользователь (любой роли) вошел в систему
+func (h *Handler) requireUserAuth(w http.ResponseWriter, r *http.Request) (*auth.Claims, error) {
+	claims, err := h.authenticate(r)
+	if err == nil {
+		return claims, nil
+	}
+
+	http.Redirect(w, r, "/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusFound)
+	return nil, err
+}
+
+// newCookie - HttpOnly cookie с настройками Secure и SameSite из конфигурации.
+// maxAge < 0 удаляет cookie.
+func (h *Handler) newCookie(name, value, path string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		HttpOnly: true,
+		Secure:   h.CookieSecure,
+		SameSite: h.CookieSameSite,
+		MaxAge:   maxAge,
+	}
+}
+
+// setAuthCookie - сохранение токена доступа и refresh токена в cookie
+func (h *Handler) setAuthCookie(w http.ResponseWriter, accessToken, refreshToken string) {
+	http.SetCookie(w, h.newCookie(authCookieName, accessToken, "/", int(auth.AccessTokenTTL.Seconds())))
+	http.SetCookie(w, h.newCookie(refreshCookieName, refreshToken, "/", int(auth.RefreshTokenTTL.Seconds())))
+}
+
+// clearAuthCookie - удаление cookie с токенами
+func (h *Handler) clearAuthCookie(w http.ResponseWriter) {
+	for _, name := range []string{authCookieName, refreshCookieName} {
+		http.SetCookie(w, h.newCookie(name, "", "/", -1))
+	}
+}
+
+// safeRedirectTarget - локальный путь для редиректа после входа (защита от open redirect)
+func safeRedirectTarget(next, fallback string) string {
+	if next == "" || !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return fallback
+	}
+	return next
+}
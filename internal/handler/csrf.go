@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"cosmos/internal/auth"
+)
+
+// CSRF защита по схеме double-submit: случайный токен лежит в cookie и он же
+// передается в скрытом поле формы (или в заголовке X-CSRF-Token). Чужой сайт может
+// заставить браузер отправить cookie, но прочитать ее и подставить в форму не может.
+const (
+	csrfCookieName = "csrf_token"
+	csrfFieldName  = "csrf_token"
+	csrfHeaderName = "X-CSRF-Token"
+)
+
+// csrfTokenLength - длина токена в base64url (32 случайных байта)
+const csrfTokenLength = 43
+
+type csrfTokenKey struct{}
+
+// WithCSRF - middleware: выдает CSRF токен и проверяет его во всех запросах,
+// меняющих данные (кроме GET, HEAD, OPTIONS)
+func (h *Handler) WithCSRF(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		isAPI := strings.HasPrefix(r.URL.Path, "/api/")
+
+		token := ""
+		if cookie, err := r.Cookie(csrfCookieName); err == nil && len(cookie.Value) == csrfTokenLength {
+			token = cookie.Value
+		}
+
+		if !csrfSafeMethod(r.Method) && csrfRequired(r) {
+			sent := r.Header.Get(csrfHeaderName)
+			if sent == "" && !isAPI {
+				sent = r.PostFormValue(csrfFieldName)
+			}
+
+			if token == "" || subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+				slog.WarnContext(r.Context(), "CSRF токен не совпал", "method", r.Method, "path", r.URL.Path, "ip", clientIP(r))
+				if isAPI {
+					writeAPIError(w, http.StatusForbidden, "Недействительный CSRF токен: передайте заголовок "+csrfHeaderName)
+				} else {
+					http.Error(w, "Недействительный CSRF токен. Обновите страницу и отправьте форму еще раз", http.StatusForbidden)
+				}
+				return
+			}
+		}
+
+		// Клиентам API, которые не работают с cookie, токен не выдается
+		if token == "" && !isAPI {
+			token = newCSRFToken()
+			http.SetCookie(w, h.newCookie(csrfCookieName, token, "/", int(auth.RefreshTokenTTL.Seconds())))
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), csrfTokenKey{}, token)))
+	})
+}
+
+// csrfToken - токен запроса для скрытого поля формы
+func csrfToken(r *http.Request) string {
+	token, _ := r.Context().Value(csrfTokenKey{}).(string)
+	return token
+}
+
+func csrfSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// csrfRequired - нужна ли проверка. Формы сайта проверяются всегда. JSON API - только
+// если клиент авторизуется cookie: заголовки Authorization и X-API-Key чужой сайт
+// подставить не может.
+func csrfRequired(r *http.Request) bool {
+	if !strings.HasPrefix(r.URL.Path, "/api/") {
+		return true
+	}
+	if r.Header.Get("Authorization") != "" || r.Header.Get("X-API-Key") != "" {
+		return false
+	}
+	for _, name := range []string{authCookieName, refreshCookieName} {
+		if _, err := r.Cookie(name); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func newCSRFToken() string {
+	buf := make([]byte, 32)
+	rand.Read(buf)
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
@@ -0,0 +1,239 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"cosmos/internal/auth"
+	"cosmos/internal/mail"
+	"cosmos/internal/models"
+	"cosmos/internal/repository"
+)
+
+// actionLink - ссылка из письма с токеном
+func (h *Handler) actionLink(path, token string) string {
+	return strings.TrimRight(h.BaseURL, "/") + path + "?" + url.Values{"token": {token}}.Encode()
+}
+
+// sendPasswordResetMail - письмо со ссылкой для сброса пароля
+func (h *Handler) sendPasswordResetMail(ctx context.Context, user models.User) error {
+	token, err := h.issueActionToken(ctx, user, auth.PurposePasswordReset, auth.PasswordResetTTL)
+	if err != nil {
+		return err
+	}
+
+	return h.Mailer.Send(mail.Message{
+		To:      user.Email,
+		Subject: "Сброс пароля в Cosmos Explorer",
+		Body: fmt.Sprintf(`Здравствуйте, %s!
+
+Кто-то (возможно, вы) запросил сброс пароля в Cosmos Explorer.
+Чтобы задать новый пароль, перейдите по ссылке:
+
+%s
+
+Ссылка действует %d мин. и только один раз.
+Если вы не запрашивали сброс, просто проигнорируйте это письмо.
+`, user.Username, h.actionLink("/reset-password", token), int(auth.PasswordResetTTL.Minutes())),
+	})
+}
+
+// sendVerificationMail - письмо со ссылкой для подтверждения email
+func (h *Handler) sendVerificationMail(ctx context.Context, user models.User) error {
+	token, err := h.issueActionToken(ctx, user, auth.PurposeVerifyEmail, auth.VerifyEmailTTL)
+	if err != nil {
+		return err
+	}
+
+	return h.Mailer.Send(mail.Message{
+		To:      user.Email,
+		Subject: "Подтверждение email в Cosmos Explorer",
+		Body: fmt.Sprintf(`Здравствуйте, %s!
+
+Подтвердите адрес %s, перейдя по ссылке:
+
+%s
+
+Ссылка действует %d ч.
+`, user.Username, user.Email, h.actionLink("/verify-email", token), int(auth.VerifyEmailTTL.Hours())),
+	})
+}
+
+// ForgotPasswordHandler - запрос письма для сброса пароля. Ответ одинаковый
+// независимо от того, зарегистрирован ли адрес.
+func (h *Handler) ForgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	type ForgotPasswordPageData struct {
+		models.PageData
+		Email string
+	}
+
+	data := ForgotPasswordPageData{
+		PageData: models.PageData{
+			Title:       "Восстановление пароля",
+			CurrentPage: "forgot_password",
+		},
+	}
+
+	if r.Method == http.MethodPost {
+		data.Email = strings.TrimSpace(r.FormValue("email"))
+
+		if data.Email == "" {
+			data.Error = "Укажите email"
+		} else {
+			// Поиск и отправка - в фоне: иначе по времени ответа было бы видно,
+			// зарегистрирован ли адрес (письмо уходит только существующим)
+			email := data.Email
+			h.runBackground(r.Context(), func(ctx context.Context) {
+				h.sendPasswordResetByEmail(ctx, email)
+			})
+
+			data.Success = "Если адрес зарегистрирован, на него отправлено письмо со ссылкой для сброса пароля"
+		}
+	}
+
+	h.render(w, r, &data)
+}
+
+// sendPasswordResetByEmail - письмо сброса, если адрес зарегистрирован и письмо
+// не отправлялось в последнюю минуту. Ошибки только пишутся в лог: клиент
+// получает одинаковый ответ в любом случае.
+func (h *Handler) sendPasswordResetByEmail(ctx context.Context, email string) {
+	user, err := h.Users.GetByEmail(ctx, email)
+	if err != nil {
+		if !errors.Is(err, repository.ErrNotFound) {
+			slog.ErrorContext(ctx, "Ошибка поиска пользователя по email", "err", err)
+		}
+		return
+	}
+
+	if recent, err := h.actionMailRecentlySent(ctx, user.ID, auth.PurposePasswordReset); err != nil {
+		slog.ErrorContext(ctx, "Ошибка проверки писем сброса", "err", err)
+	} else if recent {
+		slog.InfoContext(ctx, "Письмо сброса пароля уже отправлено недавно", "user", user.Username)
+	} else if err := h.sendPasswordResetMail(ctx, user); err != nil {
+		slog.ErrorContext(ctx, "Ошибка отправки письма сброса пароля", "err", err)
+	} else {
+		slog.InfoContext(ctx, "Отправлено письмо сброса пароля", "user", user.Username)
+	}
+}
+
+// ResetPasswordHandler - новый пароль по ссылке из письма
+func (h *Handler) ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	type ResetPasswordPageData struct {
+		models.PageData
+		Token string
+		Valid bool
+	}
+
+	data := ResetPasswordPageData{
+		PageData: models.PageData{
+			Title:       "Новый пароль",
+			CurrentPage: "reset_password",
+		},
+		Token: r.FormValue("token"),
+	}
+
+	claims, err := h.actionTokenClaims(r.Context(), data.Token, auth.PurposePasswordReset)
+	if err != nil {
+		if !errors.Is(err, errActionTokenInvalid) {
+			slog.ErrorContext(r.Context(), "Ошибка проверки токена сброса пароля", "err", err)
+		}
+		data.Error = "Ссылка недействительна или устарела. Запросите новую."
+		h.render(w, r, &data)
+		return
+	}
+	data.Valid = true
+
+	if r.Method == http.MethodPost {
+		password := r.FormValue("password")
+
+		user, err := h.Users.Get(r.Context(), claims.UserID())
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Ошибка получения пользователя для сброса пароля", "err", err)
+			data.Error = "Ошибка сервера"
+		} else if err := h.validatePassword(user.Username, password, true); err != nil {
+			data.Error = err.Error()
+		} else if password != r.FormValue("password_confirm") {
+			data.Error = "Пароли не совпадают"
+		} else if err := h.resetPassword(r.Context(), claims, password); err != nil {
+			if errors.Is(err, errActionTokenInvalid) {
+				data.Error = "Ссылка уже использована. Запросите новую."
+				data.Valid = false
+			} else {
+				slog.ErrorContext(r.Context(), "Ошибка сброса пароля", "err", err)
+				data.Error = "Ошибка сохранения в базу данных"
+			}
+		} else {
+			// Тот, кто знал старый пароль, больше не должен оставаться в системе
+			revoked, err := h.Sessions.RevokeUser(r.Context(), claims.UserID())
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Ошибка отзыва сессий", "err", err)
+			}
+			h.resetLoginFailures(r.Context(), user.Username)
+			slog.InfoContext(r.Context(), "Пароль сброшен по ссылке", "target_user_id", claims.UserID(), "revoked_sessions", revoked)
+
+			data.Valid = false
+			data.Success = "Пароль изменен. Теперь можно войти с новым паролем."
+		}
+	}
+
+	h.render(w, r, &data)
+}
+
+// VerifyEmailHandler - подтверждение email по ссылке из письма
+func (h *Handler) VerifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	data := models.PageData{
+		Title:       "Подтверждение email",
+		CurrentPage: "verify_email",
+	}
+
+	claims, err := h.actionTokenClaims(r.Context(), r.FormValue("token"), auth.PurposeVerifyEmail)
+	if err == nil {
+		err = h.verifyEmail(r.Context(), claims)
+	}
+
+	if err != nil {
+		if !errors.Is(err, errActionTokenInvalid) {
+			slog.ErrorContext(r.Context(), "Ошибка подтверждения email", "err", err)
+			data.Error = "Ошибка сервера"
+		} else {
+			data.Error = "Ссылка недействительна или устарела. Отправьте письмо повторно из профиля."
+		}
+	} else {
+		slog.InfoContext(r.Context(), "Email подтвержден", "target_user_id", claims.UserID(), "email", claims.Email)
+		data.Success = "Адрес " + claims.Email + " подтвержден"
+	}
+
+	h.render(w, r, &data)
+}
+
+// ResendVerificationHandler - повторное письмо для подтверждения email (POST /profile/verify-email)
+func (h *Handler) ResendVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	claims := requestClaims(r)
+
+	user, err := h.Users.Get(r.Context(), claims.UserID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка получения профиля", "err", err)
+		http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+		return
+	}
+
+	query := url.Values{}
+	if user.EmailVerified {
+		query.Set("success", "Адрес уже подтвержден")
+	} else if recent, err := h.actionMailRecentlySent(r.Context(), user.ID, auth.PurposeVerifyEmail); err != nil || recent {
+		query.Set("error", "Письмо уже отправлено, повторить можно через минуту")
+	} else if err := h.sendVerificationMail(r.Context(), user); err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка отправки письма подтверждения", "err", err)
+		query.Set("error", "Не удалось отправить письмо, попробуйте позже")
+	} else {
+		query.Set("success", "Письмо отправлено на "+user.Email)
+	}
+
+	http.Redirect(w, r, "/profile?"+query.Encode(), http.StatusFound)
+}
@@ -1,66 +1,26 @@
 package handler
 
 import (
-	"database/sql"
 	"errors"
-	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
-	"strings"
 
 	"cosmos/internal/models"
+	"cosmos/internal/repository"
 )
 
 // AdminGalaxiesHandler - список галактик в админке
 func (h *Handler) AdminGalaxiesHandler(w http.ResponseWriter, r *http.Request) {
 	h.setEncoding(w)
 
-	// Проверяем авторизацию
-	_, err := h.requireAdminAuth(w, r)
-	if err != nil {
-		return
-	}
-
 	// Получаем галактики из БД
-	rows, err := h.DB.Query(`
-        SELECT id, name, type, diameter_ly, discovered_year
-        FROM galaxies
-        ORDER BY id DESC
-    `)
+	galaxies, err := h.Galaxies.List(r.Context(), repository.NewestFirst)
 	if err != nil {
-		log.Printf("❌ Ошибка SQL запроса галактик (админка): %v", err)
+		slog.ErrorContext(r.Context(), "Ошибка SQL запроса галактик (админка)", "err", err)
 		http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
-
-	var galaxies []models.Galaxy
-	for rows.Next() {
-		var g models.Galaxy
-		var diameterLy sql.NullFloat64
-		var discoveredYear sql.NullInt64
-
-		err := rows.Scan(&g.ID, &g.Name, &g.Type, &diameterLy, &discoveredYear)
-		if err != nil {
-			log.Printf("❌ Ошибка сканирования галактики (админка): %v", err)
-			continue
-		}
-
-		if diameterLy.Valid {
-			g.DiameterLy = &diameterLy.Float64
-		}
-		if discoveredYear.Valid {
-			year := int(discoveredYear.Int64)
-			g.DiscoveredYear = &year
-		}
-
-		galaxies = append(galaxies, g)
-	}
-
-	// Получаем общее количество
-	var galaxyCount int
-	h.DB.QueryRow("SELECT COUNT(*) FROM galaxies").Scan(&galaxyCount)
 
 	// Получаем сообщение об успехе из URL параметра
 	success := r.URL.Query().Get("success")
@@ -69,28 +29,18 @@ func (h *Handler) AdminGalaxiesHandler(w http.ResponseWriter, r *http.Request) {
 		Title:       "Управление галактиками",
 		CurrentPage: "admin_galaxies",
 		Galaxies:    galaxies,
-		GalaxyCount: galaxyCount,
+		GalaxyCount: len(galaxies),
 		IsAdmin:     true,
 		Success:     success,
 	}
 
-	err = h.Tmpl.ExecuteTemplate(w, "base.html", data)
-	if err != nil {
-		log.Printf("❌ Ошибка выполнения шаблона admin_galaxies: %v", err)
-		http.Error(w, "Ошибка отображения страницы", http.StatusInternalServerError)
-	}
+	h.render(w, r, &data)
 }
 
 // AdminNewGalaxyHandler - форма создания новой галактики
 func (h *Handler) AdminNewGalaxyHandler(w http.ResponseWriter, r *http.Request) {
 	h.setEncoding(w)
 
-	// Проверяем авторизацию
-	_, err := h.requireAdminAuth(w, r)
-	if err != nil {
-		return
-	}
-
 	// Создаем структуру для данных формы
 	type FormData struct {
 		models.PageData
@@ -115,7 +65,7 @@ func (h *Handler) AdminNewGalaxyHandler(w http.ResponseWriter, r *http.Request)
 			data.Galaxy = galaxy
 		} else {
 			// Сохраняем в БД
-			err = h.saveGalaxy(&galaxy)
+			err = h.Galaxies.Create(r.Context(), &galaxy)
 			if err != nil {
 				data.Error = "Ошибка сохранения в базу данных"
 				data.Galaxy = galaxy
@@ -126,34 +76,15 @@ func (h *Handler) AdminNewGalaxyHandler(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
-	err = h.Tmpl.ExecuteTemplate(w, "base.html", data)
-	if err != nil {
-		log.Printf("Ошибка выполнения шаблона admin_galaxy_form: %v", err)
-		http.Error(w, "Ошибка отображения страницы", http.StatusInternalServerError)
-	}
+	h.render(w, r, &data)
 }
 
 // AdminEditGalaxyHandler - форма редактирования галактики
 func (h *Handler) AdminEditGalaxyHandler(w http.ResponseWriter, r *http.Request) {
 	h.setEncoding(w)
 
-	// Проверяем авторизацию
-	_, err := h.requireAdminAuth(w, r)
-	if err != nil {
-		return
-	}
-
-	// Извлекаем ID из URL: /admin/galaxies/edit/{id}
-	pathParts := strings.Split(r.URL.Path, "/")
-
-	if len(pathParts) != 5 {
-		http.NotFound(w, r)
-		return
-	}
-
-	id, err := strconv.Atoi(pathParts[4]) // pathParts[4] это ID
-	if err != nil {
-		http.NotFound(w, r)
+	id, ok := pathID(w, r)
+	if !ok {
 		return
 	}
 
@@ -165,48 +96,17 @@ func (h *Handler) AdminEditGalaxyHandler(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Получаем галактику из БД
-	var galaxy models.Galaxy
-	var diameterLy, massSuns, distanceFromEarthLy sql.NullFloat64
-	var discoveredYear sql.NullInt64
-
-	err = h.DB.QueryRow(`
-		SELECT id, name, type, diameter_ly, mass_suns,
-		       distance_from_earth_ly, discovered_year, description
-		FROM galaxies
-		WHERE id = $1
-	`, id).Scan(
-		&galaxy.ID, &galaxy.Name, &galaxy.Type, &diameterLy, &massSuns,
-		&distanceFromEarthLy, &discoveredYear, &galaxy.Description,
-	)
-
+	galaxy, err := h.Galaxies.Get(r.Context(), id)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, repository.ErrNotFound) {
 			http.NotFound(w, r)
 		} else {
-			log.Printf("❌ Ошибка получения галактики: %v", err)
+			slog.ErrorContext(r.Context(), "Ошибка получения галактики", "err", err)
 			http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
 		}
 		return
 	}
 
-	// Обрабатываем nullable поля
-	if diameterLy.Valid {
-		val := diameterLy.Float64
-		galaxy.DiameterLy = &val
-	}
-	if massSuns.Valid {
-		val := massSuns.Float64
-		galaxy.MassSuns = &val
-	}
-	if distanceFromEarthLy.Valid {
-		val := distanceFromEarthLy.Float64
-		galaxy.DistanceFromEarthLy = &val
-	}
-	if discoveredYear.Valid {
-		year := int(discoveredYear.Int64)
-		galaxy.DiscoveredYear = &year
-	}
-
 	data := FormData{
 		PageData: models.PageData{
 			Title:       "Редактирование галактики",
@@ -225,7 +125,7 @@ func (h *Handler) AdminEditGalaxyHandler(w http.ResponseWriter, r *http.Request)
 			data.Galaxy.ID = galaxy.ID // Сохраняем оригинальный ID
 		} else {
 			// Обновляем в БД
-			err = h.updateGalaxy(id, &updatedGalaxy)
+			err = h.Galaxies.Update(r.Context(), id, &updatedGalaxy)
 			if err != nil {
 				data.Error = "Ошибка обновления в базе данных"
 				data.Galaxy = updatedGalaxy
@@ -237,32 +137,13 @@ func (h *Handler) AdminEditGalaxyHandler(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
-	err = h.Tmpl.ExecuteTemplate(w, "base.html", data)
-	if err != nil {
-		log.Printf("Ошибка выполнения шаблона admin_galaxy_form (edit): %v", err)
-		http.Error(w, "Ошибка отображения страницы", http.StatusInternalServerError)
-	}
+	h.render(w, r, &data)
 }
 
 // AdminDeleteGalaxyHandler - удаление галактики
 func (h *Handler) AdminDeleteGalaxyHandler(w http.ResponseWriter, r *http.Request) {
-	// Проверяем авторизацию
-	_, err := h.requireAdminAuth(w, r)
-	if err != nil {
-		return
-	}
-
-	// Извлекаем ID из URL: /admin/galaxies/delete/{id}
-	pathParts := strings.Split(r.URL.Path, "/")
-
-	if len(pathParts) != 5 {
-		http.NotFound(w, r)
-		return
-	}
-
-	id, err := strconv.Atoi(pathParts[4]) // pathParts[4] это ID
-	if err != nil {
-		http.NotFound(w, r)
+	id, ok := pathID(w, r)
+	if !ok {
 		return
 	}
 
@@ -272,63 +153,58 @@ func (h *Handler) AdminDeleteGalaxyHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	// Если POST запрос - выполняем удаление
-	if r.Method != http.MethodPost {
-		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+	// POST запрос - выполняем удаление
+	// Получаем имя галактики для логирования
+	galaxy, err := h.Galaxies.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		slog.ErrorContext(r.Context(), "Ошибка получения галактики", "id", id, "err", err)
+		http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
 		return
 	}
-
-	// Получаем имя галактики для логирования
-	var galaxyName string
-	h.DB.QueryRow("SELECT name FROM galaxies WHERE id = $1", id).Scan(&galaxyName)
+	galaxyName := galaxy.Name
 
 	// Проверяем, есть ли зависимые планеты
-	var planetCount int
-	h.DB.QueryRow("SELECT COUNT(*) FROM planets WHERE galaxy_id = $1", id).Scan(&planetCount)
+	planetCount, err := h.Planets.CountByGalaxy(r.Context(), id)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка подсчета планет галактики", "id", id, "err", err)
+		http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+		return
+	}
 
 	if planetCount > 0 {
 		http.Error(w, "Нельзя удалить галактику, у которой есть планеты. Сначала удалите или переместите планеты.", http.StatusBadRequest)
 		return
 	}
 
-	// Удаляем галактику
-	result, err := h.DB.Exec("DELETE FROM galaxies WHERE id = $1", id)
-	if err != nil {
-		log.Printf("❌ Ошибка удаления галактики %d: %v", id, err)
+	// Удаляем галактику (планет у нее нет, отвязывать нечего)
+	if _, err := h.Galaxies.DeleteDetachingPlanets(r.Context(), id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		slog.ErrorContext(r.Context(), "Ошибка удаления галактики", "id", id, "err", err)
 		http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
 		return
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		http.NotFound(w, r)
-		return
-	}
-
-	log.Printf("✅ Галактика удалена: %s (ID %d)", galaxyName, id)
+	slog.InfoContext(r.Context(), "Галактика удалена", "galaxy", galaxyName, "id", id)
 
 	http.Redirect(w, r, "/admin/galaxies?success=Галактика+"+galaxyName+"+удалена", http.StatusFound)
 }
 
 // Вспомогательная функция
 func (h *Handler) showDeleteGalaxyConfirmation(w http.ResponseWriter, r *http.Request, id int) {
-	log.Printf("🔍 showDeleteGalaxyConfirmation вызван для ID: %d", id)
-
 	h.setEncoding(w)
 
 	// Получаем галактику из БД
-	var galaxy models.Galaxy
-	var diameterLy sql.NullFloat64
-
-	err := h.DB.QueryRow(`
-        SELECT id, name, type, diameter_ly, description
-        FROM galaxies
-        WHERE id = $1
-    `, id).Scan(&galaxy.ID, &galaxy.Name, &galaxy.Type, &diameterLy, &galaxy.Description)
-
+	galaxy, err := h.Galaxies.Get(r.Context(), id)
 	if err != nil {
-		log.Printf("❌ Ошибка получения галактики для удаления: %v", err)
-		if err == sql.ErrNoRows {
+		slog.ErrorContext(r.Context(), "Ошибка получения галактики для удаления", "err", err)
+		if errors.Is(err, repository.ErrNotFound) {
 			http.NotFound(w, r)
 		} else {
 			http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
@@ -336,14 +212,11 @@ func (h *Handler) showDeleteGalaxyConfirmation(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	if diameterLy.Valid {
-		val := diameterLy.Float64
-		galaxy.DiameterLy = &val
-	}
-
 	// Проверяем, есть ли планеты в этой галактике
-	var planetCount int
-	h.DB.QueryRow("SELECT COUNT(*) FROM planets WHERE galaxy_id = $1", id).Scan(&planetCount)
+	planetCount, err := h.Planets.CountByGalaxy(r.Context(), id)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка подсчета планет галактики", "id", id, "err", err)
+	}
 
 	// Структура для данных страницы подтверждения
 	type DeleteData struct {
@@ -372,30 +245,25 @@ func (h *Handler) showDeleteGalaxyConfirmation(w http.ResponseWriter, r *http.Re
 		PlanetCount: planetCount,
 	}
 
-	log.Printf("Данные для шаблона галактики: ObjectType=%s, ObjectName=%s, HasPlanets=%v",
-		data.ObjectType, data.ObjectName, data.HasPlanets)
-
-	// Пробуем выполнить шаблон
-	err = h.Tmpl.ExecuteTemplate(w, "admin_confirm_delete", data)
-	if err != nil {
-		log.Printf("❌ Ошибка выполнения шаблона admin_confirm_delete для галактики: %v", err)
-
-		// Покажем простую страницу ошибки
-		fmt.Fprintf(w, `
-            <html><body style="background:#0a0a2a;color:white;padding:50px;">
-            <h1>Ошибка загрузки шаблона</h1>
-            <p>%v</p>
-            <p>ObjectType: %s</p>
-            <p>ObjectName: %s</p>
-            <p>HasPlanets: %v</p>
-            <a href="/admin/galaxies">Назад к галактикам</a>
-            </body></html>
-        `, err, data.ObjectType, data.ObjectName, data.HasPlanets)
-	}
+	h.render(w, r, &data)
 }
 
 //Вспомогательные методы для галактик
 
+// validateGalaxy - проверка обязательных полей галактики (общая для форм и API)
+func validateGalaxy(galaxy models.Galaxy) error {
+	if galaxy.Name == "" {
+		return errors.New("название галактики обязательно")
+	}
+	if galaxy.Type == "" {
+		return errors.New("тип галактики обязателен")
+	}
+	if galaxy.Description == "" {
+		return errors.New("описание обязательно")
+	}
+	return nil
+}
+
 func (h *Handler) parseGalaxyForm(r *http.Request) (models.Galaxy, error) {
 	var galaxy models.Galaxy
 
@@ -405,14 +273,8 @@ func (h *Handler) parseGalaxyForm(r *http.Request) (models.Galaxy, error) {
 	galaxy.Description = r.FormValue("description")
 
 	// Проверяем обязательные поля
-	if galaxy.Name == "" {
-		return galaxy, errors.New("название галактики обязательно")
-	}
-	if galaxy.Type == "" {
-		return galaxy, errors.New("тип галактики обязателен")
-	}
-	if galaxy.Description == "" {
-		return galaxy, errors.New("описание обязательно")
+	if err := validateGalaxy(galaxy); err != nil {
+		return galaxy, err
 	}
 
 	// Числовые поля
@@ -442,90 +304,3 @@ func (h *Handler) parseGalaxyForm(r *http.Request) (models.Galaxy, error) {
 
 	return galaxy, nil
 }
-
-func (h *Handler) saveGalaxy(galaxy *models.Galaxy) error {
-	query := `
-		INSERT INTO galaxies (name, type, description, diameter_ly, mass_suns,
-		                     distance_from_earth_ly, discovered_year)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-		RETURNING id, created_at
-	`
-
-	var diameterLy, massSuns, distanceFromEarthLy, discoveredYear any
-
-	if galaxy.DiameterLy != nil {
-		diameterLy = *galaxy.DiameterLy
-	} else {
-		diameterLy = nil
-	}
-
-	if galaxy.MassSuns != nil {
-		massSuns = *galaxy.MassSuns
-	} else {
-		massSuns = nil
-	}
-
-	if galaxy.DistanceFromEarthLy != nil {
-		distanceFromEarthLy = *galaxy.DistanceFromEarthLy
-	} else {
-		distanceFromEarthLy = nil
-	}
-
-	if galaxy.DiscoveredYear != nil {
-		discoveredYear = *galaxy.DiscoveredYear
-	} else {
-		discoveredYear = nil
-	}
-
-	err := h.DB.QueryRow(query,
-		galaxy.Name, galaxy.Type, galaxy.Description,
-		diameterLy, massSuns, distanceFromEarthLy, discoveredYear,
-	).Scan(&galaxy.ID, &galaxy.CreatedAt)
-
-	return err
-}
-
-func (h *Handler) updateGalaxy(id int, galaxy *models.Galaxy) error {
-	query := `
-		UPDATE galaxies
-		SET name = $1, type = $2, description = $3, diameter_ly = $4,
-		    mass_suns = $5, distance_from_earth_ly = $6, discovered_year = $7,
-		    updated_at = CURRENT_TIMESTAMP
-		WHERE id = $8
-		RETURNING updated_at
-	`
-
-	var diameterLy, massSuns, distanceFromEarthLy, discoveredYear any
-
-	if galaxy.DiameterLy != nil {
-		diameterLy = *galaxy.DiameterLy
-	} else {
-		diameterLy = nil
-	}
-
-	if galaxy.MassSuns != nil {
-		massSuns = *galaxy.MassSuns
-	} else {
-		massSuns = nil
-	}
-
-	if galaxy.DistanceFromEarthLy != nil {
-		distanceFromEarthLy = *galaxy.DistanceFromEarthLy
-	} else {
-		distanceFromEarthLy = nil
-	}
-
-	if galaxy.DiscoveredYear != nil {
-		discoveredYear = *galaxy.DiscoveredYear
-	} else {
-		discoveredYear = nil
-	}
-
-	err := h.DB.QueryRow(query,
-		galaxy.Name, galaxy.Type, galaxy.Description,
-		diameterLy, massSuns, distanceFromEarthLy, discoveredYear,
-		id,
-	).Scan(&galaxy.CreatedAt)
-
-	return err
-}
@@ -1,23 +1,104 @@
 package handler
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
 	"fmt"
 	"html/template"
-	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"cosmos/internal/auth"
+	"cosmos/internal/health"
+	"cosmos/internal/mail"
+	"cosmos/internal/models"
+	"cosmos/internal/repository"
+	"cosmos/internal/tracing"
 
 	_ "github.com/lib/pq"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Handler содержит зависимости
 type Handler struct {
-	DB   *sql.DB
 	Tmpl *template.Template
+
+	TemplatesErr error           // ошибка разбора шаблонов; сайт не готов принимать запросы
+	Liveness     *health.Checker // проверки для /healthz; пустой набор - процесс жив, пока отвечает
+	Readiness    *health.Checker // проверки готовности для /readyz
+
+	Planets  repository.PlanetRepository
+	Galaxies repository.GalaxyRepository
+	Users    repository.UserRepository
+
+	Roles         repository.RoleRepository
+	Sessions      repository.SessionRepository
+	TwoFactor     repository.TOTPRepository
+	APIKeys       repository.APIKeyRepository
+	LoginThrottle repository.LoginThrottleRepository
+	ActionTokens  repository.ActionTokenRepository
+	Identities    repository.IdentityRepository
+
+	TOTP *auth.TOTP // проверка кодов 2FA; часы подменяются в тестах
+
+	LoginPolicy    LoginPolicy         // ограничения на неудачные попытки входа
+	PasswordPolicy auth.PasswordPolicy // требования к новым паролям
+
+	Mailer  mail.Mailer // отправка писем: сброс пароля, подтверждение email
+	BaseURL string      // внешний адрес сайта для ссылок в письмах
+
+	OIDC *auth.OIDCProvider // вход через OpenID Connect; nil - выключен
+
+	CookieSecure   bool          // cookie только по HTTPS
+	CookieSameSite http.SameSite // SameSite для cookie авторизации и CSRF
+
+	Security SecurityPolicy // заголовки безопасности ответов (CSP, HSTS...)
+
+	RequestTimeout time.Duration // дедлайн контекста запроса, в том числе для запросов к БД; 0 - без ограничения
+
+	background sync.WaitGroup // задачи, продолжающиеся после ответа (см. runBackground)
 }
 
-// NewHandler создает новый экземпляр Handler
-func NewHandler(db *sql.DB) *Handler {
+// backgroundTimeout - сколько может работать фоновая задача запроса (например, отправка письма)
+const backgroundTimeout = time.Minute
+
+// runBackground - выполнение fn после ответа клиенту. Контекст сохраняет значения
+// запроса (request_id, трассировку), но не отменяется вместе с ним.
+func (h *Handler) runBackground(ctx context.Context, fn func(ctx context.Context)) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), backgroundTimeout)
+	h.background.Add(1)
+	go func() {
+		defer h.background.Done()
+		defer cancel()
+		fn(ctx)
+	}()
+}
+
+// WaitBackground - ожидание фоновых задач при остановке сервера, не дольше ctx
+func (h *Handler) WaitBackground(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		h.background.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// NewHandler создает новый экземпляр Handler; secrets шифрует секреты TOTP в БД
+func NewHandler(db *sql.DB, secrets repository.SecretCipher) *Handler {
 	// Создаем карту функций для шаблонов
 	funcMap := template.FuncMap{
 		"formatNumber": func(num float64) string {
@@ -90,32 +171,135 @@ func NewHandler(db *sql.DB) *Handler {
 			}
 			return false
 		},
+		"roleLabel": roleLabel,
 	}
 
 	// Парсим шаблоны
 	tmpl := template.New("").Funcs(funcMap)
 
-	// Парсим ВСЕ HTML файлы
-	tmpl, err := tmpl.ParseGlob("templates/*.html")
-	if err != nil {
-		log.Printf("Ошибка парсинга шаблонов: %v", err)
+	// Парсим ВСЕ HTML файлы. При ошибке сервер продолжает работать,
+	// но /readyz сообщает, что он не готов
+	parsed, templatesErr := tmpl.ParseGlob("templates/*.html")
+	if templatesErr != nil {
+		slog.Error("Ошибка парсинга шаблонов", "err", templatesErr)
+	} else {
+		tmpl = parsed
 	}
 
 	// Проверяем, какие шаблоны загрузились
-	log.Printf("Загруженные шаблоны:")
+	var names []string
 	for _, t := range tmpl.Templates() {
 		if t.Name() != "" {
-			log.Printf("  - %s", t.Name())
+			names = append(names, t.Name())
 		}
 	}
+	slog.Debug("Загружены шаблоны", "templates", names)
 
-	return &Handler{
-		DB:   db,
+	h := &Handler{
 		Tmpl: tmpl,
+
+		TemplatesErr: templatesErr,
+		Liveness:     health.New(),
+		Readiness:    health.New(),
+
+		Planets:  repository.NewPostgresPlanets(db),
+		Galaxies: repository.NewPostgresGalaxies(db),
+		Users:    repository.NewPostgresUsers(db),
+
+		Roles:         repository.NewPostgresRoles(db),
+		Sessions:      repository.NewPostgresSessions(db),
+		TwoFactor:     repository.NewPostgresTOTP(db, secrets),
+		APIKeys:       repository.NewPostgresAPIKeys(db),
+		LoginThrottle: repository.NewPostgresLoginThrottle(db),
+		ActionTokens:  repository.NewPostgresActionTokens(db),
+		Identities:    repository.NewPostgresIdentities(db),
+
+		TOTP: auth.NewTOTP(auth.SystemClock),
+
+		LoginPolicy:    DefaultLoginPolicy(),
+		PasswordPolicy: auth.DefaultPasswordPolicy(),
+
+		Mailer:  &mail.WriterMailer{W: os.Stdout, From: "Cosmos Explorer <noreply@localhost>"},
+		BaseURL: "http://localhost:8080",
+
+		CookieSameSite: http.SameSiteLaxMode,
+
+		Security: DefaultSecurityPolicy(),
+
+		RequestTimeout: 20 * time.Second,
 	}
+
+	// Проверки готовности; миграции добавляются в main, где известен их источник
+	h.Readiness.Add("templates", func(context.Context) error { return h.TemplatesErr })
+	if db != nil {
+		h.Readiness.Add("database", db.PingContext)
+	}
+	return h
+}
+
+// roleLabels - подписи известных ролей; остальные роли показываются по имени
+var roleLabels = map[string]string{
+	"admin":  "👑 Администратор",
+	"editor": "✏️ Редактор",
+	"viewer": "👁️ Наблюдатель",
+	"user":   "👤 Пользователь",
+}
+
+func roleLabel(role string) string {
+	if label, ok := roleLabels[role]; ok {
+		return label
+	}
+	return role
 }
 
 // setEncoding устанавливает правильную кодировку
 func (h *Handler) setEncoding(w http.ResponseWriter) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 }
+
+// pageProvider - данные шаблона со встроенной models.PageData
+type pageProvider interface {
+	Page() *models.PageData
+}
+
+// render - отрисовка base.html с данными страницы.
+// Добавляет информацию о вошедшем пользователе и отдает ответ только после
+// успешного выполнения шаблона, чтобы ошибка не обрывала страницу на середине.
+func (h *Handler) render(w http.ResponseWriter, r *http.Request, data any) {
+	pageName := "base.html"
+	if page, ok := data.(pageProvider); ok {
+		page.Page().Viewer = h.currentViewer(r)
+		page.Page().CSRFToken = csrfToken(r)
+		page.Page().CSPNonce = cspNonce(r)
+		page.Page().PasswordMinLength = h.PasswordPolicy.MinLength
+		pageName = page.Page().CurrentPage
+	}
+
+	_, span := tracing.Tracer().Start(r.Context(), "template base.html",
+		trace.WithAttributes(attribute.String("template.page", pageName)))
+	var buf bytes.Buffer
+	err := h.Tmpl.ExecuteTemplate(&buf, "base.html", data)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "ошибка выполнения шаблона")
+	}
+	span.End()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка выполнения шаблона", "page", pageName, "err", err)
+		http.Error(w, "Ошибка отображения страницы", http.StatusInternalServerError)
+		return
+	}
+
+	h.setEncoding(w)
+	buf.WriteTo(w)
+}
+
+// pathID - числовой параметр {id} маршрута; при ошибке отвечает 404
+func pathID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id <= 0 {
+		http.NotFound(w, r)
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+
+	"cosmos/internal/buildinfo"
+	"cosmos/internal/health"
+)
+
+// HealthzHandler - GET /healthz: проверка живости для оркестратора.
+// Не зависит от БД, чтобы недоступная БД не приводила к перезапуску процесса.
+func (h *Handler) HealthzHandler(w http.ResponseWriter, r *http.Request) {
+	h.writeHealth(w, r, h.Liveness)
+}
+
+// ReadyzHandler - GET /readyz: готовность принимать запросы (БД, миграции, шаблоны).
+// 503, если хотя бы одна проверка не прошла.
+func (h *Handler) ReadyzHandler(w http.ResponseWriter, r *http.Request) {
+	h.writeHealth(w, r, h.Readiness)
+}
+
+func (h *Handler) writeHealth(w http.ResponseWriter, r *http.Request, checker *health.Checker) {
+	report := checker.Run(r.Context())
+
+	status := http.StatusOK
+	if !report.OK() {
+		status = http.StatusServiceUnavailable
+		for name, result := range report.Checks {
+			if result.Status != health.StatusOK {
+				slog.WarnContext(r.Context(), "Проверка не прошла", "path", r.URL.Path, "check", name, "err", result.Error)
+			}
+		}
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, status, report)
+}
+
+// VersionHandler - GET /version: версия и коммит сборки
+func (h *Handler) VersionHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, buildinfo.Get())
+}
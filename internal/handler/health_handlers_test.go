@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"cosmos/internal/health"
+)
+
+// /readyz открыт без авторизации: в ответе только статус и время проверок, без текста ошибок
+func TestReadyzHidesCheckErrors(t *testing.T) {
+	h, _ := newMemoryHandler(t)
+	const detail = `dial tcp db.internal:5432: password authentication failed for user "cosmos"`
+	h.Readiness.Add("database", func(context.Context) error { return errors.New(detail) })
+
+	rec := httptest.NewRecorder()
+	h.ReadyzHandler(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("статус %d, want 503", rec.Code)
+	}
+	if body := rec.Body.String(); strings.Contains(body, "db.internal") || strings.Contains(body, "error") {
+		t.Errorf("в ответе детали ошибки: %s", body)
+	}
+
+	var report health.Report
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Status != health.StatusFail || report.Checks["database"].Status != health.StatusFail {
+		t.Errorf("отчет = %+v", report)
+	}
+	if report.Checks["templates"].Status != health.StatusOK {
+		t.Errorf("templates = %+v", report.Checks["templates"])
+	}
+}
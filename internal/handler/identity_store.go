@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"cosmos/internal/auth"
+	"cosmos/internal/models"
+	"cosmos/internal/repository"
+)
+
+// ssoPasswordHash - хэш пароля пользователей, созданных при входе через SSO.
+// Это не хэш argon2id или bcrypt, поэтому auth.CheckPassword с ним всегда ложно.
+const ssoPasswordHash = "!sso"
+
+// maxUsernameLength - длина users.username
+const maxUsernameLength = 50
+
+// usernameDisallowed - символы, которые не переносятся из логина провайдера
+var usernameDisallowed = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// ssoIdentity - учетная запись провайдера для репозитория
+func ssoIdentity(identity *auth.OIDCIdentity) repository.Identity {
+	return repository.Identity{
+		Issuer:        identity.Issuer,
+		Subject:       identity.Subject,
+		Email:         identity.Email,
+		EmailVerified: identity.EmailVerified,
+	}
+}
+
+// provisionSSOUser - новый пользователь без пароля, привязанный к учетной записи провайдера
+func (h *Handler) provisionSSOUser(ctx context.Context, identity *auth.OIDCIdentity, role string) (models.User, error) {
+	username, err := h.freeUsername(ctx, ssoUsernameBase(identity))
+	if err != nil {
+		return models.User{}, err
+	}
+
+	user := models.User{Username: username, Email: identity.Email, PasswordHash: ssoPasswordHash, Role: role}
+	err = h.Identities.Provision(ctx, ssoIdentity(identity), &user)
+	return user, err
+}
+
+// syncSSOUser - обновление пользователя по данным провайдера при каждом входе:
+// время входа и, если provisioned, роль из групп (со сменой роли отзываются сессии)
+// и подтвержденный провайдером email. Роль и адрес синхронизируются только у созданных
+// через SSO: у привязанной локальной учетной записи роль назначает администратор
+// cosmos, а адрес подтверждает сам пользователь.
+func (h *Handler) syncSSOUser(ctx context.Context, user *models.User, identity *auth.OIDCIdentity, role string, provisioned bool) error {
+	if !provisioned {
+		role = user.Role
+	}
+
+	if err := h.Identities.Sync(ctx, ssoIdentity(identity), user.ID, role); err != nil {
+		return err
+	}
+
+	if user.Role != role {
+		revoked, err := h.Sessions.RevokeUser(ctx, user.ID)
+		if err != nil {
+			return fmt.Errorf("ошибка отзыва сессий: %w", err)
+		}
+		slog.InfoContext(ctx, "Роль пользователя изменена провайдером SSO",
+			"user", user.Username, "old_role", user.Role, "role", role, "revoked_sessions", revoked)
+		user.Role = role
+	}
+	if provisioned && identity.EmailVerified && identity.Email == user.Email {
+		user.EmailVerified = true
+	}
+	return nil
+}
+
+// ssoUsernameBase - желаемый логин: preferred_username или часть email до @
+func ssoUsernameBase(identity *auth.OIDCIdentity) string {
+	name := identity.Username
+	if name == "" {
+		name, _, _ = strings.Cut(identity.Email, "@")
+	}
+	name = strings.Trim(usernameDisallowed.ReplaceAllString(name, "-"), "-.")
+	if name == "" {
+		name = "sso-user"
+	}
+	if len(name) > maxUsernameLength-4 {
+		name = name[:maxUsernameLength-4]
+	}
+	return name
+}
+
+// freeUsername - base или base-2, base-3... если логин уже занят
+func (h *Handler) freeUsername(ctx context.Context, base string) (string, error) {
+	for i := 1; i <= 100; i++ {
+		username := base
+		if i > 1 {
+			username = fmt.Sprintf("%s-%d", base, i)
+		}
+
+		_, err := h.Users.GetByUsername(ctx, username)
+		if errors.Is(err, repository.ErrNotFound) {
+			return username, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("не удалось подобрать свободный логин для %q", base)
+}
@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"cosmos/internal/metrics"
+	"cosmos/internal/models"
+	"cosmos/internal/repository"
+)
+
+// LoginPolicy - ограничения на неудачные попытки входа
+type LoginPolicy struct {
+	MaxFailures   int           // неудач подряд по логину до блокировки
+	MaxIPFailures int           // неудач с одного IP до блокировки
+	Lockout       time.Duration // длительность блокировки; неудачи старше этого срока забываются
+	BaseDelay     time.Duration // пауза после первой неудачи, дальше удваивается
+	MaxDelay      time.Duration // предел паузы до блокировки
+}
+
+// DefaultLoginPolicy - 5 неудач по логину или 20 с одного IP блокируют вход на 15 минут
+func DefaultLoginPolicy() LoginPolicy {
+	return LoginPolicy{
+		MaxFailures:   5,
+		MaxIPFailures: 20,
+		Lockout:       15 * time.Minute,
+		BaseDelay:     time.Second,
+		MaxDelay:      30 * time.Second,
+	}
+}
+
+// delay - на сколько закрыть вход после failures неудач подряд
+func (p LoginPolicy) delay(failures, maxFailures int) time.Duration {
+	if failures >= maxFailures {
+		return p.Lockout
+	}
+	delay := p.BaseDelay
+	for i := 1; i < failures && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, p.MaxDelay)
+}
+
+// throttleKey - логин без учета регистра и пробелов, чтобы вариации не обходили счетчик
+func throttleKey(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+// attemptLogin - проверка логина и пароля с учетом блокировок. При блокировке,
+// неизвестном логине и неверном пароле возвращается одна и та же errInvalidCredentials.
+func (h *Handler) attemptLogin(r *http.Request, username, password string) (models.User, error) {
+	ip := clientIP(r)
+
+	blocked, err := h.loginBlocked(r.Context(), username, ip)
+	if err != nil {
+		return models.User{}, err
+	}
+	if blocked {
+		slog.WarnContext(r.Context(), "Вход заблокирован", "user", username, "ip", ip)
+		metrics.LoginAttempt(metrics.LoginBlocked)
+		return models.User{}, errInvalidCredentials
+	}
+
+	user, err := h.checkCredentials(r.Context(), username, password)
+	if errors.Is(err, errInvalidCredentials) {
+		h.recordLoginFailure(r.Context(), username, ip)
+	}
+	return user, err
+}
+
+// loginBlocked - закрыт ли сейчас вход для логина или IP
+func (h *Handler) loginBlocked(ctx context.Context, username, ip string) (bool, error) {
+	return h.LoginThrottle.Locked(ctx, throttleKey(username), ip)
+}
+
+// recordLoginFailure - учет неудачи по логину и по IP. Ошибки БД только логируются:
+// вход все равно не удался. Запись не отменяется вместе с запросом, иначе перебор
+// с обрывом соединения обходил бы счетчик.
+func (h *Handler) recordLoginFailure(ctx context.Context, username, ip string) {
+	ctx = context.WithoutCancel(ctx)
+	metrics.LoginAttempt(metrics.LoginFailure)
+
+	counters := []struct {
+		kind, key   string
+		maxFailures int
+	}{
+		{repository.ThrottleUser, throttleKey(username), h.LoginPolicy.MaxFailures},
+		{repository.ThrottleIP, ip, h.LoginPolicy.MaxIPFailures},
+	}
+
+	for _, c := range counters {
+		if c.key == "" {
+			continue
+		}
+
+		// Неудачи старше окна блокировки не считаются: счетчик начинается заново
+		failures, err := h.LoginThrottle.RecordFailure(ctx, c.kind, c.key, h.LoginPolicy.Lockout)
+		if err != nil {
+			slog.ErrorContext(ctx, "Ошибка учета неудачного входа", "err", err)
+			continue
+		}
+
+		delay := h.LoginPolicy.delay(failures, c.maxFailures)
+		if err := h.LoginThrottle.Lock(ctx, c.kind, c.key, delay); err != nil {
+			slog.ErrorContext(ctx, "Ошибка учета неудачного входа", "err", err)
+			continue
+		}
+
+		if failures >= c.maxFailures {
+			slog.WarnContext(ctx, "Вход заблокирован после неудачных попыток", "kind", c.kind, "key", c.key, "lockout", delay, "failures", failures)
+		}
+	}
+}
+
+// resetLoginFailures - сброс счетчика логина после успешного входа. Счетчик IP
+// не сбрасывается: иначе перебор чужих логинов можно чередовать со входом в свой.
+func (h *Handler) resetLoginFailures(ctx context.Context, username string) {
+	if _, err := h.LoginThrottle.Reset(ctx, repository.ThrottleUser, throttleKey(username)); err != nil {
+		slog.ErrorContext(ctx, "Ошибка сброса счетчика входов", "err", err)
+	}
+}
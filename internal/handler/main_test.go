@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"log/slog"
+	"os"
+	"testing"
+
+	"cosmos/internal/auth"
+	"cosmos/internal/repository"
+)
+
+// Шаблоны загружаются из templates/ относительно рабочего каталога, как при запуске сервера
+func TestMain(m *testing.M) {
+	if err := os.Chdir("../.."); err != nil {
+		panic(err)
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn + 1})))
+	os.Exit(m.Run())
+}
+
+// useDevKeyring - временные ключи подписи токенов на время теста
+func useDevKeyring(t *testing.T) {
+	t.Helper()
+
+	kr, err := auth.NewDevKeyring()
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := auth.CurrentKeyring()
+	auth.SetKeyring(kr)
+	t.Cleanup(func() { auth.SetKeyring(prev) })
+}
+
+// newMemoryHandler - обработчик, все хранилища которого в памяти
+func newMemoryHandler(t *testing.T) (*Handler, *repository.Memory) {
+	t.Helper()
+	useDevKeyring(t)
+
+	h := NewHandler(nil, nil)
+	if h.TemplatesErr != nil {
+		t.Fatalf("шаблоны: %v", h.TemplatesErr)
+	}
+
+	mem := repository.NewMemory()
+	h.Planets, h.Galaxies, h.Users = mem.Planets(), mem.Galaxies(), mem.Users()
+	h.Roles, h.Sessions, h.TwoFactor = mem.Roles(), mem.Sessions(), mem.TOTP()
+	h.APIKeys, h.LoginThrottle = mem.APIKeys(), mem.LoginThrottle()
+	h.ActionTokens, h.Identities = mem.ActionTokens(), mem.Identities()
+	return h, mem
+}
@@ -0,0 +1,193 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"cosmos/internal/auth"
+	"cosmos/internal/models"
+	"cosmos/internal/repository"
+)
+
+// oidcFlowCookieName - cookie с подписанным состоянием входа через OIDC (state, nonce, PKCE)
+const oidcFlowCookieName = "oidc_flow"
+
+// oidcPath - общий префикс маршрутов входа через OIDC, путь cookie состояния
+const oidcPath = "/auth/oidc"
+
+// ssoName - название провайдера для кнопки входа; пусто, если SSO выключен
+func (h *Handler) ssoName() string {
+	if h.OIDC == nil {
+		return ""
+	}
+	return h.OIDC.Name()
+}
+
+// renderSSOError - страница входа с ошибкой входа через SSO
+func (h *Handler) renderSSOError(w http.ResponseWriter, r *http.Request, next, message string) {
+	data := h.newLoginPageData(next)
+	data.Error = message
+	h.render(w, r, &data)
+}
+
+// oidcFlowCookie - cookie состояния входа. Она должна прийти при возврате с сайта
+// провайдера обычным переходом, поэтому SameSite=Strict заменяется на Lax.
+func (h *Handler) oidcFlowCookie(value string, maxAge int) *http.Cookie {
+	cookie := h.newCookie(oidcFlowCookieName, value, oidcPath, maxAge)
+	if cookie.SameSite == http.SameSiteStrictMode {
+		cookie.SameSite = http.SameSiteLaxMode
+	}
+	return cookie
+}
+
+// OIDCLoginHandler - переход к провайдеру (GET /auth/oidc/login?next=)
+func (h *Handler) OIDCLoginHandler(w http.ResponseWriter, r *http.Request) {
+	if h.OIDC == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	next := safeRedirectTarget(r.FormValue("next"), "/profile")
+
+	authURL, flowToken, err := h.OIDC.Begin(r.Context(), next)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка начала входа через SSO", "err", err)
+		h.renderSSOError(w, r, next, "Провайдер входа недоступен, попробуйте позже")
+		return
+	}
+
+	http.SetCookie(w, h.oidcFlowCookie(flowToken, int(auth.OIDCFlowTTL.Seconds())))
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// OIDCCallbackHandler - возврат от провайдера: проверка state, обмен кода с PKCE,
+// поиск или создание пользователя и роль по группам (GET /auth/oidc/callback)
+func (h *Handler) OIDCCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	if h.OIDC == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	var flow *auth.OIDCFlow
+	if cookie, err := r.Cookie(oidcFlowCookieName); err == nil {
+		flow, _ = auth.ParseFlow(cookie.Value)
+	}
+	http.SetCookie(w, h.oidcFlowCookie("", -1))
+
+	if flow == nil || r.FormValue("state") != flow.State {
+		slog.WarnContext(r.Context(), "Вход через SSO: неизвестный или устаревший state")
+		h.renderSSOError(w, r, "/profile", "Время входа истекло, попробуйте еще раз")
+		return
+	}
+	next := safeRedirectTarget(flow.Next, "/profile")
+
+	if idpErr := r.FormValue("error"); idpErr != "" {
+		slog.WarnContext(r.Context(), "Провайдер SSO отказал во входе", "error", idpErr, "description", r.FormValue("error_description"))
+		h.renderSSOError(w, r, next, "Провайдер отклонил вход")
+		return
+	}
+
+	identity, err := h.OIDC.Exchange(r.Context(), flow, r.FormValue("code"))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка входа через SSO", "err", err)
+		h.renderSSOError(w, r, next, "Не удалось подтвердить вход у провайдера")
+		return
+	}
+
+	role, ok := h.OIDC.RoleFor(identity.Groups)
+	if !ok {
+		slog.WarnContext(r.Context(), "Вход через SSO запрещен: нет ни одной из сопоставленных групп",
+			"subject", identity.Subject, "email", identity.Email, "groups", identity.Groups)
+		h.renderSSOError(w, r, next, "Для вашей учетной записи доступ к Cosmos Explorer не предусмотрен")
+		return
+	}
+	if exists, err := h.Roles.Exists(r.Context(), role); err != nil || !exists {
+		slog.ErrorContext(r.Context(), "Ошибка настройки SSO: роль не найдена", "role", role, "err", err)
+		h.renderSSOError(w, r, next, "Ошибка сервера")
+		return
+	}
+
+	user, provisioned, err := h.ssoUser(r.Context(), identity, role)
+	if err != nil {
+		if errors.Is(err, errSSOEmailTaken) {
+			slog.WarnContext(r.Context(), "Вход через SSO: email занят, а адрес не подтвержден провайдером или пользователем",
+				"email", identity.Email, "provider_verified", identity.EmailVerified)
+			h.renderSSOError(w, r, next, "Пользователь с таким email уже есть. Войдите по паролю и подтвердите адрес, чтобы входить через SSO")
+		} else if errors.Is(err, errSSONoEmail) {
+			slog.WarnContext(r.Context(), "Вход через SSO: провайдер не передал email", "subject", identity.Subject)
+			h.renderSSOError(w, r, next, "Провайдер не передал email. Разрешите доступ к адресу и попробуйте еще раз")
+		} else {
+			slog.ErrorContext(r.Context(), "Ошибка входа через SSO", "err", err)
+			h.renderSSOError(w, r, next, "Ошибка сервера")
+		}
+		return
+	}
+
+	// Привязанная локальная учетная запись проходит свой второй фактор, как при входе
+	// по паролю: иначе SSO был бы способом обойти TOTP. У созданных через SSO второй
+	// фактор проверяет провайдер.
+	if !provisioned {
+		slog.InfoContext(r.Context(), "Вход через SSO в привязанную учетную запись", "user", user.Username, "role", user.Role)
+		h.completeLogin(w, r, user, next)
+		return
+	}
+
+	if err := h.startSession(w, r, user); err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка создания сессии", "err", err)
+		http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Вход через SSO", "user", user.Username, "role", user.Role)
+	http.Redirect(w, r, next, http.StatusFound)
+}
+
+// Причины, по которым учетную запись провайдера нельзя сопоставить с пользователем
+var (
+	errSSONoEmail    = errors.New("провайдер не передал email")
+	errSSOEmailTaken = errors.New("email занят пользователем без привязки к провайдеру")
+)
+
+// ssoUser - пользователь для учетной записи провайдера. Привязанный ищется по subject;
+// существующий с тем же email привязывается, только если адрес подтвердили и провайдер,
+// и сам пользователь: иначе чужой провайдер мог бы войти в любую учетную запись, а
+// зарегистрированная на чужой адрес без подтверждения - перехватить вход владельца
+// адреса. Такому пользователю нужно войти по паролю и подтвердить адрес. Иначе
+// создается новый.
+// provisioned - пользователь создан через SSO, и роль ему задают группы провайдера.
+func (h *Handler) ssoUser(ctx context.Context, identity *auth.OIDCIdentity, role string) (user models.User, provisioned bool, err error) {
+	user, provisioned, err = h.Identities.GetUser(ctx, identity.Issuer, identity.Subject)
+	if err == nil {
+		return user, provisioned, h.syncSSOUser(ctx, &user, identity, role, provisioned)
+	}
+	if !errors.Is(err, repository.ErrNotFound) {
+		return user, false, err
+	}
+
+	if identity.Email == "" {
+		return user, false, errSSONoEmail
+	}
+
+	user, err = h.Users.GetByEmail(ctx, identity.Email)
+	switch {
+	case err == nil:
+		if !identity.EmailVerified || !user.EmailVerified {
+			return user, false, errSSOEmailTaken
+		}
+		if err := h.Identities.Link(ctx, ssoIdentity(identity), user.ID); err != nil {
+			return user, false, err
+		}
+		slog.InfoContext(ctx, "Учетная запись SSO привязана к пользователю", "subject", identity.Subject, "user", user.Username)
+		return user, false, h.syncSSOUser(ctx, &user, identity, role, false)
+
+	case errors.Is(err, repository.ErrNotFound):
+		user, err = h.provisionSSOUser(ctx, identity, role)
+		if err == nil {
+			slog.InfoContext(ctx, "Создан пользователь при входе через SSO", "user", user.Username, "id", user.ID, "role", user.Role)
+		}
+		return user, true, err
+	}
+	return user, false, err
+}
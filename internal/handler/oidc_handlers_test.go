@@ -0,0 +1,216 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"cosmos/internal/auth"
+	"cosmos/internal/auth/oidctest"
+	"cosmos/internal/repository"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// newOIDCHandler - обработчик над хранилищем в памяти с входом через тестовый провайдер
+func newOIDCHandler(t *testing.T) (*Handler, *repository.Memory, *oidctest.Provider) {
+	t.Helper()
+	h, mem := newMemoryHandler(t)
+
+	idp, err := oidctest.New("", "cosmos", "", jwt.MapClaims{"sub": "user-1", "email": "astronaut@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(idp.Handler())
+	t.Cleanup(srv.Close)
+	idp.Issuer = srv.URL
+
+	h.OIDC, err = auth.NewOIDCProvider(auth.OIDCConfig{
+		IssuerURL:   srv.URL,
+		ClientID:    "cosmos",
+		RedirectURL: "http://cosmos.test/auth/oidc/callback",
+		DefaultRole: auth.RoleUser,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h, mem, idp
+}
+
+func responseCookie(rec *httptest.ResponseRecorder, name string) *http.Cookie {
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// oidcLogin - GET /auth/oidc/login: cookie состояния, state и адрес провайдера
+func oidcLogin(t *testing.T, h *Handler) (*http.Cookie, string, *url.URL) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.OIDCLoginHandler(rec, httptest.NewRequest(http.MethodGet, "/auth/oidc/login?next=/planets", nil))
+
+	if rec.Code != http.StatusFound {
+		t.Fatalf("login: статус %d", rec.Code)
+	}
+	cookie := responseCookie(rec, oidcFlowCookieName)
+	if cookie == nil || cookie.Value == "" || !cookie.HttpOnly || cookie.Path != oidcPath {
+		t.Fatalf("cookie состояния = %+v", cookie)
+	}
+	location, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if location.Query().Get("code_challenge_method") != "S256" {
+		t.Errorf("переход к провайдеру без PKCE: %s", location)
+	}
+	return cookie, location.Query().Get("state"), location
+}
+
+// oidcSignIn - полный вход через тестовый провайдер с claims: ответ обработчика возврата
+func oidcSignIn(t *testing.T, h *Handler, idp *oidctest.Provider, claims jwt.MapClaims) *httptest.ResponseRecorder {
+	t.Helper()
+	idp.Claims = claims
+	cookie, _, location := oidcLogin(t, h)
+
+	// Провайдер сразу возвращает на адрес возврата с кодом
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(location.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || callback.Query().Get("code") == "" {
+		t.Fatalf("провайдер вернул %d, Location %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/auth/oidc/callback?"+callback.RawQuery, nil)
+	req.AddCookie(cookie)
+	rec := httptest.NewRecorder()
+	h.OIDCCallbackHandler(rec, req)
+	return rec
+}
+
+// sessionStarted - обработчик выдал cookie сессии
+func sessionStarted(rec *httptest.ResponseRecorder) bool {
+	c := responseCookie(rec, "auth_token")
+	return c != nil && c.Value != ""
+}
+
+func TestOIDCCallbackStateMismatch(t *testing.T) {
+	h, _, _ := newOIDCHandler(t)
+	cookie, state, _ := oidcLogin(t, h)
+	if state == "" {
+		t.Fatal("нет state в адресе провайдера")
+	}
+
+	tests := []struct {
+		name   string
+		cookie *http.Cookie
+		state  string
+	}{
+		{"чужой state", cookie, "forged-state"},
+		{"без cookie", nil, state},
+		{"подделанная cookie", &http.Cookie{Name: oidcFlowCookieName, Value: cookie.Value + "x"}, state},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/auth/oidc/callback?code=any&state="+url.QueryEscape(tt.state), nil)
+			if tt.cookie != nil {
+				req.AddCookie(tt.cookie)
+			}
+			rec := httptest.NewRecorder()
+			h.OIDCCallbackHandler(rec, req)
+
+			if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "Время входа истекло") {
+				t.Errorf("статус %d, ожидалась страница входа с ошибкой", rec.Code)
+			}
+			if sessionStarted(rec) {
+				t.Error("создана сессия")
+			}
+			if c := responseCookie(rec, oidcFlowCookieName); c == nil || c.MaxAge >= 0 {
+				t.Error("cookie состояния не удалена")
+			}
+		})
+	}
+}
+
+// Учетная запись, зарегистрированная на чужой адрес без подтверждения, не
+// привязывается при входе владельца адреса через SSO и не становится подтвержденной
+func TestOIDCDoesNotLinkUnverifiedLocalEmail(t *testing.T) {
+	h, mem, idp := newOIDCHandler(t)
+	ctx := t.Context()
+
+	squatter, err := h.createUser(ctx, "squatter", "victim@example.com", testPassword, auth.RoleUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if squatter.EmailVerified {
+		t.Fatal("адрес подтвержден при регистрации")
+	}
+
+	claims := jwt.MapClaims{"sub": "victim", "email": "victim@example.com", "email_verified": true}
+	rec := oidcSignIn(t, h, idp, claims)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "Войдите по паролю") {
+		t.Errorf("статус %d, ожидалась страница входа с ошибкой", rec.Code)
+	}
+	if sessionStarted(rec) {
+		t.Error("создана сессия")
+	}
+	if _, _, err := h.Identities.GetUser(ctx, idp.Issuer, "victim"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("учетная запись провайдера привязана: %v", err)
+	}
+	if user, _ := mem.Users().Get(ctx, squatter.ID); user.EmailVerified {
+		t.Error("адрес подтвержден входом через SSO")
+	}
+
+	// После подтверждения адреса владельцем вход через SSO привязывает учетную запись
+	token := repository.ActionToken{JTI: "verify-1", UserID: squatter.ID, Purpose: auth.PurposeVerifyEmail, Email: squatter.Email}
+	if err := h.ActionTokens.Issue(ctx, token, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.ActionTokens.VerifyEmail(ctx, token); err != nil {
+		t.Fatal(err)
+	}
+	rec = oidcSignIn(t, h, idp, claims)
+	if rec.Code != http.StatusFound || !sessionStarted(rec) {
+		t.Fatalf("вход после подтверждения: статус %d, сессия %v", rec.Code, sessionStarted(rec))
+	}
+	user, provisioned, err := h.Identities.GetUser(ctx, idp.Issuer, "victim")
+	if err != nil || user.ID != squatter.ID || provisioned {
+		t.Errorf("привязка = %+v, provisioned=%v, %v", user, provisioned, err)
+	}
+}
+
+// Провайдер без подтвержденного адреса не привязывается даже к подтвержденному пользователю
+func TestOIDCDoesNotLinkUnverifiedProviderEmail(t *testing.T) {
+	h, _, idp := newOIDCHandler(t)
+	ctx := t.Context()
+
+	owner, err := h.createUser(ctx, "owner", "owner@example.com", testPassword, auth.RoleUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := repository.ActionToken{JTI: "verify-1", UserID: owner.ID, Purpose: auth.PurposeVerifyEmail, Email: owner.Email}
+	if err := h.ActionTokens.Issue(ctx, token, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.ActionTokens.VerifyEmail(ctx, token); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := oidcSignIn(t, h, idp, jwt.MapClaims{"sub": "intruder", "email": "owner@example.com", "email_verified": false})
+	if sessionStarted(rec) {
+		t.Error("создана сессия")
+	}
+	if _, _, err := h.Identities.GetUser(ctx, idp.Issuer, "intruder"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("учетная запись провайдера привязана: %v", err)
+	}
+}
@@ -1,58 +1,26 @@
 package handler
 
 import (
-	"database/sql"
 	"errors"
-	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
-	"strings"
 
 	"cosmos/internal/models"
+	"cosmos/internal/repository"
 )
 
 // AdminPlanetsHandler - список планет в админке
 func (h *Handler) AdminPlanetsHandler(w http.ResponseWriter, r *http.Request) {
 	h.setEncoding(w)
 
-	// Проверяем авторизацию
-	_, err := h.requireAdminAuth(w, r)
-	if err != nil {
-		return
-	}
-
 	// Получаем планеты из БД
-	query := `
-        SELECT p.id, p.name, p.type, p.diameter_km, p.has_life,
-               COALESCE(g.name, 'Не указана') as galaxy_name
-        FROM planets p
-        LEFT JOIN galaxies g ON p.galaxy_id = g.id
-        ORDER BY p.id DESC
-    `
-
-	rows, err := h.DB.Query(query)
+	planets, err := h.Planets.List(r.Context(), repository.NewestFirst)
 	if err != nil {
-		log.Printf("Ошибка SQL запроса планет (админка): %v", err)
+		slog.ErrorContext(r.Context(), "Ошибка SQL запроса планет (админка)", "err", err)
 		http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
-
-	var planets []models.Planet
-	for rows.Next() {
-		var p models.Planet
-		err := rows.Scan(&p.ID, &p.Name, &p.Type, &p.DiameterKm, &p.HasLife, &p.GalaxyName)
-		if err != nil {
-			log.Printf("Ошибка сканирования планеты (админка): %v", err)
-			continue
-		}
-		planets = append(planets, p)
-	}
-
-	// Получаем общее количество
-	var planetCount int
-	h.DB.QueryRow("SELECT COUNT(*) FROM planets").Scan(&planetCount)
 
 	// Получаем сообщение об успехе из URL параметра
 	success := r.URL.Query().Get("success")
@@ -61,32 +29,22 @@ func (h *Handler) AdminPlanetsHandler(w http.ResponseWriter, r *http.Request) {
 		Title:       "Управление планетами",
 		CurrentPage: "admin_planets",
 		Planets:     planets,
-		PlanetCount: planetCount,
+		PlanetCount: len(planets),
 		IsAdmin:     true,
 		Success:     success,
 	}
 
-	err = h.Tmpl.ExecuteTemplate(w, "base.html", data)
-	if err != nil {
-		log.Printf("Ошибка выполнения шаблона admin_planets: %v", err)
-		http.Error(w, "Ошибка отображения страницы", http.StatusInternalServerError)
-	}
+	h.render(w, r, &data)
 }
 
 // AdminNewPlanetHandler - форма создания новой планеты
 func (h *Handler) AdminNewPlanetHandler(w http.ResponseWriter, r *http.Request) {
 	h.setEncoding(w)
 
-	// Проверяем авторизацию
-	_, err := h.requireAdminAuth(w, r)
-	if err != nil {
-		return
-	}
-
 	// Получаем список галактик для выпадающего списка
-	galaxies, err := h.getGalaxies()
+	galaxies, err := h.Galaxies.List(r.Context(), repository.ByName)
 	if err != nil {
-		log.Printf("Ошибка получения галактик: %v", err)
+		slog.ErrorContext(r.Context(), "Ошибка получения галактик", "err", err)
 		http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
 		return
 	}
@@ -117,7 +75,7 @@ func (h *Handler) AdminNewPlanetHandler(w http.ResponseWriter, r *http.Request)
 			data.Planet = planet
 		} else {
 			// Сохраняем в БД
-			err = h.savePlanet(&planet)
+			err = h.Planets.Create(r.Context(), &planet)
 			if err != nil {
 				data.Error = "Ошибка сохранения в базу данных"
 				data.Planet = planet
@@ -128,33 +86,13 @@ func (h *Handler) AdminNewPlanetHandler(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
-	err = h.Tmpl.ExecuteTemplate(w, "base.html", data)
-	if err != nil {
-		log.Printf("Ошибка выполнения шаблона admin_planet_form: %v", err)
-		http.Error(w, "Ошибка отображения страницы", http.StatusInternalServerError)
-	}
+	h.render(w, r, &data)
 }
 
 // AdminDeletePlanetHandler - удаление планеты
 func (h *Handler) AdminDeletePlanetHandler(w http.ResponseWriter, r *http.Request) {
-	// Проверяем авторизацию
-	_, err := h.requireAdminAuth(w, r)
-	if err != nil {
-		return
-	}
-
-	// Извлекаем ID из URL: /admin/planets/delete/{id}
-	// pathParts: ["", "admin", "planets", "delete", "{id}"]
-	pathParts := strings.Split(r.URL.Path, "/")
-
-	if len(pathParts) != 5 {
-		http.NotFound(w, r)
-		return
-	}
-
-	id, err := strconv.Atoi(pathParts[4]) // pathParts[4] это ID
-	if err != nil {
-		http.NotFound(w, r)
+	id, ok := pathID(w, r)
+	if !ok {
 		return
 	}
 
@@ -164,36 +102,24 @@ func (h *Handler) AdminDeletePlanetHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	// Если POST запрос - выполняем удаление
-	if r.Method != http.MethodPost {
-		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
-		return
-	}
-
+	// POST запрос - выполняем удаление
 	// Получаем имя планеты для логирования
-	var planetName string
-	h.DB.QueryRow("SELECT name FROM planets WHERE id = $1", id).Scan(&planetName)
-
-	// Удаляем планету
-	result, err := h.DB.Exec("DELETE FROM planets WHERE id = $1", id)
+	planet, err := h.Planets.Get(r.Context(), id)
+	if err == nil {
+		err = h.Planets.Delete(r.Context(), id)
+	}
 	if err != nil {
-		log.Printf("Ошибка удаления планеты %d: %v", id, err)
-
-		if strings.Contains(err.Error(), "foreign key constraint") {
-			http.Error(w, "Нельзя удалить планету, так как она связана с другими данными", http.StatusBadRequest)
-		} else {
-			http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+		if errors.Is(err, repository.ErrNotFound) {
+			http.NotFound(w, r)
+			return
 		}
+		slog.ErrorContext(r.Context(), "Ошибка удаления планеты", "id", id, "err", err)
+		http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
 		return
 	}
+	planetName := planet.Name
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		http.NotFound(w, r)
-		return
-	}
-
-	log.Printf("Планета удалена: %s (ID %d)", planetName, id)
+	slog.InfoContext(r.Context(), "Планета удалена", "planet", planetName, "id", id)
 
 	// Редирект с сообщением об успехе
 	http.Redirect(w, r, "/admin/planets?success=Планета+"+planetName+"+удалена", http.StatusFound)
@@ -201,24 +127,13 @@ func (h *Handler) AdminDeletePlanetHandler(w http.ResponseWriter, r *http.Reques
 
 // Функция для страницы подтверждения
 func (h *Handler) showDeletePlanetConfirmation(w http.ResponseWriter, r *http.Request, id int) {
-	log.Printf("showDeletePlanetConfirmation вызван для ID: %d", id)
-
 	h.setEncoding(w)
 
 	// Получаем планету из БД
-	var planet models.Planet
-	var galaxyName sql.NullString
-
-	err := h.DB.QueryRow(`
-        SELECT p.id, p.name, p.type, p.diameter_km, COALESCE(g.name, 'Не указана') as galaxy_name
-        FROM planets p
-        LEFT JOIN galaxies g ON p.galaxy_id = g.id
-        WHERE p.id = $1
-    `, id).Scan(&planet.ID, &planet.Name, &planet.Type, &planet.DiameterKm, &galaxyName)
-
+	planet, err := h.Planets.Get(r.Context(), id)
 	if err != nil {
-		log.Printf("Ошибка получения планеты для удаления: %v", err)
-		if err == sql.ErrNoRows {
+		slog.ErrorContext(r.Context(), "Ошибка получения планеты для удаления", "err", err)
+		if errors.Is(err, repository.ErrNotFound) {
 			http.NotFound(w, r)
 		} else {
 			http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
@@ -226,10 +141,6 @@ func (h *Handler) showDeletePlanetConfirmation(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	if galaxyName.Valid {
-		planet.GalaxyName = galaxyName.String
-	}
-
 	// Структура для данных страницы подтверждения
 	type DeleteData struct {
 		models.PageData
@@ -257,46 +168,23 @@ func (h *Handler) showDeletePlanetConfirmation(w http.ResponseWriter, r *http.Re
 		PlanetCount: 0,
 	}
 
-	log.Printf("Данные для шаблона: ObjectType=%s, ObjectName=%s", data.ObjectType, data.ObjectName)
-
-	// Пробуем выполнить шаблон
-	err = h.Tmpl.ExecuteTemplate(w, "admin_confirm_delete", data)
-	if err != nil {
-		log.Printf("Ошибка выполнения шаблона admin_confirm_delete: %v", err)
-
-		// Покажем простую страницу ошибки
-		fmt.Fprintf(w, `
-            <html><body style="background:#0a0a2a;color:white;padding:50px;">
-            <h1>Ошибка загрузки шаблона</h1>
-            <p>%v</p>
-            <p>ObjectType: %s</p>
-            <p>ObjectName: %s</p>
-            <a href="/admin/planets">Назад</a>
-            </body></html>
-        `, err, data.ObjectType, data.ObjectName)
-	}
+	h.render(w, r, &data)
 }
 
 //Вспомогательные методы
 
-func (h *Handler) getGalaxies() ([]models.Galaxy, error) {
-	rows, err := h.DB.Query("SELECT id, name FROM galaxies ORDER BY name")
-	if err != nil {
-		return nil, err
+// validatePlanet - проверка обязательных полей планеты (общая для форм и API)
+func validatePlanet(planet models.Planet) error {
+	if planet.Name == "" {
+		return errors.New("название планеты обязательно")
 	}
-	defer rows.Close()
-
-	var galaxies []models.Galaxy
-	for rows.Next() {
-		var g models.Galaxy
-		err := rows.Scan(&g.ID, &g.Name)
-		if err != nil {
-			return nil, err
-		}
-		galaxies = append(galaxies, g)
+	if planet.Type == "" {
+		return errors.New("тип планеты обязателен")
 	}
-
-	return galaxies, nil
+	if planet.Description == "" {
+		return errors.New("описание обязательно")
+	}
+	return nil
 }
 
 func (h *Handler) parsePlanetForm(r *http.Request) (models.Planet, error) {
@@ -307,17 +195,9 @@ func (h *Handler) parsePlanetForm(r *http.Request) (models.Planet, error) {
 	planet.Type = r.FormValue("type")
 	planet.Description = r.FormValue("description")
 
-	log.Printf("Парсим форму: name=%s, type=%s", planet.Name, planet.Type)
-
 	// Проверяем обязательные поля
-	if planet.Name == "" {
-		return planet, errors.New("название планеты обязательно")
-	}
-	if planet.Type == "" {
-		return planet, errors.New("тип планеты обязателен")
-	}
-	if planet.Description == "" {
-		return planet, errors.New("описание обязательно")
+	if err := validatePlanet(planet); err != nil {
+		return planet, err
 	}
 
 	// Числовые поля
@@ -325,7 +205,7 @@ func (h *Handler) parsePlanetForm(r *http.Request) (models.Planet, error) {
 		if val, err := strconv.ParseFloat(diameter, 64); err == nil {
 			planet.DiameterKm = val
 		} else {
-			log.Printf("Ошибка парсинга diameter_km: %v", err)
+			slog.WarnContext(r.Context(), "Некорректное значение поля формы", "field", "diameter_km", "err", err)
 		}
 	}
 
@@ -333,7 +213,7 @@ func (h *Handler) parsePlanetForm(r *http.Request) (models.Planet, error) {
 		if val, err := strconv.ParseFloat(mass, 64); err == nil {
 			planet.MassKg = val
 		} else {
-			log.Printf("Ошибка парсинга mass_kg: %v", err)
+			slog.WarnContext(r.Context(), "Некорректное значение поля формы", "field", "mass_kg", "err", err)
 		}
 	}
 
@@ -341,7 +221,7 @@ func (h *Handler) parsePlanetForm(r *http.Request) (models.Planet, error) {
 		if val, err := strconv.ParseFloat(period, 64); err == nil {
 			planet.OrbitalPeriodDays = val
 		} else {
-			log.Printf("Ошибка парсинга orbital_period_days: %v", err)
+			slog.WarnContext(r.Context(), "Некорректное значение поля формы", "field", "orbital_period_days", "err", err)
 		}
 	}
 
@@ -349,7 +229,7 @@ func (h *Handler) parsePlanetForm(r *http.Request) (models.Planet, error) {
 		if val, err := strconv.Atoi(year); err == nil {
 			planet.DiscoveredYear = &val
 		} else {
-			log.Printf("Ошибка парсинга discovered_year: %v", err)
+			slog.WarnContext(r.Context(), "Некорректное значение поля формы", "field", "discovered_year", "err", err)
 		}
 	}
 
@@ -358,7 +238,7 @@ func (h *Handler) parsePlanetForm(r *http.Request) (models.Planet, error) {
 		if val, err := strconv.Atoi(galaxyID); err == nil {
 			planet.GalaxyID = &val
 		} else {
-			log.Printf("Ошибка парсинга galaxy_id: %v", err)
+			slog.WarnContext(r.Context(), "Некорректное значение поля формы", "field", "galaxy_id", "err", err)
 		}
 	}
 
@@ -366,106 +246,21 @@ func (h *Handler) parsePlanetForm(r *http.Request) (models.Planet, error) {
 	planet.HasLife = r.FormValue("has_life") == "on" || r.FormValue("has_life") == "true"
 	planet.IsHabitable = r.FormValue("is_habitable") == "on" || r.FormValue("is_habitable") == "true"
 
-	log.Printf("Результат парсинга: %+v", planet)
-
 	return planet, nil
 }
 
-func (h *Handler) savePlanet(planet *models.Planet) error {
-	query := `
-		INSERT INTO planets (name, type, description, diameter_km, mass_kg,
-		                    orbital_period_days, discovered_year, galaxy_id,
-		                    has_life, is_habitable)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
-		RETURNING id, created_at
-	`
-
-	var galaxyID any
-	if planet.GalaxyID != nil && *planet.GalaxyID > 0 {
-		galaxyID = *planet.GalaxyID
-	} else {
-		galaxyID = nil
-	}
-
-	var discoveredYear any
-	if planet.DiscoveredYear != nil && *planet.DiscoveredYear != 0 {
-		discoveredYear = *planet.DiscoveredYear
-	} else {
-		discoveredYear = nil
-	}
-
-	err := h.DB.QueryRow(query,
-		planet.Name, planet.Type, planet.Description,
-		planet.DiameterKm, planet.MassKg, planet.OrbitalPeriodDays,
-		discoveredYear, galaxyID,
-		planet.HasLife, planet.IsHabitable,
-	).Scan(&planet.ID, &planet.CreatedAt)
-
-	return err
-}
-
-func (h *Handler) updatePlanet(id int, planet *models.Planet) error {
-	query := `
-        UPDATE planets
-        SET name = $1, type = $2, description = $3, diameter_km = $4,
-            mass_kg = $5, orbital_period_days = $6, discovered_year = $7,
-            galaxy_id = $8, has_life = $9, is_habitable = $10,
-            updated_at = CURRENT_TIMESTAMP
-        WHERE id = $11
-        RETURNING updated_at
-    `
-
-	var galaxyID any
-	if planet.GalaxyID != nil && *planet.GalaxyID > 0 {
-		galaxyID = *planet.GalaxyID
-	} else {
-		galaxyID = nil
-	}
-
-	var discoveredYear any
-	if planet.DiscoveredYear != nil && *planet.DiscoveredYear != 0 {
-		discoveredYear = *planet.DiscoveredYear
-	} else {
-		discoveredYear = nil
-	}
-
-	err := h.DB.QueryRow(query,
-		planet.Name, planet.Type, planet.Description,
-		planet.DiameterKm, planet.MassKg, planet.OrbitalPeriodDays,
-		discoveredYear, galaxyID,
-		planet.HasLife, planet.IsHabitable,
-		id,
-	).Scan(&planet.UpdatedAt)
-
-	return err
-}
-
 func (h *Handler) AdminEditPlanetHandler(w http.ResponseWriter, r *http.Request) {
 	h.setEncoding(w)
 
-	// Проверяем авторизацию
-	_, err := h.requireAdminAuth(w, r)
-	if err != nil {
-		return
-	}
-
-	// Извлекаем ID из URL
-	pathParts := strings.Split(r.URL.Path, "/")
-	if len(pathParts) != 5 {
-		http.NotFound(w, r)
-		return
-	}
-
-	id, err := strconv.Atoi(pathParts[4])
-	if err != nil {
-		http.NotFound(w, r)
+	id, ok := pathID(w, r)
+	if !ok {
 		return
 	}
 
 	// Получаем список галактик для выпадающего списка
-	galaxies, err := h.getGalaxies()
+	galaxies, err := h.Galaxies.List(r.Context(), repository.ByName)
 	if err != nil {
-		log.Printf("Ошибка получения галактик: %v", err)
+		slog.ErrorContext(r.Context(), "Ошибка получения галактик", "err", err)
 		http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
 		return
 	}
@@ -480,51 +275,17 @@ func (h *Handler) AdminEditPlanetHandler(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Получаем планету из БД
-	var planet models.Planet
-	var discoveredYear sql.NullInt64
-	var galaxyID sql.NullInt64
-	var massKg sql.NullFloat64
-	var orbitalPeriodDays sql.NullFloat64
-
-	err = h.DB.QueryRow(`
-        SELECT id, name, type, description, diameter_km, mass_kg,
-               orbital_period_days, discovered_year, galaxy_id,
-               has_life, is_habitable, created_at
-        FROM planets
-        WHERE id = $1
-    `, id).Scan(
-		&planet.ID, &planet.Name, &planet.Type, &planet.Description,
-		&planet.DiameterKm, &massKg, &orbitalPeriodDays,
-		&discoveredYear, &galaxyID, &planet.HasLife, &planet.IsHabitable,
-		&planet.CreatedAt,
-	)
-
+	planet, err := h.Planets.Get(r.Context(), id)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, repository.ErrNotFound) {
 			http.NotFound(w, r)
 		} else {
-			log.Printf("Ошибка получения планеты: %v", err)
+			slog.ErrorContext(r.Context(), "Ошибка получения планеты", "err", err)
 			http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
 		}
 		return
 	}
 
-	// Обрабатываем nullable поля
-	if discoveredYear.Valid {
-		year := int(discoveredYear.Int64)
-		planet.DiscoveredYear = &year
-	}
-	if galaxyID.Valid {
-		id := int(galaxyID.Int64)
-		planet.GalaxyID = &id
-	}
-	if massKg.Valid {
-		planet.MassKg = massKg.Float64
-	}
-	if orbitalPeriodDays.Valid {
-		planet.OrbitalPeriodDays = orbitalPeriodDays.Float64
-	}
-
 	data := FormData{
 		PageData: models.PageData{
 			Title:       "Редактирование планеты",
@@ -544,7 +305,7 @@ func (h *Handler) AdminEditPlanetHandler(w http.ResponseWriter, r *http.Request)
 			data.Planet.ID = planet.ID // Сохраняем оригинальный ID
 		} else {
 			// Обновляем в БД
-			err = h.updatePlanet(id, &updatedPlanet)
+			err = h.Planets.Update(r.Context(), id, &updatedPlanet)
 			if err != nil {
 				data.Error = "Ошибка обновления в базе данных: " + err.Error()
 				data.Planet = updatedPlanet
@@ -555,14 +316,10 @@ func (h *Handler) AdminEditPlanetHandler(w http.ResponseWriter, r *http.Request)
 				data.Planet = updatedPlanet
 				data.Planet.ID = planet.ID
 				data.Planet.CreatedAt = planet.CreatedAt
-				log.Printf("Планета обновлена: ID %d", id)
+				slog.InfoContext(r.Context(), "Планета обновлена", "id", id)
 			}
 		}
 	}
 
-	err = h.Tmpl.ExecuteTemplate(w, "base.html", data)
-	if err != nil {
-		log.Printf("Ошибка выполнения шаблона admin_planet_form (edit): %v", err)
-		http.Error(w, "Ошибка отображения страницы", http.StatusInternalServerError)
-	}
+	h.render(w, r, &data)
 }
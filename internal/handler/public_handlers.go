@@ -1,34 +1,27 @@
 package handler
 
 import (
-	"database/sql"
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
-	"strconv"
-	"strings"
 
 	"cosmos/internal/models"
+	"cosmos/internal/repository"
 )
 
 // HomeHandler - главная страница
 func (h *Handler) HomeHandler(w http.ResponseWriter, r *http.Request) {
-	if r.URL.Path != "/" {
-		http.NotFound(w, r)
-		return
-	}
-
 	h.setEncoding(w)
 
-	var planetCount, galaxyCount int
-	err := h.DB.QueryRow("SELECT COUNT(*) FROM planets").Scan(&planetCount)
+	planetCount, err := h.Planets.Count(r.Context())
 	if err != nil {
-		log.Printf("Ошибка получения количества планет: %v", err)
+		slog.ErrorContext(r.Context(), "Ошибка получения количества планет", "err", err)
 		planetCount = 0
 	}
 
-	err = h.DB.QueryRow("SELECT COUNT(*) FROM galaxies").Scan(&galaxyCount)
+	galaxyCount, err := h.Galaxies.Count(r.Context())
 	if err != nil {
-		log.Printf("Ошибка получения количества галактик: %v", err)
+		slog.ErrorContext(r.Context(), "Ошибка получения количества галактик", "err", err)
 		galaxyCount = 0
 	}
 
@@ -40,60 +33,16 @@ func (h *Handler) HomeHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Используем базовый шаблон base.html
-	err = h.Tmpl.ExecuteTemplate(w, "base.html", data)
-	if err != nil {
-		log.Printf("❌ Ошибка выполнения шаблона home: %v", err)
-		http.Error(w, "Ошибка шаблона", http.StatusInternalServerError)
-	}
+	h.render(w, r, &data)
 }
 
 // PlanetsHandler - список планет
 func (h *Handler) PlanetsHandler(w http.ResponseWriter, r *http.Request) {
 	h.setEncoding(w)
 
-	query := `
-		SELECT p.id, p.name, p.type, p.diameter_km, p.mass_kg,
-		       p.orbital_period_days, p.has_life, p.is_habitable,
-		       p.description, COALESCE(g.name, 'Не указана') as galaxy_name
-		FROM planets p
-		LEFT JOIN galaxies g ON p.galaxy_id = g.id
-		ORDER BY p.name
-	`
-
-	rows, err := h.DB.Query(query)
+	planets, err := h.Planets.List(r.Context(), repository.ByName)
 	if err != nil {
-		log.Printf("Ошибка SQL запроса планет: %v", err)
-		// Создаем данные с пустым списком планет
-		data := models.PageData{
-			Title:       "Планеты",
-			CurrentPage: "planets",
-			Planets:     []models.Planet{},
-		}
-		// Указываем явно какой шаблон использовать для контента
-		h.Tmpl.ExecuteTemplate(w, "base.html", data)
-		return
-	}
-	defer rows.Close()
-
-	var planets []models.Planet
-	for rows.Next() {
-		var p models.Planet
-
-		err := rows.Scan(
-			&p.ID, &p.Name, &p.Type, &p.DiameterKm, &p.MassKg,
-			&p.OrbitalPeriodDays, &p.HasLife, &p.IsHabitable,
-			&p.Description, &p.GalaxyName,
-		)
-		if err != nil {
-			log.Printf("Ошибка сканирования планеты: %v", err)
-			continue
-		}
-
-		planets = append(planets, p)
-	}
-
-	if err = rows.Err(); err != nil {
-		log.Printf("Ошибка итерации планет: %v", err)
+		slog.ErrorContext(r.Context(), "Ошибка SQL запроса планет", "err", err)
 	}
 
 	data := models.PageData{
@@ -103,72 +52,35 @@ func (h *Handler) PlanetsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Сначала парсим шаблон планет, потом base
-	err = h.Tmpl.ExecuteTemplate(w, "base.html", data)
-	if err != nil {
-		log.Printf("Ошибка выполнения шаблона planets: %v", err)
-		// Показываем простую ошибку пользователю
-		http.Error(w, "Ошибка отображения страницы. Проверьте консоль сервера.", http.StatusInternalServerError)
-	}
+	h.render(w, r, &data)
 }
 
 // PlanetDetailHandler - детальная страница планеты
 func (h *Handler) PlanetDetailHandler(w http.ResponseWriter, r *http.Request) {
 	h.setEncoding(w)
 
-	pathParts := strings.Split(r.URL.Path, "/")
-	if len(pathParts) != 3 {
-		http.NotFound(w, r)
-		return
-	}
-
-	id, err := strconv.Atoi(pathParts[2])
-	if err != nil {
-		http.NotFound(w, r)
+	id, ok := pathID(w, r)
+	if !ok {
 		return
 	}
 
-	var planet models.Planet
-	var discoveredYear sql.NullInt64
-
-	err = h.DB.QueryRow(`
-		SELECT p.id, p.name, p.type, p.diameter_km, p.mass_kg,
-		       p.orbital_period_days, p.has_life, p.is_habitable,
-		       p.discovered_year, p.description,
-		       COALESCE(g.name, 'Не указана') as galaxy_name
-		FROM planets p
-		LEFT JOIN galaxies g ON p.galaxy_id = g.id
-		WHERE p.id = $1
-	`, id).Scan(
-		&planet.ID, &planet.Name, &planet.Type, &planet.DiameterKm,
-		&planet.MassKg, &planet.OrbitalPeriodDays, &planet.HasLife,
-		&planet.IsHabitable, &discoveredYear, &planet.Description,
-		&planet.GalaxyName,
-	)
-
+	planet, err := h.Planets.Get(r.Context(), id)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			log.Printf("Планета с ID %d не найдена", id)
+		if errors.Is(err, repository.ErrNotFound) {
+			slog.DebugContext(r.Context(), "Планета не найдена", "id", id)
 			// Показываем страницу 404
 			data := models.PageData{
 				Title:       "Планета не найдена",
 				CurrentPage: "planets",
 			}
-			h.Tmpl.ExecuteTemplate(w, "base.html", data)
+			h.render(w, r, &data)
 			return
 		}
-		log.Printf("Ошибка запроса планеты ID %d: %v", id, err)
+		slog.ErrorContext(r.Context(), "Ошибка запроса планеты", "id", id, "err", err)
 		http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
 		return
 	}
 
-	// Обрабатываем nullable поля
-	if discoveredYear.Valid {
-		year := int(discoveredYear.Int64)
-		planet.DiscoveredYear = &year
-	}
-
-	log.Printf("Найдена планета: %s (ID: %d)", planet.Name, planet.ID)
-
 	data := models.PageData{
 		Title:       planet.Name,
 		CurrentPage: "planets",
@@ -176,171 +88,57 @@ func (h *Handler) PlanetDetailHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Используем шаблон planet.html внутри base.html
-	err = h.Tmpl.ExecuteTemplate(w, "base.html", data)
-	if err != nil {
-		log.Printf("Ошибка выполнения шаблона planet detail: %v", err)
-		log.Printf("Доступные шаблоны:")
-		for _, t := range h.Tmpl.Templates() {
-			if t.Name() != "" {
-				log.Printf("  - %s", t.Name())
-			}
-		}
-		http.Error(w, "Ошибка отображения страницы", http.StatusInternalServerError)
-	}
+	h.render(w, r, &data)
 }
 
 // GalaxiesHandler - список галактик
 func (h *Handler) GalaxiesHandler(w http.ResponseWriter, r *http.Request) {
 	h.setEncoding(w)
 
-	rows, err := h.DB.Query(`
-		SELECT id, name, type, diameter_ly, mass_suns,
-		       distance_from_earth_ly, discovered_year, description
-		FROM galaxies
-		ORDER BY name
-	`)
-
+	galaxies, err := h.Galaxies.List(r.Context(), repository.ByName)
 	if err != nil {
-		log.Printf("Ошибка SQL запроса галактик: %v", err)
-		data := models.PageData{
-			Title:       "Галактики",
-			CurrentPage: "galaxies",
-			Galaxies:    []models.Galaxy{},
-		}
-		h.Tmpl.ExecuteTemplate(w, "base.html", data)
-		return
-	}
-	defer rows.Close()
-
-	var galaxies []models.Galaxy
-	for rows.Next() {
-		var g models.Galaxy
-		var diameterLy, massSuns, distanceFromEarthLy sql.NullFloat64
-		var discoveredYear sql.NullInt64
-
-		err := rows.Scan(
-			&g.ID, &g.Name, &g.Type, &diameterLy, &massSuns,
-			&distanceFromEarthLy, &discoveredYear, &g.Description,
-		)
-		if err != nil {
-			log.Printf("Ошибка сканирования галактики: %v", err)
-			continue
-		}
-
-		// Обрабатываем nullable поля - исправлено здесь
-		if diameterLy.Valid {
-			val := diameterLy.Float64
-			g.DiameterLy = &val
-		}
-		if massSuns.Valid {
-			val := massSuns.Float64
-			g.MassSuns = &val
-		}
-		if distanceFromEarthLy.Valid {
-			val := distanceFromEarthLy.Float64
-			g.DistanceFromEarthLy = &val
-		}
-		if discoveredYear.Valid {
-			year := int(discoveredYear.Int64)
-			g.DiscoveredYear = &year
-		}
-
-		galaxies = append(galaxies, g)
+		slog.ErrorContext(r.Context(), "Ошибка SQL запроса галактик", "err", err)
 	}
 
-	if err = rows.Err(); err != nil {
-		log.Printf("❌ Ошибка итерации галактик: %v", err)
-	}
-
-	log.Printf("Найдено галактик: %d", len(galaxies))
-
 	data := models.PageData{
 		Title:       "Галактики",
 		CurrentPage: "galaxies",
 		Galaxies:    galaxies,
 	}
 
-	err = h.Tmpl.ExecuteTemplate(w, "base.html", data)
-	if err != nil {
-		log.Printf("Ошибка выполнения шаблона galaxies: %v", err)
-		http.Error(w, "Ошибка отображения страницы", http.StatusInternalServerError)
-	}
+	h.render(w, r, &data)
 }
 
 // GalaxyDetailHandler - детальная страница галактики
 func (h *Handler) GalaxyDetailHandler(w http.ResponseWriter, r *http.Request) {
 	h.setEncoding(w)
 
-	pathParts := strings.Split(r.URL.Path, "/")
-	if len(pathParts) != 3 {
-		http.NotFound(w, r)
-		return
-	}
-
-	id, err := strconv.Atoi(pathParts[2])
-	if err != nil {
-		http.NotFound(w, r)
+	id, ok := pathID(w, r)
+	if !ok {
 		return
 	}
 
-	var galaxy models.Galaxy
-	var diameterLy, massSuns, distanceFromEarthLy sql.NullFloat64
-	var discoveredYear sql.NullInt64
-
-	err = h.DB.QueryRow(`
-		SELECT id, name, type, diameter_ly, mass_suns,
-		       distance_from_earth_ly, discovered_year, description
-		FROM galaxies
-		WHERE id = $1
-	`, id).Scan(
-		&galaxy.ID, &galaxy.Name, &galaxy.Type, &diameterLy, &massSuns,
-		&distanceFromEarthLy, &discoveredYear, &galaxy.Description,
-	)
-
+	galaxy, err := h.Galaxies.Get(r.Context(), id)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			log.Printf("Галактика с ID %d не найдена", id)
+		if errors.Is(err, repository.ErrNotFound) {
+			slog.DebugContext(r.Context(), "Галактика не найдена", "id", id)
 			data := models.PageData{
 				Title:       "Галактика не найдена",
 				CurrentPage: "galaxies",
 			}
-			h.Tmpl.ExecuteTemplate(w, "base.html", data)
+			h.render(w, r, &data)
 			return
 		}
-		log.Printf("Ошибка запроса галактики ID %d: %v", id, err)
+		slog.ErrorContext(r.Context(), "Ошибка запроса галактики", "id", id, "err", err)
 		http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
 		return
 	}
 
-	// Обрабатываем nullable поля
-	if diameterLy.Valid {
-		val := diameterLy.Float64
-		galaxy.DiameterLy = &val
-	}
-	if massSuns.Valid {
-		val := massSuns.Float64
-		galaxy.MassSuns = &val
-	}
-	if distanceFromEarthLy.Valid {
-		val := distanceFromEarthLy.Float64
-		galaxy.DistanceFromEarthLy = &val
-	}
-	if discoveredYear.Valid {
-		year := int(discoveredYear.Int64)
-		galaxy.DiscoveredYear = &year
-	}
-
-	log.Printf("Найдена галактика: %s (ID: %d)", galaxy.Name, galaxy.ID)
-
 	data := models.PageData{
 		Title:       galaxy.Name,
 		CurrentPage: "galaxies",
 		Galaxy:      &galaxy,
 	}
 
-	err = h.Tmpl.ExecuteTemplate(w, "base.html", data)
-	if err != nil {
-		log.Printf("Ошибка выполнения шаблона galaxy detail: %v", err)
-		http.Error(w, "Ошибка отображения страницы", http.StatusInternalServerError)
-	}
+	h.render(w, r, &data)
 }
@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"net/http"
+
+	"cosmos/internal/auth"
+	"cosmos/internal/metrics"
+	"cosmos/internal/router"
+	"cosmos/internal/tracing"
+)
+
+// Routes - все маршруты сайта, админки и JSON API с общими middleware.
+//
+// Снаружи внутрь: RequestID присваивает запросу ID (заголовок X-Request-ID),
+// Logger пишет строку access log на каждый запрос, Recover превращает панику
+// в ответ 500, Timeout ограничивает время обработки (RequestTimeout),
+// WithSecurityHeaders добавляет CSP и другие заголовки безопасности, WithCSRF
+// проверяет CSRF токен форм, WithSession продлевает вход по refresh токену
+// при истекшем токене доступа. Проверка прав подключается к группам маршрутов.
+//
+// Router.Observe снаружи цепочки считает метрики запросов по шаблону маршрута,
+// tracing.Middleware открывает спан запроса, в который попадают запросы к БД и шаблоны.
+// Проверки оркестратора /healthz, /readyz и метрики /metrics обслуживаются до
+// этой цепочки: им не нужны сессия и CSRF, а частые запросы не засоряют access log.
+func (h *Handler) Routes() http.Handler {
+	rt := router.New()
+
+	// Публичные страницы
+	rt.HandleFunc("GET /{$}", h.HomeHandler)
+	rt.HandleFunc("GET /planets", h.PlanetsHandler)
+	rt.HandleFunc("GET /planets/{id}", h.PlanetDetailHandler)
+	rt.HandleFunc("GET /galaxies", h.GalaxiesHandler)
+	rt.HandleFunc("GET /galaxies/{id}", h.GalaxyDetailHandler)
+
+	// Аккаунт пользователя
+	rt.HandleFunc("GET /register", h.RegisterHandler)
+	rt.HandleFunc("POST /register", h.RegisterHandler)
+	rt.HandleFunc("GET /login", h.LoginHandler)
+	rt.HandleFunc("POST /login", h.LoginHandler)
+	rt.HandleFunc("POST /logout", h.LogoutHandler)
+	rt.HandleFunc("GET /login/2fa", h.LoginTOTPHandler)
+	rt.HandleFunc("POST /login/2fa", h.LoginTOTPHandler)
+	rt.HandleFunc("GET /forgot-password", h.ForgotPasswordHandler)
+	rt.HandleFunc("POST /forgot-password", h.ForgotPasswordHandler)
+	rt.HandleFunc("GET /reset-password", h.ResetPasswordHandler)
+	rt.HandleFunc("POST /reset-password", h.ResetPasswordHandler)
+	rt.HandleFunc("GET /verify-email", h.VerifyEmailHandler)
+
+	profile := rt.Group("/profile", h.RequireUser)
+	profile.HandleFunc("GET /", h.ProfileHandler)
+	profile.HandleFunc("POST /", h.ProfileHandler)
+	profile.HandleFunc("GET /2fa", h.ProfileTOTPHandler)
+	profile.HandleFunc("POST /2fa", h.ProfileTOTPHandler)
+	profile.HandleFunc("POST /verify-email", h.ResendVerificationHandler)
+
+	// Вход через OpenID Connect
+	rt.HandleFunc("GET /auth/oidc/login", h.OIDCLoginHandler)
+	rt.HandleFunc("GET /auth/oidc/callback", h.OIDCCallbackHandler)
+
+	// Админ-панель
+	admin := rt.Group("/admin")
+	admin.HandleFunc("GET /login", h.AdminLoginHandler)
+	admin.HandleFunc("POST /login", h.AdminLoginHandler)
+	admin.HandleFunc("POST /logout", h.AdminLogoutHandler)
+	admin.With(h.RequirePermission(auth.PermAdminAccess)).HandleFunc("GET /", h.AdminDashboardHandler)
+
+	// Планеты
+	planets := admin.Group("/planets")
+	planets.With(h.RequirePermission(auth.PermAdminAccess)).HandleFunc("GET /", h.AdminPlanetsHandler)
+	planetsWrite := planets.With(h.RequirePermission(auth.PermPlanetsWrite))
+	planetsWrite.HandleFunc("GET /new", h.AdminNewPlanetHandler)
+	planetsWrite.HandleFunc("POST /new", h.AdminNewPlanetHandler)
+	planetsWrite.HandleFunc("GET /edit/{id}", h.AdminEditPlanetHandler)
+	planetsWrite.HandleFunc("POST /edit/{id}", h.AdminEditPlanetHandler)
+	planetsDelete := planets.With(h.RequirePermission(auth.PermPlanetsDelete))
+	planetsDelete.HandleFunc("GET /delete/{id}", h.AdminDeletePlanetHandler)
+	planetsDelete.HandleFunc("POST /delete/{id}", h.AdminDeletePlanetHandler)
+
+	// Галактики
+	galaxies := admin.Group("/galaxies")
+	galaxies.With(h.RequirePermission(auth.PermAdminAccess)).HandleFunc("GET /", h.AdminGalaxiesHandler)
+	galaxiesWrite := galaxies.With(h.RequirePermission(auth.PermGalaxiesWrite))
+	galaxiesWrite.HandleFunc("GET /new", h.AdminNewGalaxyHandler)
+	galaxiesWrite.HandleFunc("POST /new", h.AdminNewGalaxyHandler)
+	galaxiesWrite.HandleFunc("GET /edit/{id}", h.AdminEditGalaxyHandler)
+	galaxiesWrite.HandleFunc("POST /edit/{id}", h.AdminEditGalaxyHandler)
+	galaxiesDelete := galaxies.With(h.RequirePermission(auth.PermGalaxiesDelete))
+	galaxiesDelete.HandleFunc("GET /delete/{id}", h.AdminDeleteGalaxyHandler)
+	galaxiesDelete.HandleFunc("POST /delete/{id}", h.AdminDeleteGalaxyHandler)
+
+	// Пользователи
+	users := admin.Group("/users")
+	usersView := users.With(h.RequirePermission(auth.PermUsersView))
+	usersView.HandleFunc("GET /", h.AdminUsersHandler)
+	usersView.HandleFunc("GET /view/{id}", h.AdminUserDetailHandler)
+	usersManage := users.With(h.RequirePermission(auth.PermUsersManage))
+	usersManage.HandleFunc("GET /new", h.AdminNewUserHandler)
+	usersManage.HandleFunc("POST /new", h.AdminNewUserHandler)
+	usersManage.HandleFunc("GET /edit/{id}", h.AdminEditUserHandler)
+	usersManage.HandleFunc("POST /edit/{id}", h.AdminEditUserHandler)
+	usersManage.HandleFunc("GET /delete/{id}", h.AdminDeleteUserHandler)
+	usersManage.HandleFunc("POST /delete/{id}", h.AdminDeleteUserHandler)
+	usersManage.HandleFunc("POST /reset-2fa/{id}", h.AdminResetUserTOTPHandler)
+
+	// Блокировки входа
+	locks := admin.Group("/login-locks", h.RequirePermission(auth.PermUsersManage))
+	locks.HandleFunc("GET /", h.AdminLoginLocksHandler)
+	locks.HandleFunc("POST /unlock", h.AdminUnlockLoginHandler)
+
+	// Сессии
+	sessions := admin.Group("/sessions", h.RequirePermission(auth.PermSessionsManage))
+	sessions.HandleFunc("GET /", h.AdminSessionsHandler)
+	sessions.HandleFunc("POST /revoke/{id}", h.AdminRevokeSessionHandler)
+	sessions.HandleFunc("POST /revoke-user/{id}", h.AdminRevokeUserSessionsHandler)
+
+	// API ключи
+	apiKeys := admin.Group("/api-keys", h.RequirePermission(auth.PermAPIKeysManage))
+	apiKeys.HandleFunc("GET /", h.AdminAPIKeysHandler)
+	apiKeys.HandleFunc("POST /", h.AdminAPIKeysHandler)
+	apiKeys.HandleFunc("POST /delete/{id}", h.AdminDeleteAPIKeyHandler)
+
+	// Статические файлы
+	fs := http.FileServer(http.Dir("static"))
+	rt.Handle("GET /static/", http.StripPrefix("/static/", fs))
+
+	// JSON API, спецификация /api/openapi.json и документация (static/docs)
+	h.RegisterAPIRoutes(rt)
+	rt.HandleFunc("GET /.well-known/jwks.json", h.JWKSHandler)
+	rt.HandleFunc("GET /version", h.VersionHandler)
+	rt.Handle("GET /api/docs", http.RedirectHandler("/api/docs/", http.StatusMovedPermanently))
+	rt.Handle("GET /api/docs/", http.StripPrefix("/api", fs))
+
+	site := router.Chain(rt,
+		router.Observe(metrics.ObserveHTTP),
+		tracing.Middleware,
+		router.RequestID,
+		router.Logger,
+		router.Recover,
+		router.Timeout(h.RequestTimeout),
+		h.WithSecurityHeaders,
+		h.WithCSRF,
+		h.WithSession,
+	)
+
+	root := router.New()
+	root.HandleFunc("GET /healthz", h.HealthzHandler)
+	root.HandleFunc("GET /readyz", h.ReadyzHandler)
+	root.Handle("GET /metrics", metrics.Handler())
+	root.Handle("/", site)
+	return root
+}
@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"cosmos/internal/auth"
+	"cosmos/internal/metrics"
+	"cosmos/internal/models"
+	"cosmos/internal/repository"
+)
+
+var (
+	// errSessionInvalid - сессия не найдена, истекла или отозвана
+	errSessionInvalid = errors.New("сессия недействительна")
+	// errSessionRace - refresh токен только что заменен параллельным запросом
+	errSessionRace = errors.New("refresh токен уже обновлен другим запросом")
+)
+
+// refreshReuseGrace - окно, в котором повторное предъявление предыдущего refresh токена
+// считается гонкой параллельных запросов (две вкладки), а не кражей
+const refreshReuseGrace = 10 * time.Second
+
+// maxUserAgentLength - сколько символов User-Agent сохранять в сессии
+const maxUserAgentLength = 255
+
+// openSession - создание сессии и выдача пары токенов для пользователя
+func (h *Handler) openSession(user models.User, r *http.Request) (accessToken, refreshToken string, err error) {
+	refreshToken, refreshHash, err := auth.NewRefreshToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	sessionID, err := h.Sessions.Create(r.Context(), user.ID, refreshHash, requestUserAgent(r), clientIP(r),
+		time.Now().Add(auth.RefreshTokenTTL))
+	if err != nil {
+		return "", "", err
+	}
+
+	accessToken, err = auth.GenerateToken(user.Username, user.Role, user.ID, sessionID)
+	if err != nil {
+		return "", "", err
+	}
+
+	// Вход состоялся (включая второй фактор) - неудачные попытки больше не считаются
+	h.resetLoginFailures(r.Context(), user.Username)
+	metrics.LoginAttempt(metrics.LoginSuccess)
+
+	return accessToken, refreshToken, nil
+}
+
+// rotateSession - обмен refresh токена на новую пару токенов.
+// Старый refresh токен становится недействительным; его повторное использование
+// (признак кражи) отзывает всю сессию.
+func (h *Handler) rotateSession(refreshToken string, r *http.Request) (claims *auth.Claims, accessToken, newRefreshToken string, err error) {
+	tokenHash := auth.HashToken(refreshToken)
+
+	newRefreshToken, newHash, err := auth.NewRefreshToken()
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	owner, err := h.Sessions.Rotate(r.Context(), tokenHash, newHash, requestUserAgent(r), clientIP(r),
+		time.Now().Add(auth.RefreshTokenTTL))
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, "", "", h.checkReusedToken(r.Context(), tokenHash)
+	}
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	claims = &auth.Claims{Username: owner.Username, Role: owner.Role, UserID: owner.UserID, SessionID: owner.SessionID}
+	accessToken, err = auth.GenerateToken(claims.Username, claims.Role, claims.UserID, claims.SessionID)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	return claims, accessToken, newRefreshToken, nil
+}
+
+// checkReusedToken - разбор неизвестного refresh токена. Если это предыдущий токен
+// живой сессии, сессия отзывается (токен украден), кроме случая гонки в пределах
+// refreshReuseGrace - тогда возвращается errSessionRace.
+func (h *Handler) checkReusedToken(ctx context.Context, tokenHash string) error {
+	sessionID, userID, recent, err := h.Sessions.ByPreviousToken(ctx, tokenHash, refreshReuseGrace)
+	if errors.Is(err, repository.ErrNotFound) {
+		return errSessionInvalid
+	}
+	if err != nil {
+		return err
+	}
+	if recent {
+		return errSessionRace
+	}
+
+	// Отзыв не должен прерываться вместе с запросом того, кто предъявил украденный токен
+	if err := h.Sessions.Revoke(context.WithoutCancel(ctx), sessionID); err != nil {
+		return err
+	}
+	slog.WarnContext(ctx, "Повторное использование refresh токена, сессия отозвана", "session_id", sessionID, "user_id", userID)
+	return errSessionInvalid
+}
+
+// sessionValid - жива ли сессия токена и не изменилась ли роль пользователя
+func (h *Handler) sessionValid(ctx context.Context, claims *auth.Claims) (bool, error) {
+	if claims.SessionID == 0 {
+		return false, nil
+	}
+	return h.Sessions.Valid(ctx, claims.SessionID, claims.UserID, claims.Role)
+}
+
+// clientIP - адрес клиента без порта
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// requestUserAgent - User-Agent, обрезанный до разумной длины
+func requestUserAgent(r *http.Request) string {
+	ua := r.UserAgent()
+	if len(ua) > maxUserAgentLength {
+		ua = ua[:maxUserAgentLength]
+	}
+	return ua
+}
@@ -0,0 +1,342 @@
+package handler
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"cosmos/internal/auth"
+	"cosmos/internal/models"
+	"cosmos/internal/repository"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// mfaCookieName - cookie с промежуточным токеном между вводом пароля и второго фактора
+const mfaCookieName = "mfa_token"
+
+// loginTOTPPath - страница второго шага входа
+const loginTOTPPath = "/login/2fa"
+
+// totpSetup - данные для подключения приложения-аутентификатора
+type totpSetup struct {
+	Secret string
+	URI    string
+	QRCode template.URL // PNG в data: URL
+}
+
+// newTOTPSetup - otpauth ссылка и QR-код для секрета
+func (h *Handler) newTOTPSetup(account, secret string) (*totpSetup, error) {
+	uri := h.TOTP.URI(account, secret)
+	png, err := qrcode.Encode(uri, qrcode.Medium, 220)
+	if err != nil {
+		return nil, err
+	}
+	return &totpSetup{
+		Secret: secret,
+		URI:    uri,
+		QRCode: template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(png)),
+	}, nil
+}
+
+// pendingTOTPSetup - настройка TOTP: секрет создается при первом обращении и
+// сохраняется, чтобы обновление страницы не меняло уже отсканированный QR-код
+func (h *Handler) pendingTOTPSetup(ctx context.Context, user models.User, state *repository.TOTPState) (*totpSetup, error) {
+	if state.Secret == "" {
+		secret, err := auth.GenerateTOTPSecret()
+		if err != nil {
+			return nil, err
+		}
+		if err := h.TwoFactor.SetPendingSecret(ctx, user.ID, secret); err != nil {
+			return nil, err
+		}
+		state.Secret = secret
+	}
+	return h.newTOTPSetup(user.Username, state.Secret)
+}
+
+// completeLogin - завершение входа после проверки пароля: сессия создается сразу
+// или, если у пользователя включен (или обязателен для роли) TOTP, после второго шага
+func (h *Handler) completeLogin(w http.ResponseWriter, r *http.Request, user models.User, next string) {
+	state, err := h.TwoFactor.State(r.Context(), user.ID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка получения настроек 2FA", "err", err)
+		http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+		return
+	}
+
+	if !state.Enabled && !state.Required {
+		if err := h.startSession(w, r, user); err != nil {
+			slog.ErrorContext(r.Context(), "Ошибка создания сессии", "err", err)
+			http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, next, http.StatusFound)
+		return
+	}
+
+	token, err := auth.GenerateMFAToken(user.ID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка создания токена 2FA", "err", err)
+		http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, h.newCookie(mfaCookieName, token, loginTOTPPath, int(auth.MFATokenTTL.Seconds())))
+	http.Redirect(w, r, loginTOTPPath+"?next="+url.QueryEscape(next), http.StatusFound)
+}
+
+func (h *Handler) clearMFACookie(w http.ResponseWriter) {
+	http.SetCookie(w, h.newCookie(mfaCookieName, "", loginTOTPPath, -1))
+}
+
+// LoginTOTPHandler - второй шаг входа: код из приложения или код восстановления.
+// Если TOTP обязателен для роли, но еще не настроен, здесь же проходит настройка.
+func (h *Handler) LoginTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	next := safeRedirectTarget(r.FormValue("next"), "/profile")
+
+	userID := 0
+	if cookie, err := r.Cookie(mfaCookieName); err == nil {
+		userID, _ = auth.ValidateMFAToken(cookie.Value)
+	}
+	if userID == 0 {
+		// Промежуточный токен истек - начинаем вход заново
+		h.clearMFACookie(w)
+		http.Redirect(w, r, "/login?next="+url.QueryEscape(next), http.StatusFound)
+		return
+	}
+
+	user, err := h.Users.Get(r.Context(), userID)
+	var state repository.TOTPState
+	if err == nil {
+		state, err = h.TwoFactor.State(r.Context(), userID)
+	}
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			h.clearMFACookie(w)
+			http.Redirect(w, r, "/login", http.StatusFound)
+			return
+		}
+		slog.ErrorContext(r.Context(), "Ошибка получения настроек 2FA", "err", err)
+		http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+		return
+	}
+
+	// Пока шел вход, 2FA отключили и для роли она больше не обязательна
+	if !state.Enabled && !state.Required {
+		h.clearMFACookie(w)
+		h.completeLogin(w, r, user, next)
+		return
+	}
+
+	type LoginTOTPPageData struct {
+		models.PageData
+		Next          string
+		Setup         *totpSetup // не nil - TOTP нужно настроить
+		RecoveryCodes []string   // показываются один раз после настройки
+	}
+
+	data := LoginTOTPPageData{
+		PageData: models.PageData{
+			Title:       "Двухфакторная аутентификация",
+			CurrentPage: "login_2fa",
+		},
+		Next: next,
+	}
+
+	if !state.Enabled {
+		if data.Setup, err = h.pendingTOTPSetup(r.Context(), user, &state); err != nil {
+			slog.ErrorContext(r.Context(), "Ошибка подготовки настройки 2FA", "err", err)
+			http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if r.Method == http.MethodPost {
+		code := r.FormValue("code")
+
+		if state.Enabled {
+			blocked, err := h.loginBlocked(r.Context(), user.Username, clientIP(r))
+			ok, recovery := false, false
+			if err == nil && !blocked {
+				ok, recovery, err = h.verifySecondFactor(r.Context(), user.ID, state, code)
+			}
+
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Ошибка проверки кода 2FA", "err", err)
+				data.Error = "Ошибка сервера"
+			} else if !ok {
+				slog.WarnContext(r.Context(), "Неверный код 2FA", "user", user.Username)
+				if !blocked {
+					h.recordLoginFailure(r.Context(), user.Username, clientIP(r))
+				}
+				data.Error = "Неверный или уже использованный код"
+			} else {
+				if recovery {
+					slog.WarnContext(r.Context(), "Вход по коду восстановления", "user", user.Username)
+				}
+				h.clearMFACookie(w)
+				if err := h.startSession(w, r, user); err != nil {
+					slog.ErrorContext(r.Context(), "Ошибка создания сессии", "err", err)
+					http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+					return
+				}
+				http.Redirect(w, r, next, http.StatusFound)
+				return
+			}
+		} else if step, ok := h.TOTP.Validate(state.Secret, code, 0); !ok {
+			data.Error = "Неверный код. Проверьте время на телефоне и попробуйте еще раз"
+		} else if codes, err := h.enableTOTP(r.Context(), user.ID, step); err != nil {
+			slog.ErrorContext(r.Context(), "Ошибка включения 2FA", "err", err)
+			data.Error = "Ошибка сохранения в базу данных"
+		} else {
+			slog.InfoContext(r.Context(), "2FA настроена при входе", "user", user.Username)
+			h.clearMFACookie(w)
+			if err := h.startSession(w, r, user); err != nil {
+				slog.ErrorContext(r.Context(), "Ошибка создания сессии", "err", err)
+				http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+				return
+			}
+			data.Setup = nil
+			data.RecoveryCodes = codes
+		}
+	}
+
+	h.render(w, r, &data)
+}
+
+// ProfileTOTPHandler - включение и отключение 2FA, новые коды восстановления
+func (h *Handler) ProfileTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	claims := requestClaims(r)
+
+	user, err := h.Users.Get(r.Context(), claims.UserID)
+	var state repository.TOTPState
+	if err == nil {
+		state, err = h.TwoFactor.State(r.Context(), claims.UserID)
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка получения настроек 2FA", "err", err)
+		http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+		return
+	}
+
+	type ProfileTOTPPageData struct {
+		models.PageData
+		Enabled       bool
+		Required      bool
+		RecoveryLeft  int
+		Setup         *totpSetup
+		RecoveryCodes []string
+	}
+
+	data := ProfileTOTPPageData{
+		PageData: models.PageData{
+			Title:       "Двухфакторная аутентификация",
+			CurrentPage: "profile_2fa",
+		},
+		Required: state.Required,
+	}
+
+	if r.Method == http.MethodPost {
+		code := r.FormValue("code")
+
+		switch r.FormValue("action") {
+		case "enable":
+			if state.Enabled {
+				break
+			}
+			if step, ok := h.TOTP.Validate(state.Secret, code, 0); !ok {
+				data.Error = "Неверный код. Проверьте время на телефоне и попробуйте еще раз"
+			} else if codes, err := h.enableTOTP(r.Context(), user.ID, step); err != nil {
+				slog.ErrorContext(r.Context(), "Ошибка включения 2FA", "err", err)
+				data.Error = "Ошибка сохранения в базу данных"
+			} else {
+				slog.InfoContext(r.Context(), "2FA включена", "user", user.Username)
+				state.Enabled = true
+				data.RecoveryCodes = codes
+				data.Success = "Двухфакторная аутентификация включена"
+			}
+
+		case "recovery_codes":
+			if ok, _, err := h.verifySecondFactor(r.Context(), user.ID, state, code); err != nil || !ok {
+				data.Error = "Неверный или уже использованный код"
+			} else if codes, err := h.regenerateRecoveryCodes(r.Context(), user.ID); err != nil {
+				slog.ErrorContext(r.Context(), "Ошибка создания кодов восстановления", "err", err)
+				data.Error = "Ошибка сохранения в базу данных"
+			} else {
+				slog.InfoContext(r.Context(), "Выданы новые коды восстановления", "user", user.Username)
+				data.RecoveryCodes = codes
+				data.Success = "Созданы новые коды восстановления, старые больше не действуют"
+			}
+
+		case "disable":
+			if state.Required {
+				data.Error = "Для вашей роли двухфакторная аутентификация обязательна"
+			} else if ok, err := h.verifyUserPassword(r.Context(), user.ID, r.FormValue("password")); err != nil || !ok {
+				data.Error = "Текущий пароль указан неверно"
+			} else if ok, _, err := h.verifySecondFactor(r.Context(), user.ID, state, code); err != nil || !ok {
+				data.Error = "Неверный или уже использованный код"
+			} else if err := h.TwoFactor.Disable(r.Context(), user.ID); err != nil {
+				slog.ErrorContext(r.Context(), "Ошибка отключения 2FA", "err", err)
+				data.Error = "Ошибка сохранения в базу данных"
+			} else {
+				slog.InfoContext(r.Context(), "2FA отключена", "user", user.Username)
+				state = repository.TOTPState{Required: state.Required}
+				data.Success = "Двухфакторная аутентификация отключена"
+			}
+		}
+	}
+
+	data.Enabled = state.Enabled
+	if state.Enabled {
+		if data.RecoveryLeft, err = h.TwoFactor.CountRecoveryCodes(r.Context(), user.ID); err != nil {
+			slog.ErrorContext(r.Context(), "Ошибка подсчета кодов восстановления", "err", err)
+		}
+	} else if data.Setup, err = h.pendingTOTPSetup(r.Context(), user, &state); err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка подготовки настройки 2FA", "err", err)
+		http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+		return
+	}
+
+	h.render(w, r, &data)
+}
+
+// AdminResetUserTOTPHandler - сброс 2FA пользователя, потерявшего телефон и коды
+// восстановления (POST /admin/users/reset-2fa/{id})
+func (h *Handler) AdminResetUserTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	claims := requestClaims(r)
+
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := h.Users.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			http.NotFound(w, r)
+		} else {
+			slog.ErrorContext(r.Context(), "Ошибка получения пользователя", "err", err)
+			http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+		}
+		return
+	}
+	if err := checkManageUser(claims, user.Role); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if err := h.TwoFactor.Disable(r.Context(), id); err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка сброса 2FA пользователя", "target_user_id", id, "err", err)
+		http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+		return
+	}
+
+	slog.WarnContext(r.Context(), "2FA пользователя сброшена администратором", "admin", claims.Username, "target_user_id", id)
+	http.Redirect(w, r, "/admin/users/edit/"+strconv.Itoa(id), http.StatusFound)
+}
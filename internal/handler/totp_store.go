@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"context"
+
+	"cosmos/internal/auth"
+	"cosmos/internal/repository"
+)
+
+// enableTOTP - включение TOTP после подтверждения кодом и выдача новых кодов восстановления;
+// repository.ErrNotFound, если настройка не начиналась или TOTP уже включен
+func (h *Handler) enableTOTP(ctx context.Context, userID int, step int64) ([]string, error) {
+	codes, hashes, err := auth.NewRecoveryCodes(auth.RecoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.TwoFactor.Enable(ctx, userID, step, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// regenerateRecoveryCodes - замена всех кодов восстановления новыми
+func (h *Handler) regenerateRecoveryCodes(ctx context.Context, userID int) ([]string, error) {
+	codes, hashes, err := auth.NewRecoveryCodes(auth.RecoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.TwoFactor.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// verifySecondFactor - проверка кода из приложения или кода восстановления.
+// Принятый код сразу помечается использованным, поэтому повторно не сработает.
+func (h *Handler) verifySecondFactor(ctx context.Context, userID int, state repository.TOTPState, code string) (ok, recovery bool, err error) {
+	if !state.Enabled {
+		return false, false, nil
+	}
+
+	if step, valid := h.TOTP.Validate(state.Secret, code, state.LastStep); valid {
+		ok, err := h.TwoFactor.AcceptStep(ctx, userID, step)
+		return ok, false, err
+	}
+
+	ok, err = h.TwoFactor.UseRecoveryCode(ctx, userID, auth.HashToken(auth.NormalizeRecoveryCode(code)))
+	return ok, ok, err
+}
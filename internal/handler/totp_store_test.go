@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"cosmos/internal/auth"
+)
+
+// enableTestTOTP - включенная 2FA пользователя с часами, стоящими на *now: секрет и коды восстановления
+func enableTestTOTP(a *apiTest, userID int, now *time.Time) (string, []string) {
+	a.t.Helper()
+	ctx := a.t.Context()
+	a.h.TOTP = auth.NewTOTP(auth.ClockFunc(func() time.Time { return *now }))
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	if err := a.h.TwoFactor.SetPendingSecret(ctx, userID, secret); err != nil {
+		a.t.Fatalf("SetPendingSecret: %v", err)
+	}
+	codes, err := a.h.enableTOTP(ctx, userID, a.h.TOTP.Step(*now)-2)
+	if err != nil {
+		a.t.Fatalf("enableTOTP: %v", err)
+	}
+	return secret, codes
+}
+
+// Код приложения принимается один раз: повтор в том же шаге и старые шаги отклоняются
+func TestVerifySecondFactorRejectsReplay(t *testing.T) {
+	a := newAPITest(t)
+	ctx := t.Context()
+	user := a.createUser("pilot", "user")
+	now := time.Unix(1_800_000_000, 0)
+	secret, _ := enableTestTOTP(a, user.ID, &now)
+
+	verify := func(code string) bool {
+		t.Helper()
+		state, err := a.h.TwoFactor.State(ctx, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ok, recovery, err := a.h.verifySecondFactor(ctx, user.ID, state, code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if recovery {
+			t.Errorf("код %s принят как код восстановления", code)
+		}
+		return ok
+	}
+
+	code, _ := a.h.TOTP.CodeAt(secret, now)
+	if !verify(code) {
+		t.Fatal("текущий код не принят")
+	}
+	if verify(code) {
+		t.Error("повтор кода в том же шаге принят")
+	}
+
+	// Предыдущий шаг попадает в допуск по времени, но уже раньше принятого
+	previous, _ := a.h.TOTP.CodeAt(secret, now.Add(-30*time.Second))
+	if verify(previous) {
+		t.Error("код предыдущего шага принят после текущего")
+	}
+
+	// Хранилище само не дает принять шаг не новее последнего, даже по устаревшему состоянию
+	step := a.h.TOTP.Step(now)
+	if ok, err := a.h.TwoFactor.AcceptStep(ctx, user.ID, step); err != nil || ok {
+		t.Errorf("AcceptStep(%d) повторно = %v, %v", step, ok, err)
+	}
+
+	now = now.Add(30 * time.Second)
+	next, _ := a.h.TOTP.CodeAt(secret, now)
+	if !verify(next) {
+		t.Error("код следующего шага не принят")
+	}
+}
+
+// Код восстановления срабатывает один раз, в том числе введенный с пробелами
+func TestVerifySecondFactorRecoveryCodeSingleUse(t *testing.T) {
+	a := newAPITest(t)
+	ctx := t.Context()
+	user := a.createUser("pilot", "user")
+	now := time.Unix(1_800_000_000, 0)
+	_, codes := enableTestTOTP(a, user.ID, &now)
+	if len(codes) != auth.RecoveryCodeCount {
+		t.Fatalf("кодов восстановления %d, want %d", len(codes), auth.RecoveryCodeCount)
+	}
+
+	state, err := a.h.TwoFactor.State(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok, recovery, err := a.h.verifySecondFactor(ctx, user.ID, state, " "+codes[0]+" ")
+	if err != nil || !ok || !recovery {
+		t.Fatalf("первое использование: ok=%v recovery=%v err=%v", ok, recovery, err)
+	}
+	if n, err := a.h.TwoFactor.CountRecoveryCodes(ctx, user.ID); err != nil || n != auth.RecoveryCodeCount-1 {
+		t.Errorf("осталось кодов %d (%v), want %d", n, err, auth.RecoveryCodeCount-1)
+	}
+
+	ok, _, err = a.h.verifySecondFactor(ctx, user.ID, state, codes[0])
+	if err != nil || ok {
+		t.Errorf("повторное использование: ok=%v err=%v", ok, err)
+	}
+	if n, _ := a.h.TwoFactor.CountRecoveryCodes(ctx, user.ID); n != auth.RecoveryCodeCount-1 {
+		t.Errorf("после повтора осталось кодов %d", n)
+	}
+
+	// Новые коды заменяют старые целиком
+	fresh, err := a.h.regenerateRecoveryCodes(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, _, _ := a.h.verifySecondFactor(ctx, user.ID, state, codes[1]); ok {
+		t.Error("старый код принят после перевыпуска")
+	}
+	if ok, recovery, _ := a.h.verifySecondFactor(ctx, user.ID, state, fresh[1]); !ok || !recovery {
+		t.Error("новый код не принят")
+	}
+}
+
+// Вход через API с 2FA: код восстановления и код приложения не принимаются повторно
+func TestAPITokenSecondFactorSingleUse(t *testing.T) {
+	a := newAPITest(t)
+	user := a.createUser("pilot", "user")
+	now := time.Unix(1_800_000_000, 0)
+	secret, codes := enableTestTOTP(a, user.ID, &now)
+	// Без задержки после неудачи: повторы проверяются сразу, а не упираются в блокировку
+	a.h.LoginPolicy.BaseDelay = 0
+
+	login := func(code string) int {
+		t.Helper()
+		return a.do("", http.MethodPost, "/api/v1/auth/token", tokenRequest{
+			Username: user.Username, Password: testPassword, TOTPCode: code,
+		}).Code
+	}
+
+	if status := login(""); status != http.StatusUnauthorized {
+		t.Errorf("без кода: статус %d", status)
+	}
+
+	code, _ := a.h.TOTP.CodeAt(secret, now)
+	if status := login(code); status != http.StatusOK {
+		t.Errorf("код приложения: статус %d", status)
+	}
+	if status := login(code); status != http.StatusUnauthorized {
+		t.Errorf("повтор кода приложения: статус %d", status)
+	}
+
+	if status := login(codes[0]); status != http.StatusOK {
+		t.Errorf("код восстановления: статус %d", status)
+	}
+	if status := login(codes[0]); status != http.StatusUnauthorized {
+		t.Errorf("повтор кода восстановления: статус %d", status)
+	}
+}
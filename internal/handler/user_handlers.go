@@ -1,50 +1,29 @@
 package handler
 
 import (
-	"database/sql"
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
-	"strings"
 
 	"cosmos/internal/auth"
 	"cosmos/internal/models"
+	"cosmos/internal/repository"
 )
 
 // AdminUsersHandler - список пользователей в админке
 func (h *Handler) AdminUsersHandler(w http.ResponseWriter, r *http.Request) {
 	h.setEncoding(w)
 
-	// Проверяем авторизацию
-	_, err := h.requireAdminAuth(w, r)
-	if err != nil {
-		return
-	}
-
 	// Получаем пользователей из БД
-	rows, err := h.DB.Query(`
-		SELECT id, username, email, role, created_at
-		FROM users
-		ORDER BY id DESC
-	`)
+	users, err := h.Users.List(r.Context())
 	if err != nil {
-		log.Printf("Ошибка SQL запроса пользователей: %v", err)
+		slog.ErrorContext(r.Context(), "Ошибка SQL запроса пользователей", "err", err)
 		http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
-
-	var users []models.User
-	for rows.Next() {
-		var user models.User
-		err := rows.Scan(&user.ID, &user.Username, &user.Email, &user.Role, &user.CreatedAt)
-		if err != nil {
-			log.Printf("Ошибка сканирования пользователя: %v", err)
-			continue
-		}
-		users = append(users, user)
-	}
 
 	data := models.PageData{
 		Title:       "Управление пользователями",
@@ -53,83 +32,50 @@ func (h *Handler) AdminUsersHandler(w http.ResponseWriter, r *http.Request) {
 		IsAdmin:     true,
 	}
 
-	err = h.Tmpl.ExecuteTemplate(w, "base.html", data)
-	if err != nil {
-		log.Printf("Ошибка выполнения шаблона admin_users: %v", err)
-		http.Error(w, "Ошибка отображения страницы", http.StatusInternalServerError)
-	}
+	h.render(w, r, &data)
 }
 
 // AdminUserDetailHandler - просмотр пользователя
 func (h *Handler) AdminUserDetailHandler(w http.ResponseWriter, r *http.Request) {
 	h.setEncoding(w)
 
-	// Проверяем авторизацию
-	_, err := h.requireAdminAuth(w, r)
-	if err != nil {
-		return
-	}
-
-	// Извлекаем ID из URL
-	pathParts := strings.Split(r.URL.Path, "/")
-	if len(pathParts) != 4 {
-		http.NotFound(w, r)
-		return
-	}
-
-	id, err := strconv.Atoi(pathParts[2])
-	if err != nil {
-		http.NotFound(w, r)
+	id, ok := pathID(w, r)
+	if !ok {
 		return
 	}
 
 	// Получаем пользователя из БД
-	var user models.User
-	err = h.DB.QueryRow(`
-		SELECT id, username, email, role, created_at
-		FROM users
-		WHERE id = $1
-	`, id).Scan(&user.ID, &user.Username, &user.Email, &user.Role, &user.CreatedAt)
-
+	user, err := h.Users.Get(r.Context(), id)
 	if err != nil {
-		if err.Error() == "sql: no rows in result set" {
+		if errors.Is(err, repository.ErrNotFound) {
 			http.NotFound(w, r)
 		} else {
-			log.Printf("Ошибка получения пользователя: %v", err)
+			slog.ErrorContext(r.Context(), "Ошибка получения пользователя", "err", err)
 			http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
 		}
 		return
 	}
 
-	// Получаем статистику пользователя
-	var planetCount int
-	h.DB.QueryRow("SELECT COUNT(*) FROM planets WHERE created_by = $1", id).Scan(&planetCount)
+	roles, err := h.Roles.List(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка получения ролей", "err", err)
+	}
 
 	data := models.PageData{
 		Title:       "Просмотр пользователя: " + user.Username,
 		CurrentPage: "admin_user_form",
 		User:        &user,
-		PlanetCount: planetCount,
+		Roles:       roles,
 		IsAdmin:     true,
 	}
 
-	err = h.Tmpl.ExecuteTemplate(w, "base.html", data)
-	if err != nil {
-		log.Printf("Ошибка выполнения шаблона admin_user_detail: %v", err)
-		http.Error(w, "Ошибка отображения страницы", http.StatusInternalServerError)
-	}
+	h.render(w, r, &data)
 }
 
 // AdminNewUserHandler - форма создания нового пользователя
 func (h *Handler) AdminNewUserHandler(w http.ResponseWriter, r *http.Request) {
 	h.setEncoding(w)
 
-	// Проверяем авторизацию
-	_, err := h.requireAdminAuth(w, r)
-	if err != nil {
-		return
-	}
-
 	// Структура для данных формы
 	type FormData struct {
 		models.PageData
@@ -137,11 +83,17 @@ func (h *Handler) AdminNewUserHandler(w http.ResponseWriter, r *http.Request) {
 		Error string
 	}
 
+	roles, err := h.Roles.List(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка получения ролей", "err", err)
+	}
+
 	data := FormData{
 		PageData: models.PageData{
 			Title:       "Создание пользователя",
 			CurrentPage: "admin_user_form",
 			IsAdmin:     true,
+			Roles:       roles,
 		},
 		User: models.User{},
 	}
@@ -158,76 +110,34 @@ func (h *Handler) AdminNewUserHandler(w http.ResponseWriter, r *http.Request) {
 		data.User.Role = role
 
 		// Валидация
-		if username == "" || email == "" || password == "" || role == "" {
-			data.Error = "Все поля обязательны для заполнения"
-		} else if len(password) < 6 {
-			data.Error = "Пароль должен быть не менее 6 символов"
-		} else if role != "admin" && role != "user" {
-			data.Error = "Роль должна быть 'admin' или 'user'"
+		if err := h.validateUserFields(r.Context(), username, email, password, role, true); err != nil {
+			data.Error = err.Error()
+		} else if err := checkRoleChange(requestClaims(r), 0, auth.RoleUser, role); err != nil {
+			data.Error = err.Error()
+		} else if exists, _ := h.Users.Exists(r.Context(), username, email, 0); exists {
+			data.Error = "Пользователь с таким логином или email уже существует"
 		} else {
-			// Проверяем, нет ли уже такого пользователя
-			var exists bool
-			h.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE username = $1 OR email = $2)",
-				username, email).Scan(&exists)
-
-			if exists {
-				data.Error = "Пользователь с таким логином или email уже существует"
+			user, err := h.createUser(r.Context(), username, email, password, role)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Ошибка создания пользователя", "err", err)
+				data.Error = "Ошибка сохранения в базу данных"
 			} else {
-				// Хэшируем пароль
-				hashedPassword, err := auth.HashPassword(password)
-				if err != nil {
-					log.Printf("Ошибка хэширования пароля: %v", err)
-					data.Error = "Ошибка сервера"
-				} else {
-					// Сохраняем пользователя
-					var userID int
-					err := h.DB.QueryRow(
-						`INSERT INTO users (username, email, password_hash, role)
-                         VALUES ($1, $2, $3, $4) RETURNING id`,
-						username, email, hashedPassword, role,
-					).Scan(&userID)
-
-					if err != nil {
-						log.Printf("Ошибка создания пользователя: %v", err)
-						data.Error = "Ошибка сохранения в базу данных"
-					} else {
-						log.Printf("Создан пользователь: %s (ID: %d, роль: %s)", username, userID, role)
-						http.Redirect(w, r, "/admin/users", http.StatusFound)
-						return
-					}
-				}
+				slog.InfoContext(r.Context(), "Создан пользователь", "user", username, "id", user.ID, "role", role)
+				http.Redirect(w, r, "/admin/users", http.StatusFound)
+				return
 			}
 		}
 	}
 
-	err = h.Tmpl.ExecuteTemplate(w, "base.html", data)
-	if err != nil {
-		log.Printf("Ошибка выполнения шаблона admin_user_form: %v", err)
-		http.Error(w, "Ошибка отображения страницы", http.StatusInternalServerError)
-	}
+	h.render(w, r, &data)
 }
 
 // AdminEditUserHandler - форма редактирования пользователя
 func (h *Handler) AdminEditUserHandler(w http.ResponseWriter, r *http.Request) {
 	h.setEncoding(w)
 
-	// Проверяем авторизацию
-	_, err := h.requireAdminAuth(w, r)
-	if err != nil {
-		return
-	}
-
-	// Извлекаем ID из URL: /admin/users/edit/{id}
-	pathParts := strings.Split(r.URL.Path, "/")
-
-	if len(pathParts) != 5 {
-		http.NotFound(w, r)
-		return
-	}
-
-	id, err := strconv.Atoi(pathParts[4]) // pathParts[4] это ID
-	if err != nil {
-		http.NotFound(w, r)
+	id, ok := pathID(w, r)
+	if !ok {
 		return
 	}
 
@@ -241,28 +151,32 @@ func (h *Handler) AdminEditUserHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Получаем пользователя из БД
-	var user models.User
-	err = h.DB.QueryRow(`
-        SELECT id, username, email, role, created_at
-        FROM users
-        WHERE id = $1
-    `, id).Scan(&user.ID, &user.Username, &user.Email, &user.Role, &user.CreatedAt)
-
+	user, err := h.Users.Get(r.Context(), id)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, repository.ErrNotFound) {
 			http.NotFound(w, r)
 		} else {
-			log.Printf("Ошибка получения пользователя: %v", err)
+			slog.ErrorContext(r.Context(), "Ошибка получения пользователя", "err", err)
 			http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
 		}
 		return
 	}
+	if err := checkManageUser(requestClaims(r), user.Role); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	roles, err := h.Roles.List(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка получения ролей", "err", err)
+	}
 
 	data := FormData{
 		PageData: models.PageData{
 			Title:       "Редактирование пользователя: " + user.Username,
 			CurrentPage: "admin_user_form",
 			IsAdmin:     true,
+			Roles:       roles,
 		},
 		User: user,
 	}
@@ -280,89 +194,35 @@ func (h *Handler) AdminEditUserHandler(w http.ResponseWriter, r *http.Request) {
 		data.ShowPassword = password != ""
 
 		// Валидация
-		if username == "" || email == "" || role == "" {
-			data.Error = "Логин, email и роль обязательны"
-		} else if role != "admin" && role != "user" {
-			data.Error = "Роль должна быть 'admin' или 'user'"
-		} else if password != "" && len(password) < 6 {
-			data.Error = "Пароль должен быть не менее 6 символов"
+		if err := h.validateUserFields(r.Context(), username, email, password, role, false); err != nil {
+			data.Error = err.Error()
+		} else if err := checkRoleChange(requestClaims(r), id, user.Role, role); err != nil {
+			data.Error = err.Error()
+		} else if exists, _ := h.Users.Exists(r.Context(), username, email, id); exists {
+			data.Error = "Логин или email уже заняты другим пользователем"
 		} else {
-			// Проверяем, не занят ли логин/email другим пользователем
-			var exists bool
-			h.DB.QueryRow(
-				`SELECT EXISTS(SELECT 1 FROM users WHERE (username = $1 OR email = $2) AND id != $3)`,
-				username, email, id,
-			).Scan(&exists)
-
-			if exists {
-				data.Error = "Логин или email уже заняты другим пользователем"
+			// Обновляем пользователя (пустой пароль не меняется)
+			err := h.updateUser(r.Context(), id, username, email, role, password)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Ошибка обновления пользователя", "err", err)
+				data.Error = "Ошибка сохранения в базу данных"
 			} else {
-				// Обновляем пользователя
-				var query string
-				var args []interface{}
-
-				if password != "" {
-					// Обновляем с паролем
-					hashedPassword, err := auth.HashPassword(password)
-					if err != nil {
-						log.Printf("Ошибка хэширования пароля: %v", err)
-						data.Error = "Ошибка сервера"
-					} else {
-						query = `UPDATE users SET username = $1, email = $2, role = $3, password_hash = $4 WHERE id = $5`
-						args = []interface{}{username, email, role, hashedPassword, id}
-					}
-				} else {
-					// Обновляем без пароля
-					query = `UPDATE users SET username = $1, email = $2, role = $3 WHERE id = $4`
-					args = []interface{}{username, email, role, id}
-				}
-
-				if query != "" {
-					result, err := h.DB.Exec(query, args...)
-					if err != nil {
-						log.Printf("Ошибка обновления пользователя: %v", err)
-						data.Error = "Ошибка сохранения в базу данных"
-					} else {
-						rowsAffected, _ := result.RowsAffected()
-						if rowsAffected > 0 {
-							data.Success = "Пользователь успешно обновлен"
-							data.User.Username = username
-							data.User.Email = email
-							data.User.Role = role
-							log.Printf("Обновлен пользователь ID %d", id)
-						}
-					}
-				}
+				data.Success = "Пользователь успешно обновлен"
+				data.User.Username = username
+				data.User.Email = email
+				data.User.Role = role
+				slog.InfoContext(r.Context(), "Пользователь обновлен", "id", id)
 			}
 		}
 	}
 
-	err = h.Tmpl.ExecuteTemplate(w, "base.html", data)
-	if err != nil {
-		log.Printf("Ошибка выполнения шаблона admin_user_form (edit): %v", err)
-		http.Error(w, "Ошибка отображения страницы", http.StatusInternalServerError)
-	}
+	h.render(w, r, &data)
 }
 
 // AdminDeleteUserHandler - удаление пользователя
 func (h *Handler) AdminDeleteUserHandler(w http.ResponseWriter, r *http.Request) {
-	// Проверяем авторизацию
-	_, err := h.requireAdminAuth(w, r)
-	if err != nil {
-		return
-	}
-
-	// Извлекаем ID из URL: /admin/users/delete/{id}
-	pathParts := strings.Split(r.URL.Path, "/")
-
-	if len(pathParts) != 5 {
-		http.NotFound(w, r)
-		return
-	}
-
-	id, err := strconv.Atoi(pathParts[4]) // pathParts[4] это ID
-	if err != nil {
-		http.NotFound(w, r)
+	id, ok := pathID(w, r)
+	if !ok {
 		return
 	}
 
@@ -372,12 +232,7 @@ func (h *Handler) AdminDeleteUserHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Если POST запрос - выполняем удаление
-	if r.Method != http.MethodPost {
-		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
-		return
-	}
-
+	// POST запрос - выполняем удаление
 	// Нельзя удалить первого админа (ID=1)
 	if id == 1 {
 		http.Error(w, "Нельзя удалить главного администратора", http.StatusBadRequest)
@@ -385,50 +240,48 @@ func (h *Handler) AdminDeleteUserHandler(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Получаем имя пользователя для логирования
-	var username string
-	h.DB.QueryRow("SELECT username FROM users WHERE id = $1", id).Scan(&username)
-
-	// Удаляем пользователя
-	result, err := h.DB.Exec("DELETE FROM users WHERE id = $1", id)
+	user, err := h.Users.Get(r.Context(), id)
+	if err == nil {
+		if err := checkManageUser(requestClaims(r), user.Role); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		err = h.Users.Delete(r.Context(), id)
+	}
 	if err != nil {
-		log.Printf("Ошибка удаления пользователя %d: %v", id, err)
+		if errors.Is(err, repository.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		slog.ErrorContext(r.Context(), "Ошибка удаления пользователя", "id", id, "err", err)
 		http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
 		return
 	}
+	username := user.Username
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		http.NotFound(w, r)
-		return
-	}
-
-	log.Printf("Пользователь удален: %s (ID %d)", username, id)
+	slog.InfoContext(r.Context(), "Пользователь удален", "user", username, "id", id)
 	http.Redirect(w, r, "/admin/users?success=Пользователь+"+username+"+удален", http.StatusFound)
 }
 
 // Добавим функцию подтверждения удаления для пользователей
 func (h *Handler) showDeleteUserConfirmation(w http.ResponseWriter, r *http.Request, id int) {
-	log.Printf("showDeleteUserConfirmation вызван для ID: %d", id)
-
 	h.setEncoding(w)
 
 	// Получаем пользователя из БД
-	var user models.User
-	err := h.DB.QueryRow(`
-        SELECT id, username, email, role, created_at
-        FROM users
-        WHERE id = $1
-    `, id).Scan(&user.ID, &user.Username, &user.Email, &user.Role, &user.CreatedAt)
-
+	user, err := h.Users.Get(r.Context(), id)
 	if err != nil {
-		log.Printf("Ошибка получения пользователя для удаления: %v", err)
-		if err == sql.ErrNoRows {
+		slog.ErrorContext(r.Context(), "Ошибка получения пользователя для удаления", "err", err)
+		if errors.Is(err, repository.ErrNotFound) {
 			http.NotFound(w, r)
 		} else {
 			http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
 		}
 		return
 	}
+	if err := checkManageUser(requestClaims(r), user.Role); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
 
 	// Структура для данных страницы подтверждения
 	type DeleteData struct {
@@ -457,24 +310,123 @@ func (h *Handler) showDeleteUserConfirmation(w http.ResponseWriter, r *http.Requ
 		PlanetCount: 0,
 	}
 
-	log.Printf("Данные для шаблона пользователя: ObjectType=%s, ObjectName=%s, Role=%s",
-		data.ObjectType, data.ObjectName, user.Role)
+	h.render(w, r, &data)
+}
+
+//Вспомогательные методы для пользователей
+
+// checkManageUser - может ли вызывающий изменять и удалять пользователя с ролью
+// targetRole. Для администраторов права users.manage (в том числе у API ключа со
+// scope admin:users) недостаточно: иначе можно было бы сменить админу email или
+// пароль и войти от его имени.
+func checkManageUser(claims *auth.Claims, targetRole string) error {
+	if targetRole == auth.RoleAdmin && claims.Role != auth.RoleAdmin {
+		return errors.New("Изменять и удалять администраторов может только администратор")
+	}
+	return nil
+}
+
+// checkRoleChange - может ли вызывающий выдать роль newRole пользователю targetID
+// (0 - новому) вместо oldRole. Роли назначает только администратор, и никто не
+// меняет роль самому себе.
+func checkRoleChange(claims *auth.Claims, targetID int, oldRole, newRole string) error {
+	if newRole == oldRole {
+		return nil
+	}
+	if targetID != 0 && targetID == claims.UserID {
+		return errors.New("Нельзя изменить собственную роль")
+	}
+	if claims.Role != auth.RoleAdmin {
+		return errors.New("Назначать роли может только администратор")
+	}
+	return nil
+}
+
+// validateUserFields - общие правила для форм и API; роль должна быть в таблице roles.
+// passwordRequired=false означает, что пустой пароль оставляет текущий без изменений.
+func (h *Handler) validateUserFields(ctx context.Context, username, email, password, role string, passwordRequired bool) error {
+	if username == "" || email == "" || role == "" {
+		if passwordRequired {
+			return errors.New("Все поля обязательны для заполнения")
+		}
+		return errors.New("Логин, email и роль обязательны")
+	}
+	if passwordRequired && password == "" {
+		return errors.New("Все поля обязательны для заполнения")
+	}
+	if err := h.validatePassword(username, password, passwordRequired); err != nil {
+		return err
+	}
+
+	exists, err := h.Roles.Exists(ctx, role)
+	if err != nil {
+		slog.ErrorContext(ctx, "Ошибка проверки роли", "err", err)
+		return errors.New("Ошибка проверки роли")
+	}
+	if !exists {
+		return fmt.Errorf("Неизвестная роль %q", role)
+	}
+	return nil
+}
+
+// validatePassword - проверка нового пароля по политике паролей;
+// required=false допускает пустой пароль (пароль не меняется)
+func (h *Handler) validatePassword(username, password string, required bool) error {
+	if password == "" && !required {
+		return nil
+	}
+	return h.PasswordPolicy.Validate(username, password)
+}
+
+// createUser - хэширование пароля и сохранение нового пользователя
+func (h *Handler) createUser(ctx context.Context, username, email, password, role string) (models.User, error) {
+	user := models.User{Username: username, Email: email, Role: role}
+
+	hashedPassword, err := auth.HashPassword(password)
+	if err != nil {
+		return user, fmt.Errorf("ошибка хэширования пароля: %w", err)
+	}
+	user.PasswordHash = hashedPassword
+
+	err = h.Users.Create(ctx, &user)
+	return user, err
+}
+
+// updateUser - обновление пользователя; пустой password оставляет пароль прежним,
+// новый email снова считается неподтвержденным.
+// При смене роли все сессии пользователя отзываются, чтобы токены со старой ролью
+// перестали действовать сразу. Возвращает repository.ErrNotFound, если пользователя нет.
+func (h *Handler) updateUser(ctx context.Context, id int, username, email, role, password string) error {
+	old, err := h.Users.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	user := models.User{ID: id, Username: username, Email: email, Role: role}
+	if password != "" {
+		if user.PasswordHash, err = auth.HashPassword(password); err != nil {
+			return fmt.Errorf("ошибка хэширования пароля: %w", err)
+		}
+	}
+	if err := h.Users.Update(ctx, &user); err != nil {
+		return err
+	}
+
+	if old.Role != role {
+		revoked, err := h.Sessions.RevokeUser(ctx, id)
+		if err != nil {
+			return fmt.Errorf("ошибка отзыва сессий: %w", err)
+		}
+		slog.InfoContext(ctx, "Роль пользователя изменена", "id", id, "old_role", old.Role, "role", role, "revoked_sessions", revoked)
+	}
+	return nil
+}
 
-	// Пробуем выполнить шаблон
-	err = h.Tmpl.ExecuteTemplate(w, "admin_confirm_delete", data)
+// setUserPassword - смена пароля пользователя
+func (h *Handler) setUserPassword(ctx context.Context, id int, password string) error {
+	hashedPassword, err := auth.HashPassword(password)
 	if err != nil {
-		log.Printf("Ошибка выполнения шаблона admin_confirm_delete для пользователя: %v", err)
-
-		// Покажем простую страницу ошибки
-		fmt.Fprintf(w, `
-            <html><body style="background:#0a0a2a;color:white;padding:50px;">
-            <h1>Ошибка загрузки шаблона</h1>
-            <p>%v</p>
-            <p>ObjectType: %s</p>
-            <p>ObjectName: %s</p>
-            <p>Role: %s</p>
-            <a href="/admin/users">Назад к пользователям</a>
-            </body></html>
-        `, err, data.ObjectType, data.ObjectName, user.Role)
+		return fmt.Errorf("ошибка хэширования пароля: %w", err)
 	}
+	return h.Users.SetPasswordHash(ctx, id, hashedPassword)
 }
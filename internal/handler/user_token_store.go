@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"cosmos/internal/auth"
+	"cosmos/internal/models"
+	"cosmos/internal/repository"
+)
+
+// errActionTokenInvalid - токен из письма не найден, истек или уже использован
+var errActionTokenInvalid = errors.New("ссылка недействительна или устарела")
+
+// actionMailInterval - не чаще одного письма одного назначения пользователю
+const actionMailInterval = time.Minute
+
+// issueActionToken - новый одноразовый токен; прежние неиспользованные токены
+// того же назначения перестают действовать
+func (h *Handler) issueActionToken(ctx context.Context, user models.User, purpose string, ttl time.Duration) (string, error) {
+	token, jti, err := auth.GenerateActionToken(purpose, user.ID, user.Email, ttl)
+	if err != nil {
+		return "", err
+	}
+
+	stored := repository.ActionToken{JTI: jti, UserID: user.ID, Purpose: purpose, Email: user.Email}
+	if err := h.ActionTokens.Issue(ctx, stored, time.Now().Add(ttl)); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// actionToken - запись токена по его claims
+func actionToken(claims *auth.ActionClaims, purpose string) repository.ActionToken {
+	return repository.ActionToken{JTI: claims.ID, UserID: claims.UserID(), Purpose: purpose, Email: claims.Email}
+}
+
+// actionTokenClaims - проверка подписи токена, того, что он еще не использован,
+// и того, что адрес пользователя с момента отправки письма не менялся
+func (h *Handler) actionTokenClaims(ctx context.Context, token, purpose string) (*auth.ActionClaims, error) {
+	claims, err := auth.ValidateActionToken(token, purpose)
+	if err != nil {
+		return nil, errActionTokenInvalid
+	}
+
+	usable, err := h.ActionTokens.Usable(ctx, actionToken(claims, purpose))
+	if err != nil {
+		return nil, err
+	}
+	if !usable {
+		return nil, errActionTokenInvalid
+	}
+	return claims, nil
+}
+
+// actionMailRecentlySent - отправлялось ли письмо этого назначения за последнюю минуту
+func (h *Handler) actionMailRecentlySent(ctx context.Context, userID int, purpose string) (bool, error) {
+	return h.ActionTokens.IssuedWithin(ctx, userID, purpose, actionMailInterval)
+}
+
+// resetPassword - смена пароля по токену из письма. Токен гасится вместе со сменой,
+// поэтому второй раз по ссылке пароль не сменить. Если после отправки письма адрес
+// сменили, ссылка со старого адреса недействительна. Письмо пришло на адрес
+// пользователя, так что адрес заодно считается подтвержденным.
+func (h *Handler) resetPassword(ctx context.Context, claims *auth.ActionClaims, password string) error {
+	hashedPassword, err := auth.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	err = h.ActionTokens.ResetPassword(ctx, actionToken(claims, auth.PurposePasswordReset), hashedPassword)
+	if errors.Is(err, repository.ErrNotFound) {
+		return errActionTokenInvalid
+	}
+	return err
+}
+
+// verifyEmail - подтверждение адреса по токену. Если после отправки письма адрес
+// сменили, токен гасится, но новый адрес не подтверждается.
+func (h *Handler) verifyEmail(ctx context.Context, claims *auth.ActionClaims) error {
+	err := h.ActionTokens.VerifyEmail(ctx, actionToken(claims, auth.PurposeVerifyEmail))
+	if errors.Is(err, repository.ErrNotFound) {
+		return errActionTokenInvalid
+	}
+	return err
+}
@@ -0,0 +1,104 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Статусы проверки и сводного отчета
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// Check - проверка зависимости: nil, если она работает
+type Check func(ctx context.Context) error
+
+// Result - результат одной проверки. Текст ошибки только для логов: в ответ
+// открытых /healthz и /readyz он не попадает, так как может содержать адреса
+// хостов, ошибки драйвера БД и детали схемы.
+type Result struct {
+	Status     string `json:"status"`
+	Error      string `json:"-"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// Report - сводный отчет: ok, только если все проверки прошли
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// OK - все проверки прошли
+func (r Report) OK() bool {
+	return r.Status == StatusOK
+}
+
+// Checker - набор именованных проверок. Проверки выполняются параллельно,
+// каждая ограничена Timeout, чтобы зависшая БД не держала запрос оркестратора.
+type Checker struct {
+	Timeout time.Duration
+
+	mu     sync.RWMutex
+	checks map[string]Check
+}
+
+// New - набор проверок с таймаутом 2 секунды на проверку
+func New() *Checker {
+	return &Checker{Timeout: 2 * time.Second, checks: map[string]Check{}}
+}
+
+// Add - добавление проверки; проверка с тем же именем заменяется
+func (c *Checker) Add(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks[name] = check
+}
+
+// Run - выполнение всех проверок
+func (c *Checker) Run(ctx context.Context) Report {
+	c.mu.RLock()
+	checks := make(map[string]Check, len(c.checks))
+	for name, check := range c.checks {
+		checks[name] = check
+	}
+	c.mu.RUnlock()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(checks))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := c.run(ctx, check)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[name] = result
+			if result.Status != StatusOK {
+				report.Status = StatusFail
+			}
+		}()
+	}
+	wg.Wait()
+	return report
+}
+
+func (c *Checker) run(ctx context.Context, check Check) Result {
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	err := check(ctx)
+	result := Result{Status: StatusOK, DurationMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
@@ -0,0 +1,126 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Setup - логгер slog с выбранным форматом (text или json) и уровнем
+// (debug, info, warn, error). Записи, сделанные с контекстом запроса
+// (slog.InfoContext и т.п.), получают поля request_id и user_id.
+func Setup(w io.Writer, format, level string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("неизвестный уровень логирования %q", level)
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "text", "":
+		handler = slog.NewTextHandler(w, opts)
+	case "json":
+		handler = slog.NewJSONHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("неизвестный формат логов %q (text или json)", format)
+	}
+
+	return slog.New(contextHandler{handler}), nil
+}
+
+// contextHandler - добавляет к записи данные запроса из контекста
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if info := requestInfoFrom(ctx); info != nil {
+		record.AddAttrs(slog.String("request_id", info.ID))
+		if info.UserID != 0 {
+			record.AddAttrs(slog.Int("user_id", info.UserID))
+		}
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// requestInfo - данные запроса для логов. Хранится в контексте по указателю,
+// чтобы middleware авторизации мог дописать пользователя, а access log его увидел.
+type requestInfo struct {
+	ID     string
+	UserID int
+}
+
+type requestInfoKey struct{}
+
+func requestInfoFrom(ctx context.Context) *requestInfo {
+	if ctx == nil {
+		return nil
+	}
+	info, _ := ctx.Value(requestInfoKey{}).(*requestInfo)
+	return info
+}
+
+// WithRequestID - контекст с ID запроса
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestInfoKey{}, &requestInfo{ID: id})
+}
+
+// RequestID - ID запроса из контекста или пустая строка
+func RequestID(ctx context.Context) string {
+	if info := requestInfoFrom(ctx); info != nil {
+		return info.ID
+	}
+	return ""
+}
+
+// SetUserID - пользователь запроса, определенный после авторизации
+func SetUserID(ctx context.Context, userID int) {
+	if info := requestInfoFrom(ctx); info != nil {
+		info.UserID = userID
+	}
+}
+
+// UserID - пользователь запроса или 0 для гостя
+func UserID(ctx context.Context) int {
+	if info := requestInfoFrom(ctx); info != nil {
+		return info.UserID
+	}
+	return 0
+}
+
+// NewRequestID - случайный ID запроса (16 hex символов)
+func NewRequestID() string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// ValidRequestID - можно ли принять ID запроса от клиента или прокси:
+// не длиннее 64 символов из букв, цифр, '-', '_' и '.'. Иначе в логи
+// попадали бы произвольные строки от клиента.
+func ValidRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
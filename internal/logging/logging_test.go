@@ -0,0 +1,87 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// logRecord - одна JSON запись логгера из Setup
+func logRecord(t *testing.T, log func(*slog.Logger)) map[string]any {
+	t.Helper()
+	var buf bytes.Buffer
+	logger, err := Setup(&buf, "json", "info")
+	if err != nil {
+		t.Fatal(err)
+	}
+	log(logger)
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("запись %q: %v", buf.String(), err)
+	}
+	return record
+}
+
+// Записи с контекстом запроса получают request_id, а после авторизации и user_id
+func TestContextHandlerAddsRequestInfo(t *testing.T) {
+	ctx := WithRequestID(context.Background(), "req-1")
+
+	record := logRecord(t, func(l *slog.Logger) { l.InfoContext(ctx, "гость") })
+	if record["request_id"] != "req-1" {
+		t.Errorf("request_id = %v", record["request_id"])
+	}
+	if _, ok := record["user_id"]; ok {
+		t.Errorf("user_id у гостя: %v", record)
+	}
+
+	// Пользователь, записанный в контекст позже, виден тем, у кого контекст раньше
+	SetUserID(ctx, 42)
+	record = logRecord(t, func(l *slog.Logger) { l.With("planet", "Марс").InfoContext(ctx, "пользователь") })
+	if record["request_id"] != "req-1" || record["user_id"] != float64(42) || record["planet"] != "Марс" {
+		t.Errorf("запись %v", record)
+	}
+
+	record = logRecord(t, func(l *slog.Logger) { l.Info("без контекста") })
+	if _, ok := record["request_id"]; ok {
+		t.Errorf("request_id без контекста запроса: %v", record)
+	}
+}
+
+func TestSetup(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := Setup(&buf, "text", "warn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("скрыто")
+	logger.Warn("видно")
+	if out := buf.String(); strings.Contains(out, "скрыто") || !strings.Contains(out, "видно") {
+		t.Errorf("вывод %q", out)
+	}
+
+	if _, err := Setup(&buf, "xml", "info"); err == nil {
+		t.Error("неизвестный формат принят")
+	}
+	if _, err := Setup(&buf, "json", "verbose"); err == nil {
+		t.Error("неизвестный уровень принят")
+	}
+}
+
+func TestValidRequestID(t *testing.T) {
+	for id, want := range map[string]bool{
+		NewRequestID():          true,
+		"abc-123_X.y":           true,
+		"":                      false,
+		"id with spaces":        false,
+		"id\nforged=1":          false,
+		strings.Repeat("a", 65): false,
+	} {
+		if got := ValidRequestID(id); got != want {
+			t.Errorf("ValidRequestID(%q) = %v, want %v", id, got, want)
+		}
+	}
+}
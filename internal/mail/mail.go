@@ -0,0 +1,162 @@
+package mail
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	netmail "net/mail"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message - текстовое письмо
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer - отправка писем. Реализации: SMTPMailer для боевого режима,
+// FileMailer и WriterMailer для разработки и тестов.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// Config - настройки отправки писем
+type Config struct {
+	Driver   string // smtp, file или stdout
+	From     string
+	Host     string
+	Port     string
+	Username string
+	Password string
+	Dir      string // каталог для driver=file
+}
+
+// New - Mailer по настройкам
+func New(cfg Config) (Mailer, error) {
+	switch cfg.Driver {
+	case "smtp":
+		if cfg.Host == "" {
+			return nil, fmt.Errorf("MAIL_DRIVER=smtp: не задан SMTP_HOST")
+		}
+		return &SMTPMailer{
+			Addr:     net.JoinHostPort(cfg.Host, cfg.Port),
+			Host:     cfg.Host,
+			Username: cfg.Username,
+			Password: cfg.Password,
+			From:     cfg.From,
+		}, nil
+	case "file":
+		if cfg.Dir == "" {
+			return nil, fmt.Errorf("MAIL_DRIVER=file: не задан MAIL_DIR")
+		}
+		return &FileMailer{Dir: cfg.Dir, From: cfg.From}, nil
+	case "", "stdout":
+		return &WriterMailer{W: os.Stdout, From: cfg.From}, nil
+	}
+	return nil, fmt.Errorf("неизвестный MAIL_DRIVER %q: допустимы smtp, file, stdout", cfg.Driver)
+}
+
+// SMTPMailer - отправка через SMTP сервер (STARTTLS, если сервер его поддерживает)
+type SMTPMailer struct {
+	Addr     string // host:port
+	Host     string
+	Username string // пусто - без авторизации (локальный релей или тестовый SMTP)
+	Password string
+	From     string
+}
+
+// Send - отправка письма
+func (m *SMTPMailer) Send(msg Message) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	// В конверте SMTP нужен адрес без имени: "Cosmos <noreply@example.com>" -> noreply@example.com
+	from, err := netmail.ParseAddress(m.From)
+	if err != nil {
+		return fmt.Errorf("некорректный MAIL_FROM: %w", err)
+	}
+	return smtp.SendMail(m.Addr, auth, from.Address, []string{msg.To}, format(m.From, msg))
+}
+
+// FileMailer - письма в виде .eml файлов в каталоге
+type FileMailer struct {
+	Dir  string
+	From string
+}
+
+// Send - сохранение письма в файл
+func (m *FileMailer) Send(msg Message) error {
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+	name := time.Now().Format("20060102-150405.000") + "-" + sanitizeFileName(msg.To) + ".eml"
+	return os.WriteFile(filepath.Join(m.Dir, name), format(m.From, msg), 0o600)
+}
+
+// WriterMailer - вывод писем в поток (по умолчанию stdout)
+type WriterMailer struct {
+	W    io.Writer
+	From string
+
+	mu sync.Mutex
+}
+
+// Send - запись письма в поток
+func (m *WriterMailer) Send(msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var buf bytes.Buffer
+	buf.WriteString("----- письмо -----\r\n")
+	buf.Write(format(m.From, msg))
+	buf.WriteString("\r\n----- конец письма -----\r\n")
+	_, err := buf.WriteTo(m.W)
+	return err
+}
+
+// format - письмо в формате RFC 5322 с заголовками в UTF-8
+func format(from string, msg Message) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", stripNewlines(from))
+	fmt.Fprintf(&buf, "To: %s\r\n", stripNewlines(msg.To))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: <%s@cosmos>\r\n", messageID())
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	buf.WriteString(strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n"))
+	return buf.Bytes()
+}
+
+// stripNewlines - защита от подстановки заголовков через адрес
+func stripNewlines(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
+
+func messageID() string {
+	buf := make([]byte, 12)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+func sanitizeFileName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ':' || r < ' ' {
+			return '_'
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,235 @@
+package mail
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// smtpSink - минимальный SMTP сервер для тестов: принимает письма и запоминает
+// конверт и данные. STARTTLS не объявляет, AUTH PLAIN - если задан login.
+type smtpSink struct {
+	ln    net.Listener
+	login string // "user\x00pass"; пусто - без авторизации
+
+	mu       sync.Mutex
+	from     string
+	to       []string
+	data     string
+	authUser string
+}
+
+func newSMTPSink(t *testing.T, login string) *smtpSink {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s := &smtpSink{ln: ln, login: login}
+	t.Cleanup(func() { ln.Close() })
+	go s.serve()
+	return s
+}
+
+func (s *smtpSink) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *smtpSink) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 sink ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		cmd := strings.ToUpper(line)
+
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			if s.login != "" {
+				reply("250-sink")
+				reply("250 AUTH PLAIN")
+			} else {
+				reply("250 sink")
+			}
+		case strings.HasPrefix(cmd, "AUTH PLAIN "):
+			raw, _ := base64.StdEncoding.DecodeString(line[len("AUTH PLAIN "):])
+			// identity \x00 user \x00 pass
+			if strings.TrimPrefix(string(raw), "\x00") != s.login {
+				reply("535 authentication failed")
+				continue
+			}
+			s.mu.Lock()
+			s.authUser, _, _ = strings.Cut(s.login, "\x00")
+			s.mu.Unlock()
+			reply("235 ok")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			s.mu.Lock()
+			s.from = strings.Trim(line[len("MAIL FROM:"):], "<> ")
+			s.mu.Unlock()
+			reply("250 ok")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			s.mu.Lock()
+			s.to = append(s.to, strings.Trim(line[len("RCPT TO:"):], "<> "))
+			s.mu.Unlock()
+			reply("250 ok")
+		case cmd == "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(l, "."))
+			}
+			s.mu.Lock()
+			s.data = data.String()
+			s.mu.Unlock()
+			reply("250 queued")
+		case cmd == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+func (s *smtpSink) mailer(username, password string) *SMTPMailer {
+	host, port, _ := net.SplitHostPort(s.ln.Addr().String())
+	m, err := New(Config{Driver: "smtp", Host: host, Port: port, Username: username, Password: password,
+		From: "Cosmos <noreply@example.com>"})
+	if err != nil {
+		panic(err)
+	}
+	return m.(*SMTPMailer)
+}
+
+func TestSMTPMailerSend(t *testing.T) {
+	sink := newSMTPSink(t, "")
+
+	err := sink.mailer("", "").Send(Message{
+		To:      "alice@example.com",
+		Subject: "Сброс пароля",
+		Body:    "Строка 1\nСтрока 2\n.начинается с точки",
+	})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if sink.from != "noreply@example.com" {
+		t.Errorf("MAIL FROM = %q, want адрес без имени", sink.from)
+	}
+	if len(sink.to) != 1 || sink.to[0] != "alice@example.com" {
+		t.Errorf("RCPT TO = %v", sink.to)
+	}
+	for _, want := range []string{
+		"From: Cosmos <noreply@example.com>\r\n",
+		"To: alice@example.com\r\n",
+		"Subject: =?utf-8?q?",
+		"Content-Type: text/plain; charset=utf-8\r\n",
+		"\r\n\r\nСтрока 1\r\nСтрока 2\r\n.начинается с точки",
+	} {
+		if !strings.Contains(sink.data, want) {
+			t.Errorf("письмо не содержит %q:\n%s", want, sink.data)
+		}
+	}
+}
+
+func TestSMTPMailerAuth(t *testing.T) {
+	sink := newSMTPSink(t, "mailer\x00secret")
+
+	if err := sink.mailer("mailer", "secret").Send(Message{To: "bob@example.com", Subject: "s", Body: "b"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	sink.mu.Lock()
+	if sink.authUser != "mailer" {
+		t.Errorf("AUTH user = %q", sink.authUser)
+	}
+	sink.mu.Unlock()
+
+	if err := sink.mailer("mailer", "wrong").Send(Message{To: "bob@example.com", Subject: "s", Body: "b"}); err == nil {
+		t.Error("Send с неверным паролем прошел")
+	}
+}
+
+func TestSMTPMailerBadFrom(t *testing.T) {
+	sink := newSMTPSink(t, "")
+	m := sink.mailer("", "")
+	m.From = "not an address"
+	if err := m.Send(Message{To: "alice@example.com"}); err == nil {
+		t.Error("Send с некорректным From прошел")
+	}
+}
+
+func TestFormatStripsHeaderInjection(t *testing.T) {
+	raw := string(format("noreply@example.com", Message{
+		To:      "alice@example.com\r\nBcc: mallory@example.com",
+		Subject: "Привет\r\nBcc: mallory@example.com",
+		Body:    "text",
+	}))
+	headers, _, _ := strings.Cut(raw, "\r\n\r\n")
+	for _, line := range strings.Split(headers, "\r\n") {
+		if strings.HasPrefix(line, "Bcc:") {
+			t.Fatalf("в заголовки попал Bcc:\n%s", headers)
+		}
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	m := &FileMailer{Dir: dir, From: "noreply@example.com"}
+	if err := m.Send(Message{To: "a/b@example.com", Subject: "s", Body: "b"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil || len(files) != 1 {
+		t.Fatalf("файлы = %v, err = %v", files, err)
+	}
+	if name := files[0].Name(); strings.Contains(name, "/") || !strings.HasSuffix(name, "-a_b@example.com.eml") {
+		t.Errorf("имя файла = %q", name)
+	}
+}
+
+func TestWriterMailer(t *testing.T) {
+	var buf bytes.Buffer
+	m := &WriterMailer{W: &buf, From: "noreply@example.com"}
+	if err := m.Send(Message{To: "alice@example.com", Subject: "s", Body: "hello"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if out := buf.String(); !strings.Contains(out, "To: alice@example.com") || !strings.Contains(out, "hello") {
+		t.Errorf("вывод:\n%s", out)
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, cfg := range []Config{{Driver: "smtp"}, {Driver: "file"}, {Driver: "carrier-pigeon"}} {
+		if _, err := New(cfg); err == nil {
+			t.Errorf("New(%+v) без ошибки", cfg)
+		}
+	}
+}
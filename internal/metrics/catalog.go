@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"cosmos/internal/repository"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	planetsDesc  = prometheus.NewDesc("cosmos_planets", "Число планет в каталоге.", nil, nil)
+	galaxiesDesc = prometheus.NewDesc("cosmos_galaxies", "Число галактик в каталоге.", nil, nil)
+)
+
+// CatalogCollector - размер каталога. Запросы к БД выполняются при сборе
+// метрик, а не в обработчиках, и не чаще раза в TTL: частые опросы
+// с нескольких Prometheus не нагружают базу.
+type CatalogCollector struct {
+	Planets  repository.PlanetRepository
+	Galaxies repository.GalaxyRepository
+	TTL      time.Duration
+
+	mu        sync.Mutex
+	updatedAt time.Time
+	planets   int
+	galaxies  int
+}
+
+// NewCatalogCollector - сборщик с обновлением раз в 30 секунд
+func NewCatalogCollector(planets repository.PlanetRepository, galaxies repository.GalaxyRepository) *CatalogCollector {
+	return &CatalogCollector{Planets: planets, Galaxies: galaxies, TTL: 30 * time.Second}
+}
+
+func (c *CatalogCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- planetsDesc
+	ch <- galaxiesDesc
+}
+
+func (c *CatalogCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if time.Since(c.updatedAt) >= c.TTL {
+		if err := c.refresh(); err != nil {
+			// Без свежих данных метрики не отдаются, чтобы не показывать устаревшие числа
+			slog.Warn("Не удалось обновить метрики каталога", "err", err)
+			return
+		}
+	}
+
+	ch <- prometheus.MustNewConstMetric(planetsDesc, prometheus.GaugeValue, float64(c.planets))
+	ch <- prometheus.MustNewConstMetric(galaxiesDesc, prometheus.GaugeValue, float64(c.galaxies))
+}
+
+func (c *CatalogCollector) refresh() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	planets, err := c.Planets.Count(ctx)
+	if err != nil {
+		return err
+	}
+	galaxies, err := c.Galaxies.Count(ctx)
+	if err != nil {
+		return err
+	}
+
+	c.planets, c.galaxies, c.updatedAt = planets, galaxies, time.Now()
+	return nil
+}
@@ -0,0 +1,88 @@
+// Package metrics - метрики Prometheus: HTTP запросы, входы, пул соединений с БД
+// и размер каталога. Отдаются обработчиком Handler на /metrics.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry - реестр метрик приложения вместе с метриками Go и процесса
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cosmos_http_requests_total",
+		Help: "HTTP запросы по методу, шаблону маршрута и коду ответа.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "cosmos_http_request_duration_seconds",
+		Help:    "Время обработки HTTP запроса по методу и шаблону маршрута.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	logins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cosmos_logins_total",
+		Help: "Попытки входа: success - сессия создана, failure - неверный пароль или код 2FA, blocked - вход заблокирован.",
+	}, []string{"result"})
+)
+
+// Результаты входа для LoginAttempt
+const (
+	LoginSuccess = "success"
+	LoginFailure = "failure"
+	LoginBlocked = "blocked"
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpDuration,
+		logins,
+	)
+	// Нулевые значения видны сразу, а не после первого входа
+	for _, result := range []string{LoginSuccess, LoginFailure, LoginBlocked} {
+		logins.WithLabelValues(result)
+	}
+}
+
+// Handler - обработчик /metrics
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// unmatchedRoute - метка запросов, для которых маршрут не найден (404, 405)
+const unmatchedRoute = "unmatched"
+
+// ObserveHTTP - учет HTTP запроса; подключается через router.Observe
+func ObserveHTTP(r *http.Request, route string, status int, elapsed time.Duration) {
+	if route == "" {
+		route = unmatchedRoute
+	}
+	method := httpMethod(r.Method)
+	httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	httpDuration.WithLabelValues(method, route).Observe(elapsed.Seconds())
+}
+
+// httpMethod - метод для метки: произвольные методы от клиента сводятся к OTHER
+func httpMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete, http.MethodOptions:
+		return method
+	}
+	return "OTHER"
+}
+
+// LoginAttempt - учет попытки входа с результатом LoginSuccess, LoginFailure или LoginBlocked
+func LoginAttempt(result string) {
+	logins.WithLabelValues(result).Inc()
+}
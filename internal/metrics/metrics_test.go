@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"cosmos/internal/router"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// Метка route - шаблон маршрута, а не путь запроса: id в пути не размножают серии
+func TestObserveHTTPRouteLabel(t *testing.T) {
+	rt := router.New()
+	rt.HandleFunc("GET /api/v1/planets/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	h := router.Observe(ObserveHTTP)(rt)
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/api/v1/planets/1", nil),
+		httptest.NewRequest(http.MethodGet, "/api/v1/planets/2", nil),
+		httptest.NewRequest(http.MethodGet, "/api/v1/stars/3", nil),
+		httptest.NewRequest("PROPFIND", "/api/v1/planets/4", nil),
+	} {
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	for _, tc := range []struct {
+		method, route, status string
+		want                  float64
+	}{
+		{"GET", "/api/v1/planets/{id}", "200", 2},
+		{"GET", unmatchedRoute, "404", 1},
+		{"OTHER", unmatchedRoute, "405", 1},
+	} {
+		if got := testutil.ToFloat64(httpRequests.WithLabelValues(tc.method, tc.route, tc.status)); got != tc.want {
+			t.Errorf("%s %s %s: %v запросов, want %v", tc.method, tc.route, tc.status, got, tc.want)
+		}
+	}
+
+	families, err := Registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "route" && label.GetValue() != "/api/v1/planets/{id}" && label.GetValue() != unmatchedRoute {
+					t.Errorf("%s: метка route = %q", family.GetName(), label.GetValue())
+				}
+			}
+		}
+	}
+}
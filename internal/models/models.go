@@ -3,12 +3,14 @@ package models
 import "time"
 
 type User struct {
-	ID           int       `json:"id"`
-	Username     string    `json:"username"`
-	Email        string    `json:"email"`
-	PasswordHash string    `json:"-"`    // Не отдаем хэш пароля в JSON
-	Role         string    `json:"role"` // "admin" или "user"
-	CreatedAt    time.Time `json:"created_at"`
+	ID            int       `json:"id"`
+	Username      string    `json:"username"`
+	Email         string    `json:"email"`
+	PasswordHash  string    `json:"-"`    // Не отдаем хэш пароля в JSON
+	Role          string    `json:"role"` // имя роли из таблицы roles: admin, editor, viewer, user
+	TOTPEnabled   bool      `json:"totp_enabled"`
+	EmailVerified bool      `json:"email_verified"`
+	CreatedAt     time.Time `json:"created_at"`
 }
 
 type Planet struct {
@@ -32,12 +34,13 @@ type Galaxy struct {
 	ID                  int       `json:"id"`
 	Name                string    `json:"name"`
 	Type                string    `json:"type"`
-	DiameterLy          *float64  `json:"diameter_ly,omitempty"`
-	MassSuns            *float64  `json:"mass_suns,omitempty"`
-	DistanceFromEarthLy *float64  `json:"distance_from_earth_ly,omitempty"`
-	DiscoveredYear      *int      `json:"discovered_year,omitempty"`
+	DiameterLy          *float64  `json:"diameter_ly"` // null, если неизвестно
+	MassSuns            *float64  `json:"mass_suns"`
+	DistanceFromEarthLy *float64  `json:"distance_from_earth_ly"`
+	DiscoveredYear      *int      `json:"discovered_year"`
 	Description         string    `json:"description"`
 	CreatedAt           time.Time `json:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at"`
 }
 
 // LoginData - данные для формы входа
@@ -53,6 +56,71 @@ type AdminData struct {
 	Users []User
 }
 
+// Session - серверная сессия пользователя (refresh токен)
+type Session struct {
+	ID         int64     `json:"id"`
+	UserID     int       `json:"user_id"`
+	Username   string    `json:"username"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// APIKey - API ключ пользователя (без самого ключа)
+type APIKey struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"user_id"`
+	Username   string     `json:"username"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Expired - истек ли срок действия ключа
+func (k APIKey) Expired() bool {
+	return k.ExpiresAt != nil && k.ExpiresAt.Before(time.Now())
+}
+
+// Role - роль и ее права
+type Role struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// Viewer - вошедший пользователь, для которого отображается страница
+type Viewer struct {
+	ID          int
+	Username    string
+	Role        string
+	Permissions map[string]bool
+}
+
+// Can - есть ли у пользователя право
+func (v *Viewer) Can(permission string) bool {
+	return v != nil && v.Permissions[permission]
+}
+
+// IsAdmin - есть ли у пользователя доступ в админ-панель
+func (v *Viewer) IsAdmin() bool {
+	return v.Can("admin.access")
+}
+
+// LoginLock - счетчик неудачных входов по логину или IP
+type LoginLock struct {
+	Kind          string    `json:"kind"` // user или ip
+	Key           string    `json:"key"`
+	Failures      int       `json:"failures"`
+	LastFailureAt time.Time `json:"last_failure_at"`
+	LockedUntil   time.Time `json:"locked_until"`
+	Locked        bool      `json:"locked"`
+}
+
 // PageData - данные для передачи в HTML шаблоны
 type PageData struct {
 	Title       string
@@ -66,11 +134,22 @@ type PageData struct {
 	Galaxy      *Galaxy
 	Users       []User
 	User        *User
+	Roles       []Role
 	IsAdmin     bool
 	Username    string
 	Role        string
 	AppPort     string
 	Environment string
-	Error       string // для ошибок форм
-	Success     string // для успешных сообщений
+	Error       string  // для ошибок форм
+	Success     string  // для успешных сообщений
+	Viewer      *Viewer // вошедший пользователь (nil - гость), заполняется при рендеринге
+	CSRFToken   string  // значение скрытого поля csrf_token для форм, заполняется при рендеринге
+	CSPNonce    string  // nonce для inline <script> и <style>, заполняется при рендеринге
+
+	PasswordMinLength int // для подсказок в формах паролей, заполняется при рендеринге
+}
+
+// Page - доступ к PageData, в том числе встроенной в данные конкретной страницы
+func (p *PageData) Page() *PageData {
+	return p
 }
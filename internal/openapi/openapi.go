@@ -0,0 +1,326 @@
+// Package openapi строит документ OpenAPI 3 из таблицы маршрутов и Go структур.
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Version - версия спецификации OpenAPI, которую генерирует пакет
+const Version = "3.0.3"
+
+// Operation - описание одного эндпоинта для генерации спецификации
+type Operation struct {
+	Method      string
+	Path        string // шаблон пути с параметрами: /api/v1/planets/{id}
+	OperationID string
+	Summary     string
+	Tag         string
+	Secured     bool   // требуется Bearer токен
+	Permission  string // право роли, необходимое для вызова
+	Scope       string // scope, с которым операция доступна по API ключу
+	Request     any    // пример типа тела запроса, nil - без тела
+	Response    any    // пример типа тела ответа, nil - без тела
+	Status      int    // код успешного ответа
+	Errors      []int  // возможные коды ошибок
+}
+
+// Info - блок info документа
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Document - корень документа OpenAPI
+type Document struct {
+	OpenAPI    string                          `json:"openapi"`
+	Info       Info                            `json:"info"`
+	Paths      map[string]map[string]*OpObject `json:"paths"`
+	Components Components                      `json:"components"`
+}
+
+// Components - переиспользуемые схемы и схемы безопасности
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme - схема авторизации
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	In           string `json:"in,omitempty"`
+	Name         string `json:"name,omitempty"`
+}
+
+// OpObject - операция внутри paths
+type OpObject struct {
+	Tags        []string              `json:"tags,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	OperationID string                `json:"operationId,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+// Parameter - параметр пути
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+// RequestBody - тело запроса
+type RequestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+// Response - описание ответа
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+// MediaType - схема содержимого
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema - подмножество JSON Schema, используемое OpenAPI 3.0
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// ErrorSchemaName - имя компоненты, которой описываются все ответы с ошибками
+const ErrorSchemaName = "Error"
+
+const (
+	bearerScheme = "bearerAuth"
+	apiKeyScheme = "apiKeyAuth"
+)
+
+var pathParamRe = regexp.MustCompile(`\{([^}/]+)\}`)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Build - сборка документа по списку операций.
+// errorBody - пример типа тела ошибки, общий для всех эндпоинтов.
+func Build(info Info, errorBody any, ops []Operation) *Document {
+	g := &generator{schemas: map[string]*Schema{}}
+
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   map[string]map[string]*OpObject{},
+		Components: Components{
+			Schemas: g.schemas,
+			SecuritySchemes: map[string]*SecurityScheme{
+				bearerScheme: {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+				apiKeyScheme: {Type: "apiKey", In: "header", Name: "X-API-Key"},
+			},
+		},
+	}
+
+	errorSchema := g.namedSchema(ErrorSchemaName, reflect.TypeOf(errorBody))
+
+	for _, op := range ops {
+		item, ok := doc.Paths[op.Path]
+		if !ok {
+			item = map[string]*OpObject{}
+			doc.Paths[op.Path] = item
+		}
+		item[strings.ToLower(op.Method)] = g.operation(op, errorSchema)
+	}
+
+	return doc
+}
+
+type generator struct {
+	schemas map[string]*Schema
+}
+
+func (g *generator) operation(op Operation, errorSchema *Schema) *OpObject {
+	obj := &OpObject{
+		Summary:     op.Summary,
+		OperationID: op.OperationID,
+		Responses:   map[string]*Response{},
+	}
+	if op.Tag != "" {
+		obj.Tags = []string{op.Tag}
+	}
+
+	for _, match := range pathParamRe.FindAllStringSubmatch(op.Path, -1) {
+		obj.Parameters = append(obj.Parameters, Parameter{
+			Name:     match[1],
+			In:       "path",
+			Required: true,
+			Schema:   &Schema{Type: "integer", Format: "int64"},
+		})
+	}
+
+	if op.Request != nil {
+		obj.RequestBody = &RequestBody{
+			Required: true,
+			Content:  jsonContent(g.schemaFor(reflect.TypeOf(op.Request))),
+		}
+	}
+
+	if op.Secured {
+		obj.Security = []map[string][]string{{bearerScheme: {}}}
+		var notes []string
+		if op.Permission != "" {
+			notes = append(notes, "Требуется право `"+op.Permission+"`.")
+		}
+		if op.Scope != "" {
+			// Альтернативы: Bearer токен или API ключ
+			obj.Security = append(obj.Security, map[string][]string{apiKeyScheme: {}})
+			notes = append(notes, "Доступно по API ключу со scope `"+op.Scope+"`.")
+		}
+		obj.Description = strings.Join(notes, " ")
+	}
+
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := &Response{Description: http.StatusText(status)}
+	if op.Response != nil {
+		success.Content = jsonContent(g.schemaFor(reflect.TypeOf(op.Response)))
+	}
+	obj.Responses[strconv.Itoa(status)] = success
+
+	errs := append([]int(nil), op.Errors...)
+	sort.Ints(errs)
+	for _, code := range errs {
+		obj.Responses[strconv.Itoa(code)] = &Response{
+			Description: http.StatusText(code),
+			Content:     jsonContent(errorSchema),
+		}
+	}
+
+	return obj
+}
+
+func jsonContent(schema *Schema) map[string]*MediaType {
+	return map[string]*MediaType{"application/json": {Schema: schema}}
+}
+
+// namedSchema - регистрация структуры в components под заданным именем
+func (g *generator) namedSchema(name string, t reflect.Type) *Schema {
+	ref := &Schema{Ref: "#/components/schemas/" + name}
+	if _, ok := g.schemas[name]; ok {
+		return ref
+	}
+
+	// Заглушка защищает от бесконечной рекурсии на самоссылающихся типах
+	g.schemas[name] = &Schema{}
+	*g.schemas[name] = *g.structSchema(t)
+	return ref
+}
+
+func (g *generator) schemaFor(t reflect.Type) *Schema {
+	if t.Kind() == reflect.Pointer {
+		elem := t.Elem()
+		if elem.Kind() == reflect.Struct && elem != timeType {
+			return g.schemaFor(elem)
+		}
+		schema := g.schemaFor(elem)
+		schema.Nullable = true
+		return schema
+	}
+
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: g.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schemaFor(t.Elem())}
+	case reflect.Struct:
+		return g.namedSchema(schemaName(t), t)
+	}
+
+	return &Schema{}
+}
+
+// structSchema - схема объекта по json тегам полей
+func (g *generator) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		// Встроенные структуры без тега раскрываются в родителя
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			embedded := g.structSchema(field.Type)
+			for propName, prop := range embedded.Properties {
+				schema.Properties[propName] = prop
+			}
+			schema.Required = append(schema.Required, embedded.Required...)
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		schema.Properties[name] = g.schemaFor(field.Type)
+
+		omitempty := strings.Contains(opts, "omitempty")
+		if !omitempty && field.Type.Kind() != reflect.Pointer {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+
+	sort.Strings(schema.Required)
+	return schema
+}
+
+// schemaName - имя компоненты по имени Go типа (с заглавной буквы)
+func schemaName(t reflect.Type) string {
+	name := t.Name()
+	if name == "" {
+		return "Object"
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
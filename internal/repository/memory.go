@@ -0,0 +1,499 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"cosmos/internal/models"
+)
+
+// Memory - хранилище в памяти для всех репозиториев пакета. Нужно для тестов
+// и запуска без PostgreSQL; повторяет ограничения схемы: уникальность имен, логинов
+// и email, ссылки на существующие галактику и роль, каскадное удаление записей
+// пользователя. Роли и права - как после миграций.
+type Memory struct {
+	mu       sync.Mutex
+	planets  map[int]models.Planet
+	galaxies map[int]models.Galaxy
+	users    map[int]models.User
+
+	roles      map[string]memoryRole
+	totp       map[int]*memoryTOTP // по ID пользователя
+	sessions   map[int64]*memorySession
+	apiKeys    map[int]*memoryAPIKey
+	throttle   map[throttleKey]*models.LoginLock
+	tokens     map[string]*memoryActionToken // по jti
+	identities map[identityKey]*memoryIdentity
+
+	lastID struct {
+		planet, galaxy, user, apiKey int
+		session                      int64
+	}
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		planets:  map[int]models.Planet{},
+		galaxies: map[int]models.Galaxy{},
+		users:    map[int]models.User{},
+
+		roles:      defaultRoles(),
+		totp:       map[int]*memoryTOTP{},
+		sessions:   map[int64]*memorySession{},
+		apiKeys:    map[int]*memoryAPIKey{},
+		throttle:   map[throttleKey]*models.LoginLock{},
+		tokens:     map[string]*memoryActionToken{},
+		identities: map[identityKey]*memoryIdentity{},
+	}
+}
+
+// Planets - планеты хранилища
+func (m *Memory) Planets() PlanetRepository { return memoryPlanets{m} }
+
+// Galaxies - галактики хранилища
+func (m *Memory) Galaxies() GalaxyRepository { return memoryGalaxies{m} }
+
+// Users - пользователи хранилища
+func (m *Memory) Users() UserRepository { return memoryUsers{m} }
+
+// Roles - роли и права хранилища
+func (m *Memory) Roles() RoleRepository { return memoryRoles{m} }
+
+// Sessions - сессии хранилища
+func (m *Memory) Sessions() SessionRepository { return memorySessions{m} }
+
+// TOTP - секреты TOTP и коды восстановления хранилища
+func (m *Memory) TOTP() TOTPRepository { return memoryTOTPs{m} }
+
+// APIKeys - API ключи хранилища
+func (m *Memory) APIKeys() APIKeyRepository { return memoryAPIKeys{m} }
+
+// LoginThrottle - счетчики неудачных входов хранилища
+func (m *Memory) LoginThrottle() LoginThrottleRepository { return memoryLoginThrottle{m} }
+
+// ActionTokens - токены из писем хранилища
+func (m *Memory) ActionTokens() ActionTokenRepository { return memoryActionTokens{m} }
+
+// Identities - привязки SSO хранилища
+func (m *Memory) Identities() IdentityRepository { return memoryIdentities{m} }
+
+// sortByOrder - сортировка записей по имени или по убыванию ID
+func sortByOrder[T any](items []T, order Order, name func(T) string, id func(T) int) {
+	sort.Slice(items, func(i, j int) bool {
+		if order == NewestFirst {
+			return id(items[i]) > id(items[j])
+		}
+		return name(items[i]) < name(items[j])
+	})
+}
+
+// clone - копия значения по указателю: хранимые записи не должны меняться через
+// указатели вызывающего (например, при декодировании JSON поверх прочитанной записи)
+func clone[T any](v *T) *T {
+	if v == nil {
+		return nil
+	}
+	c := *v
+	return &c
+}
+
+func clonePlanet(p models.Planet) models.Planet {
+	p.GalaxyID, p.DiscoveredYear = clone(p.GalaxyID), clone(p.DiscoveredYear)
+	return p
+}
+
+func cloneGalaxy(g models.Galaxy) models.Galaxy {
+	g.DiameterLy, g.MassSuns = clone(g.DiameterLy), clone(g.MassSuns)
+	g.DistanceFromEarthLy, g.DiscoveredYear = clone(g.DistanceFromEarthLy), clone(g.DiscoveredYear)
+	return g
+}
+
+type memoryPlanets struct{ m *Memory }
+
+// withGalaxyName - планета с именем ее галактики, как после LEFT JOIN
+func (m *Memory) withGalaxyName(planet models.Planet) models.Planet {
+	planet = clonePlanet(planet)
+	planet.GalaxyName = ""
+	if planet.GalaxyID != nil {
+		planet.GalaxyName = m.galaxies[*planet.GalaxyID].Name
+	}
+	return planet
+}
+
+// checkPlanet - уникальность имени и существование галактики; вызывается под m.mu
+func (m *Memory) checkPlanet(planet *models.Planet, id int) error {
+	for _, other := range m.planets {
+		if other.ID != id && other.Name == planet.Name {
+			return ErrConflict
+		}
+	}
+	// Как в Postgres: galaxy_id <= 0 и год 0 сохраняются как NULL
+	if planet.GalaxyID != nil && *planet.GalaxyID <= 0 {
+		planet.GalaxyID = nil
+	}
+	if planet.DiscoveredYear != nil && *planet.DiscoveredYear == 0 {
+		planet.DiscoveredYear = nil
+	}
+	if planet.GalaxyID != nil {
+		if _, ok := m.galaxies[*planet.GalaxyID]; !ok {
+			return ErrReference
+		}
+	}
+	return nil
+}
+
+func (r memoryPlanets) List(ctx context.Context, order Order) ([]models.Planet, error) {
+	return r.filter(order, func(models.Planet) bool { return true }), nil
+}
+
+func (r memoryPlanets) ListByGalaxy(ctx context.Context, galaxyID int) ([]models.Planet, error) {
+	return r.filter(ByName, func(p models.Planet) bool {
+		return p.GalaxyID != nil && *p.GalaxyID == galaxyID
+	}), nil
+}
+
+func (r memoryPlanets) filter(order Order, match func(models.Planet) bool) []models.Planet {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	planets := []models.Planet{}
+	for _, planet := range r.m.planets {
+		if match(planet) {
+			planets = append(planets, r.m.withGalaxyName(planet))
+		}
+	}
+	sortByOrder(planets, order,
+		func(p models.Planet) string { return p.Name },
+		func(p models.Planet) int { return p.ID })
+	return planets
+}
+
+func (r memoryPlanets) Get(ctx context.Context, id int) (models.Planet, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	planet, ok := r.m.planets[id]
+	if !ok {
+		return planet, ErrNotFound
+	}
+	return r.m.withGalaxyName(planet), nil
+}
+
+func (r memoryPlanets) Count(ctx context.Context) (int, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	return len(r.m.planets), nil
+}
+
+func (r memoryPlanets) CountByGalaxy(ctx context.Context, galaxyID int) (int, error) {
+	planets, _ := r.ListByGalaxy(ctx, galaxyID)
+	return len(planets), nil
+}
+
+func (r memoryPlanets) Create(ctx context.Context, planet *models.Planet) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	if err := r.m.checkPlanet(planet, 0); err != nil {
+		return err
+	}
+	r.m.lastID.planet++
+	planet.ID = r.m.lastID.planet
+	planet.CreatedAt = time.Now()
+	planet.UpdatedAt = planet.CreatedAt
+	r.m.planets[planet.ID] = clonePlanet(*planet)
+	return nil
+}
+
+func (r memoryPlanets) Update(ctx context.Context, id int, planet *models.Planet) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	old, ok := r.m.planets[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if err := r.m.checkPlanet(planet, id); err != nil {
+		return err
+	}
+
+	stored := clonePlanet(*planet)
+	stored.ID, stored.CreatedAt, stored.UpdatedAt = id, old.CreatedAt, time.Now()
+	r.m.planets[id] = stored
+	planet.UpdatedAt = stored.UpdatedAt
+	return nil
+}
+
+func (r memoryPlanets) Delete(ctx context.Context, id int) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	if _, ok := r.m.planets[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.m.planets, id)
+	return nil
+}
+
+type memoryGalaxies struct{ m *Memory }
+
+// checkGalaxyName - уникальность имени галактики; вызывается под m.mu
+func (m *Memory) checkGalaxyName(name string, id int) error {
+	for _, other := range m.galaxies {
+		if other.ID != id && other.Name == name {
+			return ErrConflict
+		}
+	}
+	return nil
+}
+
+func (r memoryGalaxies) List(ctx context.Context, order Order) ([]models.Galaxy, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	galaxies := make([]models.Galaxy, 0, len(r.m.galaxies))
+	for _, galaxy := range r.m.galaxies {
+		galaxies = append(galaxies, cloneGalaxy(galaxy))
+	}
+	sortByOrder(galaxies, order,
+		func(g models.Galaxy) string { return g.Name },
+		func(g models.Galaxy) int { return g.ID })
+	return galaxies, nil
+}
+
+func (r memoryGalaxies) Get(ctx context.Context, id int) (models.Galaxy, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	galaxy, ok := r.m.galaxies[id]
+	if !ok {
+		return galaxy, ErrNotFound
+	}
+	return cloneGalaxy(galaxy), nil
+}
+
+func (r memoryGalaxies) Count(ctx context.Context) (int, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	return len(r.m.galaxies), nil
+}
+
+func (r memoryGalaxies) Create(ctx context.Context, galaxy *models.Galaxy) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	if err := r.m.checkGalaxyName(galaxy.Name, 0); err != nil {
+		return err
+	}
+	r.m.lastID.galaxy++
+	galaxy.ID = r.m.lastID.galaxy
+	galaxy.CreatedAt = time.Now()
+	galaxy.UpdatedAt = galaxy.CreatedAt
+	r.m.galaxies[galaxy.ID] = cloneGalaxy(*galaxy)
+	return nil
+}
+
+func (r memoryGalaxies) Update(ctx context.Context, id int, galaxy *models.Galaxy) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	old, ok := r.m.galaxies[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if err := r.m.checkGalaxyName(galaxy.Name, id); err != nil {
+		return err
+	}
+
+	stored := cloneGalaxy(*galaxy)
+	stored.ID, stored.CreatedAt, stored.UpdatedAt = id, old.CreatedAt, time.Now()
+	r.m.galaxies[id] = stored
+	galaxy.UpdatedAt = stored.UpdatedAt
+	return nil
+}
+
+func (r memoryGalaxies) DeleteDetachingPlanets(ctx context.Context, id int) (int64, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	if _, ok := r.m.galaxies[id]; !ok {
+		return 0, ErrNotFound
+	}
+
+	var detached int64
+	for planetID, planet := range r.m.planets {
+		if planet.GalaxyID != nil && *planet.GalaxyID == id {
+			planet.GalaxyID = nil
+			planet.UpdatedAt = time.Now()
+			r.m.planets[planetID] = planet
+			detached++
+		}
+	}
+	delete(r.m.galaxies, id)
+	return detached, nil
+}
+
+type memoryUsers struct{ m *Memory }
+
+func (r memoryUsers) List(ctx context.Context) ([]models.User, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	users := make([]models.User, 0, len(r.m.users))
+	for _, user := range r.m.users {
+		users = append(users, user)
+	}
+	sortByOrder(users, NewestFirst,
+		func(u models.User) string { return u.Username },
+		func(u models.User) int { return u.ID })
+	return users, nil
+}
+
+func (r memoryUsers) Get(ctx context.Context, id int) (models.User, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	user, ok := r.m.users[id]
+	if !ok {
+		return user, ErrNotFound
+	}
+	return user, nil
+}
+
+func (r memoryUsers) GetByUsername(ctx context.Context, username string) (models.User, error) {
+	return r.find(func(u models.User) bool { return u.Username == username })
+}
+
+func (r memoryUsers) GetByEmail(ctx context.Context, email string) (models.User, error) {
+	return r.find(func(u models.User) bool { return u.Email == email })
+}
+
+func (r memoryUsers) find(match func(models.User) bool) (models.User, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	for _, user := range r.m.users {
+		if match(user) {
+			return user, nil
+		}
+	}
+	return models.User{}, ErrNotFound
+}
+
+// userTaken - занят ли логин или email кем-то, кроме excludeID; вызывается под m.mu
+func (m *Memory) userTaken(username, email string, excludeID int) bool {
+	for _, user := range m.users {
+		if user.ID != excludeID && (user.Username == username || user.Email == email) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r memoryUsers) Exists(ctx context.Context, username, email string, excludeID int) (bool, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	return r.m.userTaken(username, email, excludeID), nil
+}
+
+func (r memoryUsers) CountByRole(ctx context.Context, role string) (int, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	count := 0
+	for _, user := range r.m.users {
+		if user.Role == role {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r memoryUsers) Create(ctx context.Context, user *models.User) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	return r.m.addUser(user)
+}
+
+// addUser - сохранение нового пользователя; вызывается под m.mu
+func (m *Memory) addUser(user *models.User) error {
+	if m.userTaken(user.Username, user.Email, 0) {
+		return ErrConflict
+	}
+	if _, ok := m.roles[user.Role]; !ok {
+		return ErrReference
+	}
+
+	m.lastID.user++
+	user.ID = m.lastID.user
+	user.CreatedAt = time.Now()
+	m.users[user.ID] = *user
+	return nil
+}
+
+func (r memoryUsers) Update(ctx context.Context, user *models.User) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	stored, ok := r.m.users[user.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	if r.m.userTaken(user.Username, user.Email, user.ID) {
+		return ErrConflict
+	}
+	if _, ok := r.m.roles[user.Role]; !ok {
+		return ErrReference
+	}
+	if stored.Email != user.Email {
+		stored.EmailVerified = false
+	}
+	stored.Username, stored.Email, stored.Role = user.Username, user.Email, user.Role
+	if user.PasswordHash != "" {
+		stored.PasswordHash = user.PasswordHash
+	}
+	r.m.users[user.ID] = stored
+	user.EmailVerified = stored.EmailVerified
+	return nil
+}
+
+func (r memoryUsers) SetPasswordHash(ctx context.Context, id int, hash string) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	user, ok := r.m.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+	user.PasswordHash = hash
+	r.m.users[id] = user
+	return nil
+}
+
+func (r memoryUsers) ReplacePasswordHash(ctx context.Context, id int, oldHash, newHash string) (bool, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	user, ok := r.m.users[id]
+	if !ok || user.PasswordHash != oldHash {
+		return false, nil
+	}
+	user.PasswordHash = newHash
+	r.m.users[id] = user
+	return true, nil
+}
+
+func (r memoryUsers) Delete(ctx context.Context, id int) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	if _, ok := r.m.users[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.m.users, id)
+	r.m.deleteUserRecords(id)
+	return nil
+}
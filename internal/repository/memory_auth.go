@@ -0,0 +1,723 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"cosmos/internal/models"
+)
+
+type memoryRole struct {
+	description string
+	permissions []string // по имени
+	requireTOTP bool
+}
+
+// defaultRoles - роли и права, как их создают миграции 004 и 005
+func defaultRoles() map[string]memoryRole {
+	return map[string]memoryRole{
+		"admin": {description: "Администратор", permissions: []string{
+			"admin.access", "api_keys.manage", "galaxies.delete", "galaxies.write",
+			"planets.delete", "planets.write", "sessions.manage", "users.manage", "users.view",
+		}},
+		"editor": {description: "Редактор", permissions: []string{
+			"admin.access", "galaxies.delete", "galaxies.write", "planets.delete", "planets.write",
+		}},
+		"viewer": {description: "Наблюдатель", permissions: []string{"admin.access", "users.view"}},
+		"user":   {description: "Пользователь", permissions: []string{}},
+	}
+}
+
+// AddRole - своя роль с правами permissions, как ее создал бы администратор в таблице roles
+func (m *Memory) AddRole(name, description string, permissions ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.roles[name] = memoryRole{description: description, permissions: permissions}
+}
+
+// SetRequireTOTP - обязателен ли второй фактор для роли (roles.require_totp)
+func (m *Memory) SetRequireTOTP(role string, required bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if r, ok := m.roles[role]; ok {
+		r.requireTOTP = required
+		m.roles[role] = r
+	}
+}
+
+// deleteUserRecords - записи, которые в схеме удаляются вместе с пользователем
+// (ON DELETE CASCADE); вызывается под m.mu
+func (m *Memory) deleteUserRecords(userID int) {
+	delete(m.totp, userID)
+	for id, s := range m.sessions {
+		if s.UserID == userID {
+			delete(m.sessions, id)
+		}
+	}
+	for id, k := range m.apiKeys {
+		if k.UserID == userID {
+			delete(m.apiKeys, id)
+		}
+	}
+	for jti, t := range m.tokens {
+		if t.UserID == userID {
+			delete(m.tokens, jti)
+		}
+	}
+	for key, i := range m.identities {
+		if i.userID == userID {
+			delete(m.identities, key)
+		}
+	}
+}
+
+type memoryRoles struct{ m *Memory }
+
+func (r memoryRoles) List(ctx context.Context) ([]models.Role, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	roles := make([]models.Role, 0, len(r.m.roles))
+	for name, role := range r.m.roles {
+		roles = append(roles, models.Role{
+			Name:        name,
+			Description: role.description,
+			Permissions: append([]string{}, role.permissions...),
+		})
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+	return roles, nil
+}
+
+func (r memoryRoles) Exists(ctx context.Context, role string) (bool, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	_, ok := r.m.roles[role]
+	return ok, nil
+}
+
+func (r memoryRoles) Permissions(ctx context.Context, role string) (map[string]bool, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	permissions := map[string]bool{}
+	for _, permission := range r.m.roles[role].permissions {
+		permissions[permission] = true
+	}
+	return permissions, nil
+}
+
+func (r memoryRoles) HasPermission(ctx context.Context, role, permission string) (bool, error) {
+	permissions, err := r.Permissions(ctx, role)
+	return permissions[permission], err
+}
+
+type memorySession struct {
+	models.Session
+	tokenHash    string
+	previousHash string
+	revoked      bool
+}
+
+// live - не отозвана и не истекла
+func (s *memorySession) live(now time.Time) bool {
+	return !s.revoked && s.ExpiresAt.After(now)
+}
+
+type memorySessions struct{ m *Memory }
+
+func (r memorySessions) Create(ctx context.Context, userID int, tokenHash, userAgent, ip string, expiresAt time.Time) (int64, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	if _, ok := r.m.users[userID]; !ok {
+		return 0, ErrReference
+	}
+
+	now := time.Now()
+	r.m.lastID.session++
+	r.m.sessions[r.m.lastID.session] = &memorySession{
+		Session: models.Session{
+			ID: r.m.lastID.session, UserID: userID, UserAgent: userAgent, IPAddress: ip,
+			CreatedAt: now, LastUsedAt: now, ExpiresAt: expiresAt,
+		},
+		tokenHash: tokenHash,
+	}
+	return r.m.lastID.session, nil
+}
+
+func (r memorySessions) Rotate(ctx context.Context, tokenHash, newHash, userAgent, ip string, expiresAt time.Time) (SessionOwner, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	now := time.Now()
+	for _, s := range r.m.sessions {
+		if s.tokenHash != tokenHash || !s.live(now) {
+			continue
+		}
+		s.previousHash, s.tokenHash = s.tokenHash, newHash
+		s.LastUsedAt, s.ExpiresAt, s.UserAgent, s.IPAddress = now, expiresAt, userAgent, ip
+
+		user := r.m.users[s.UserID]
+		return SessionOwner{SessionID: s.ID, UserID: user.ID, Username: user.Username, Role: user.Role}, nil
+	}
+	return SessionOwner{}, ErrNotFound
+}
+
+func (r memorySessions) ByPreviousToken(ctx context.Context, tokenHash string, within time.Duration) (int64, int, bool, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	for _, s := range r.m.sessions {
+		if s.previousHash == tokenHash && !s.revoked {
+			return s.ID, s.UserID, s.LastUsedAt.After(time.Now().Add(-within)), nil
+		}
+	}
+	return 0, 0, false, ErrNotFound
+}
+
+func (r memorySessions) Valid(ctx context.Context, id int64, userID int, role string) (bool, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	s, ok := r.m.sessions[id]
+	if !ok || s.UserID != userID || !s.live(time.Now()) {
+		return false, nil
+	}
+	return r.m.users[userID].Role == role, nil
+}
+
+func (r memorySessions) Revoke(ctx context.Context, id int64) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	if s, ok := r.m.sessions[id]; ok {
+		s.revoked = true
+	}
+	return nil
+}
+
+func (r memorySessions) RevokeByToken(ctx context.Context, tokenHash string) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	for _, s := range r.m.sessions {
+		if s.tokenHash == tokenHash {
+			s.revoked = true
+		}
+	}
+	return nil
+}
+
+func (r memorySessions) RevokeUser(ctx context.Context, userID int) (int64, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	var revoked int64
+	for _, s := range r.m.sessions {
+		if s.UserID == userID && !s.revoked {
+			s.revoked = true
+			revoked++
+		}
+	}
+	return revoked, nil
+}
+
+func (r memorySessions) ListActive(ctx context.Context) ([]models.Session, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	now := time.Now()
+	sessions := []models.Session{}
+	for _, s := range r.m.sessions {
+		if s.live(now) {
+			session := s.Session
+			session.Username = r.m.users[s.UserID].Username
+			sessions = append(sessions, session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt) })
+	return sessions, nil
+}
+
+type memoryTOTP struct {
+	secret   string
+	lastStep int64
+	codes    map[string]bool // хэш кода восстановления -> использован
+}
+
+type memoryTOTPs struct{ m *Memory }
+
+// state - TOTP пользователя, созданный при первом обращении; вызывается под m.mu
+func (r memoryTOTPs) state(userID int) *memoryTOTP {
+	t, ok := r.m.totp[userID]
+	if !ok {
+		t = &memoryTOTP{codes: map[string]bool{}}
+		r.m.totp[userID] = t
+	}
+	return t
+}
+
+func (r memoryTOTPs) State(ctx context.Context, userID int) (TOTPState, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	user, ok := r.m.users[userID]
+	if !ok {
+		return TOTPState{}, ErrNotFound
+	}
+	t := r.state(userID)
+	return TOTPState{
+		Secret:   t.secret,
+		Enabled:  user.TOTPEnabled,
+		LastStep: t.lastStep,
+		Required: r.m.roles[user.Role].requireTOTP,
+	}, nil
+}
+
+func (r memoryTOTPs) SetPendingSecret(ctx context.Context, userID int, secret string) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	if user, ok := r.m.users[userID]; ok && !user.TOTPEnabled {
+		r.state(userID).secret = secret
+	}
+	return nil
+}
+
+func (r memoryTOTPs) Enable(ctx context.Context, userID int, step int64, codeHashes []string) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	user, ok := r.m.users[userID]
+	if !ok || user.TOTPEnabled || r.state(userID).secret == "" {
+		return ErrNotFound
+	}
+	user.TOTPEnabled = true
+	r.m.users[userID] = user
+
+	t := r.state(userID)
+	t.lastStep = step
+	r.replaceCodes(t, codeHashes)
+	return nil
+}
+
+func (r memoryTOTPs) Disable(ctx context.Context, userID int) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	if user, ok := r.m.users[userID]; ok {
+		user.TOTPEnabled = false
+		r.m.users[userID] = user
+	}
+	delete(r.m.totp, userID)
+	return nil
+}
+
+func (r memoryTOTPs) ReplaceRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	if _, ok := r.m.users[userID]; !ok {
+		return ErrReference
+	}
+	r.replaceCodes(r.state(userID), codeHashes)
+	return nil
+}
+
+func (r memoryTOTPs) replaceCodes(t *memoryTOTP, codeHashes []string) {
+	t.codes = map[string]bool{}
+	for _, hash := range codeHashes {
+		t.codes[hash] = false
+	}
+}
+
+func (r memoryTOTPs) CountRecoveryCodes(ctx context.Context, userID int) (int, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	count := 0
+	if t, ok := r.m.totp[userID]; ok {
+		for _, used := range t.codes {
+			if !used {
+				count++
+			}
+		}
+	}
+	return count, nil
+}
+
+func (r memoryTOTPs) AcceptStep(ctx context.Context, userID int, step int64) (bool, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	if _, ok := r.m.users[userID]; !ok {
+		return false, nil
+	}
+	t := r.state(userID)
+	if t.lastStep >= step {
+		return false, nil
+	}
+	t.lastStep = step
+	return true, nil
+}
+
+func (r memoryTOTPs) UseRecoveryCode(ctx context.Context, userID int, codeHash string) (bool, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	t, ok := r.m.totp[userID]
+	if !ok {
+		return false, nil
+	}
+	if used, exists := t.codes[codeHash]; !exists || used {
+		return false, nil
+	}
+	t.codes[codeHash] = true
+	return true, nil
+}
+
+type memoryAPIKey struct {
+	models.APIKey
+	hash string
+}
+
+type memoryAPIKeys struct{ m *Memory }
+
+// withUsername - копия ключа с логином владельца, как после JOIN; вызывается под m.mu
+func (r memoryAPIKeys) withUsername(k *memoryAPIKey) models.APIKey {
+	key := k.APIKey
+	key.Scopes = append([]string{}, k.Scopes...)
+	key.ExpiresAt, key.LastUsedAt = clone(k.ExpiresAt), clone(k.LastUsedAt)
+	key.Username = r.m.users[k.UserID].Username
+	return key
+}
+
+func (r memoryAPIKeys) Create(ctx context.Context, key *models.APIKey, keyHash string) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	if _, ok := r.m.users[key.UserID]; !ok {
+		return ErrReference
+	}
+	for _, k := range r.m.apiKeys {
+		if k.hash == keyHash {
+			return ErrConflict
+		}
+	}
+
+	r.m.lastID.apiKey++
+	key.ID = r.m.lastID.apiKey
+	key.CreatedAt = time.Now()
+
+	stored := &memoryAPIKey{APIKey: *key, hash: keyHash}
+	stored.Scopes = append([]string{}, key.Scopes...)
+	stored.ExpiresAt = clone(key.ExpiresAt)
+	r.m.apiKeys[key.ID] = stored
+	return nil
+}
+
+func (r memoryAPIKeys) List(ctx context.Context) ([]models.APIKey, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	keys := make([]models.APIKey, 0, len(r.m.apiKeys))
+	for _, k := range r.m.apiKeys {
+		keys = append(keys, r.withUsername(k))
+	}
+	sortByOrder(keys, NewestFirst,
+		func(k models.APIKey) string { return k.Name },
+		func(k models.APIKey) int { return k.ID })
+	return keys, nil
+}
+
+func (r memoryAPIKeys) Delete(ctx context.Context, id int) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	if _, ok := r.m.apiKeys[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.m.apiKeys, id)
+	return nil
+}
+
+func (r memoryAPIKeys) GetByHash(ctx context.Context, keyHash string) (models.APIKey, string, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	for _, k := range r.m.apiKeys {
+		if k.hash == keyHash && !k.Expired() {
+			return r.withUsername(k), r.m.users[k.UserID].Role, nil
+		}
+	}
+	return models.APIKey{}, "", ErrNotFound
+}
+
+func (r memoryAPIKeys) Touch(ctx context.Context, id int, interval time.Duration) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	k, ok := r.m.apiKeys[id]
+	if !ok {
+		return nil
+	}
+	now := time.Now()
+	if k.LastUsedAt == nil || k.LastUsedAt.Before(now.Add(-interval)) {
+		k.LastUsedAt = &now
+	}
+	return nil
+}
+
+type throttleKey struct{ kind, key string }
+
+type memoryLoginThrottle struct{ m *Memory }
+
+func (r memoryLoginThrottle) Locked(ctx context.Context, user, ip string) (bool, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	now := time.Now()
+	for _, k := range []throttleKey{{ThrottleUser, user}, {ThrottleIP, ip}} {
+		if l, ok := r.m.throttle[k]; ok && l.LockedUntil.After(now) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r memoryLoginThrottle) RecordFailure(ctx context.Context, kind, key string, window time.Duration) (int, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	now := time.Now()
+	l, ok := r.m.throttle[throttleKey{kind, key}]
+	switch {
+	case !ok:
+		l = &models.LoginLock{Kind: kind, Key: key, Failures: 1}
+		r.m.throttle[throttleKey{kind, key}] = l
+	case l.LastFailureAt.Before(now.Add(-window)):
+		l.Failures = 1
+	default:
+		l.Failures++
+	}
+	l.LastFailureAt = now
+	return l.Failures, nil
+}
+
+func (r memoryLoginThrottle) Lock(ctx context.Context, kind, key string, d time.Duration) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	if l, ok := r.m.throttle[throttleKey{kind, key}]; ok {
+		l.LockedUntil = time.Now().Add(d)
+	}
+	return nil
+}
+
+func (r memoryLoginThrottle) Reset(ctx context.Context, kind, key string) (bool, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	_, ok := r.m.throttle[throttleKey{kind, key}]
+	delete(r.m.throttle, throttleKey{kind, key})
+	return ok, nil
+}
+
+func (r memoryLoginThrottle) List(ctx context.Context, window time.Duration) ([]models.LoginLock, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	now := time.Now()
+	locks := []models.LoginLock{}
+	for _, l := range r.m.throttle {
+		if l.LockedUntil.After(now) || l.LastFailureAt.After(now.Add(-window)) {
+			lock := *l
+			lock.Locked = l.LockedUntil.After(now)
+			locks = append(locks, lock)
+		}
+	}
+	sort.Slice(locks, func(i, j int) bool { return locks[i].LockedUntil.After(locks[j].LockedUntil) })
+	return locks, nil
+}
+
+func (r memoryLoginThrottle) Purge(ctx context.Context, window time.Duration) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	now := time.Now()
+	for k, l := range r.m.throttle {
+		if l.LockedUntil.Before(now) && l.LastFailureAt.Before(now.Add(-window)) {
+			delete(r.m.throttle, k)
+		}
+	}
+	return nil
+}
+
+type memoryActionToken struct {
+	ActionToken
+	expiresAt time.Time
+	createdAt time.Time
+	used      bool
+}
+
+// usable - токен назначения purpose пользователя userID, не использован и не истек
+func (t *memoryActionToken) usable(userID int, purpose string, now time.Time) bool {
+	return t.UserID == userID && t.Purpose == purpose && !t.used && t.expiresAt.After(now)
+}
+
+type memoryActionTokens struct{ m *Memory }
+
+func (r memoryActionTokens) Issue(ctx context.Context, token ActionToken, expiresAt time.Time) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	if _, ok := r.m.users[token.UserID]; !ok {
+		return ErrReference
+	}
+	if _, ok := r.m.tokens[token.JTI]; ok {
+		return ErrConflict
+	}
+	for _, t := range r.m.tokens {
+		if t.UserID == token.UserID && t.Purpose == token.Purpose {
+			t.used = true
+		}
+	}
+
+	r.m.tokens[token.JTI] = &memoryActionToken{ActionToken: token, expiresAt: expiresAt, createdAt: time.Now()}
+	return nil
+}
+
+func (r memoryActionTokens) Usable(ctx context.Context, token ActionToken) (bool, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	t, ok := r.m.tokens[token.JTI]
+	return ok && t.usable(token.UserID, token.Purpose, time.Now()) &&
+		r.m.users[token.UserID].Email == token.Email, nil
+}
+
+func (r memoryActionTokens) IssuedWithin(ctx context.Context, userID int, purpose string, d time.Duration) (bool, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	since := time.Now().Add(-d)
+	for _, t := range r.m.tokens {
+		if t.UserID == userID && t.Purpose == purpose && t.createdAt.After(since) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r memoryActionTokens) ResetPassword(ctx context.Context, token ActionToken, passwordHash string) error {
+	return r.consume(token, func(user *models.User) {
+		user.PasswordHash = passwordHash
+		user.EmailVerified = true
+	})
+}
+
+func (r memoryActionTokens) VerifyEmail(ctx context.Context, token ActionToken) error {
+	return r.consume(token, func(user *models.User) { user.EmailVerified = true })
+}
+
+// consume - погашение токена и изменение пользователя, если его адрес не менялся
+func (r memoryActionTokens) consume(token ActionToken, update func(*models.User)) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	t, ok := r.m.tokens[token.JTI]
+	if !ok || !t.usable(token.UserID, token.Purpose, time.Now()) {
+		return ErrNotFound
+	}
+	t.used = true
+
+	user, ok := r.m.users[token.UserID]
+	if !ok || user.Email != token.Email {
+		return ErrNotFound
+	}
+	update(&user)
+	r.m.users[user.ID] = user
+	return nil
+}
+
+type identityKey struct{ issuer, subject string }
+
+type memoryIdentity struct {
+	userID      int
+	email       string
+	provisioned bool
+	lastLoginAt time.Time
+}
+
+type memoryIdentities struct{ m *Memory }
+
+func (r memoryIdentities) GetUser(ctx context.Context, issuer, subject string) (models.User, bool, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	i, ok := r.m.identities[identityKey{issuer, subject}]
+	if !ok {
+		return models.User{}, false, ErrNotFound
+	}
+	return r.m.users[i.userID], i.provisioned, nil
+}
+
+func (r memoryIdentities) Link(ctx context.Context, identity Identity, userID int) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	if _, ok := r.m.users[userID]; !ok {
+		return ErrReference
+	}
+	key := identityKey{identity.Issuer, identity.Subject}
+	if _, ok := r.m.identities[key]; !ok {
+		r.m.identities[key] = &memoryIdentity{userID: userID, email: identity.Email, lastLoginAt: time.Now()}
+	}
+	return nil
+}
+
+func (r memoryIdentities) Provision(ctx context.Context, identity Identity, user *models.User) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	key := identityKey{identity.Issuer, identity.Subject}
+	if _, ok := r.m.identities[key]; ok {
+		return ErrConflict
+	}
+
+	user.EmailVerified = identity.EmailVerified
+	if err := r.m.addUser(user); err != nil {
+		return err
+	}
+	r.m.identities[key] = &memoryIdentity{userID: user.ID, email: identity.Email, provisioned: true, lastLoginAt: time.Now()}
+	return nil
+}
+
+func (r memoryIdentities) Sync(ctx context.Context, identity Identity, userID int, role string) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	user, ok := r.m.users[userID]
+	if !ok {
+		return nil
+	}
+	if _, ok := r.m.roles[role]; !ok {
+		return ErrReference
+	}
+	i, linked := r.m.identities[identityKey{identity.Issuer, identity.Subject}]
+	user.Role = role
+	if linked && i.userID == userID && i.provisioned && identity.EmailVerified && user.Email == identity.Email {
+		user.EmailVerified = true
+	}
+	r.m.users[userID] = user
+
+	if linked {
+		i.email, i.lastLoginAt = identity.Email, time.Now()
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"cosmos/internal/logging"
+
+	"github.com/lib/pq"
+)
+
+// taggedDB - *sql.DB, который дописывает к запросам комментарий с ID HTTP запроса
+// (/* request_id='...' */). Комментарий виден в pg_stat_activity и в логе медленных
+// запросов PostgreSQL, поэтому запрос к БД можно сопоставить с записью access log.
+type taggedDB struct {
+	*sql.DB
+}
+
+func (db taggedDB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return db.DB.QueryContext(ctx, tagQuery(ctx, query), args...)
+}
+
+func (db taggedDB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return db.DB.QueryRowContext(ctx, tagQuery(ctx, query), args...)
+}
+
+func (db taggedDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return db.DB.ExecContext(ctx, tagQuery(ctx, query), args...)
+}
+
+// tagQuery - запрос с комментарием request_id. ID проверен logging.ValidRequestID
+// и не содержит кавычек и символов комментария.
+func tagQuery(ctx context.Context, query string) string {
+	if id := logging.RequestID(ctx); id != "" {
+		return "/* request_id='" + id + "' */ " + query
+	}
+	return query
+}
+
+// rowScanner - общий интерфейс для *sql.Row и *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// pgError - перевод ошибок PostgreSQL в ошибки пакета. Исходная ошибка остается
+// в цепочке, поэтому errors.As(err, *pq.Error) продолжает работать.
+func pgError(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code.Name() {
+		case "unique_violation":
+			return fmt.Errorf("%w: %w", ErrConflict, err)
+		case "foreign_key_violation":
+			return fmt.Errorf("%w: %w", ErrReference, err)
+		}
+	}
+	return err
+}
+
+// expiresIn - секунды до t для "NOW() + make_interval(secs => $N)". Срок считается
+// в SQL от NOW(): time.Time в колонке TIMESTAMP без часового пояса теряет смещение и
+// сравнивается с NOW() в поясе сессии БД, поэтому при разных часовых поясах
+// приложения и БД срок сдвигался бы на их разницу.
+func expiresIn(t time.Time) float64 {
+	return time.Until(t).Seconds()
+}
+
+// nullExpiresIn - expiresIn для необязательного срока: NULL (бессрочно) для nil
+func nullExpiresIn(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return expiresIn(*t)
+}
+
+// execAffected - Exec, который возвращает ErrNotFound, если не затронута ни одна строка
+func execAffected(result sql.Result, err error) error {
+	if err != nil {
+		return pgError(err)
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func nullInt(v *int) any {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
+
+// nullID - NULL вместо отсутствующей или непроставленной (0) ссылки
+func nullID(v *int) any {
+	if v == nil || *v <= 0 {
+		return nil
+	}
+	return *v
+}
+
+// nullNonZero - NULL для nil и нуля (год открытия планеты 0 означает "неизвестен")
+func nullNonZero(v *int) any {
+	if v == nil || *v == 0 {
+		return nil
+	}
+	return *v
+}
+
+func nullFloat(v *float64) any {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
+
+func intPtr(v sql.NullInt64) *int {
+	if !v.Valid {
+		return nil
+	}
+	i := int(v.Int64)
+	return &i
+}
+
+func floatPtr(v sql.NullFloat64) *float64 {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Float64
+}
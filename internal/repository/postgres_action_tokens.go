@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// PostgresActionTokens - токены из писем в PostgreSQL
+type PostgresActionTokens struct {
+	db taggedDB
+}
+
+func NewPostgresActionTokens(db *sql.DB) *PostgresActionTokens {
+	return &PostgresActionTokens{db: taggedDB{db}}
+}
+
+func (r *PostgresActionTokens) Issue(ctx context.Context, token ActionToken, expiresAt time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, tagQuery(ctx,
+		"UPDATE user_tokens SET used_at = NOW() WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL"),
+		token.UserID, token.Purpose,
+	); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, tagQuery(ctx,
+		"INSERT INTO user_tokens (jti, user_id, purpose, expires_at) VALUES ($1, $2, $3, NOW() + make_interval(secs => $4))"),
+		token.JTI, token.UserID, token.Purpose, expiresIn(expiresAt),
+	); err != nil {
+		return pgError(err)
+	}
+
+	return tx.Commit()
+}
+
+func (r *PostgresActionTokens) Usable(ctx context.Context, token ActionToken) (bool, error) {
+	var usable bool
+	err := r.db.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM user_tokens t JOIN users u ON u.id = t.user_id
+			WHERE t.jti = $1 AND t.user_id = $2 AND t.purpose = $3 AND t.used_at IS NULL AND t.expires_at > NOW()
+			  AND u.email = $4
+		)`, token.JTI, token.UserID, token.Purpose, token.Email,
+	).Scan(&usable)
+	return usable, err
+}
+
+func (r *PostgresActionTokens) IssuedWithin(ctx context.Context, userID int, purpose string, d time.Duration) (bool, error) {
+	var recent bool
+	err := r.db.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM user_tokens
+			WHERE user_id = $1 AND purpose = $2 AND created_at > NOW() - make_interval(secs => $3)
+		)`, userID, purpose, d.Seconds(),
+	).Scan(&recent)
+	return recent, err
+}
+
+func (r *PostgresActionTokens) ResetPassword(ctx context.Context, token ActionToken, passwordHash string) error {
+	return r.consume(ctx, token, `
+		UPDATE users SET password_hash = $3, email_verified_at = COALESCE(email_verified_at, NOW())
+		WHERE id = $1 AND email = $2`, passwordHash)
+}
+
+func (r *PostgresActionTokens) VerifyEmail(ctx context.Context, token ActionToken) error {
+	return r.consume(ctx, token,
+		"UPDATE users SET email_verified_at = COALESCE(email_verified_at, NOW()) WHERE id = $1 AND email = $2")
+}
+
+// consume - погашение токена и изменение пользователя (update с параметрами $1 - ID,
+// $2 - адрес из токена, дальше args) в одной транзакции, поэтому по одной ссылке
+// изменение проходит один раз. Если адрес сменили, токен гасится без изменения.
+func (r *PostgresActionTokens) consume(ctx context.Context, token ActionToken, update string, args ...any) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = execAffected(tx.ExecContext(ctx, tagQuery(ctx, `
+		UPDATE user_tokens SET used_at = NOW()
+		WHERE jti = $1 AND user_id = $2 AND purpose = $3 AND used_at IS NULL AND expires_at > NOW()`),
+		token.JTI, token.UserID, token.Purpose))
+	if err != nil {
+		return err
+	}
+
+	err = execAffected(tx.ExecContext(ctx, tagQuery(ctx, update), append([]any{token.UserID, token.Email}, args...)...))
+	if errors.Is(err, ErrNotFound) {
+		// Токен со старого адреса гасится, пользователь не меняется
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"cosmos/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// PostgresAPIKeys - API ключи в PostgreSQL
+type PostgresAPIKeys struct {
+	db taggedDB
+}
+
+func NewPostgresAPIKeys(db *sql.DB) *PostgresAPIKeys {
+	return &PostgresAPIKeys{db: taggedDB{db}}
+}
+
+func (r *PostgresAPIKeys) Create(ctx context.Context, key *models.APIKey, keyHash string) error {
+	err := r.db.QueryRowContext(ctx,
+		`INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, expires_at)
+		 VALUES ($1, $2, $3, $4, $5, NOW() + make_interval(secs => $6)) RETURNING id, created_at`,
+		key.UserID, key.Name, key.Prefix, keyHash, pq.Array(key.Scopes), nullExpiresIn(key.ExpiresAt),
+	).Scan(&key.ID, &key.CreatedAt)
+	return pgError(err)
+}
+
+func (r *PostgresAPIKeys) List(ctx context.Context) ([]models.APIKey, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT k.id, k.user_id, u.username, k.name, k.prefix, k.scopes, k.expires_at, k.last_used_at, k.created_at
+		FROM api_keys k
+		JOIN users u ON u.id = k.user_id
+		ORDER BY k.id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		var k models.APIKey
+		if err := rows.Scan(&k.ID, &k.UserID, &k.Username, &k.Name, &k.Prefix, pq.Array(&k.Scopes),
+			&k.ExpiresAt, &k.LastUsedAt, &k.CreatedAt); err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+func (r *PostgresAPIKeys) Delete(ctx context.Context, id int) error {
+	return execAffected(r.db.ExecContext(ctx, "DELETE FROM api_keys WHERE id = $1", id))
+}
+
+func (r *PostgresAPIKeys) GetByHash(ctx context.Context, keyHash string) (models.APIKey, string, error) {
+	var k models.APIKey
+	var role string
+	err := r.db.QueryRowContext(ctx, `
+		SELECT k.id, k.user_id, u.username, k.name, k.prefix, k.scopes, k.expires_at, k.last_used_at, k.created_at, u.role
+		FROM api_keys k
+		JOIN users u ON u.id = k.user_id
+		WHERE k.key_hash = $1 AND (k.expires_at IS NULL OR k.expires_at > NOW())`, keyHash,
+	).Scan(&k.ID, &k.UserID, &k.Username, &k.Name, &k.Prefix, pq.Array(&k.Scopes),
+		&k.ExpiresAt, &k.LastUsedAt, &k.CreatedAt, &role)
+	return k, role, pgError(err)
+}
+
+func (r *PostgresAPIKeys) Touch(ctx context.Context, id int, interval time.Duration) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE api_keys SET last_used_at = NOW()
+		 WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - make_interval(secs => $2))`,
+		id, interval.Seconds(),
+	)
+	return err
+}
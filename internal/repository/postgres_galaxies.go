@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"cosmos/internal/models"
+)
+
+// galaxyColumns - полный набор колонок галактики для scanGalaxy
+const galaxyColumns = `
+	id, name, COALESCE(type, ''), diameter_ly, mass_suns, distance_from_earth_ly,
+	discovered_year, COALESCE(description, ''), created_at, COALESCE(updated_at, created_at)
+`
+
+// PostgresGalaxies - галактики в PostgreSQL
+type PostgresGalaxies struct {
+	db taggedDB
+}
+
+func NewPostgresGalaxies(db *sql.DB) *PostgresGalaxies {
+	return &PostgresGalaxies{db: taggedDB{db}}
+}
+
+// scanGalaxy - чтение галактики из строки, выбранной с galaxyColumns
+func scanGalaxy(row rowScanner) (models.Galaxy, error) {
+	var galaxy models.Galaxy
+	var diameterLy, massSuns, distanceFromEarthLy sql.NullFloat64
+	var discoveredYear sql.NullInt64
+
+	err := row.Scan(
+		&galaxy.ID, &galaxy.Name, &galaxy.Type, &diameterLy, &massSuns,
+		&distanceFromEarthLy, &discoveredYear, &galaxy.Description,
+		&galaxy.CreatedAt, &galaxy.UpdatedAt,
+	)
+	if err != nil {
+		return galaxy, pgError(err)
+	}
+
+	galaxy.DiameterLy = floatPtr(diameterLy)
+	galaxy.MassSuns = floatPtr(massSuns)
+	galaxy.DistanceFromEarthLy = floatPtr(distanceFromEarthLy)
+	galaxy.DiscoveredYear = intPtr(discoveredYear)
+	return galaxy, nil
+}
+
+func (r *PostgresGalaxies) List(ctx context.Context, order Order) ([]models.Galaxy, error) {
+	orderBy := "name"
+	if order == NewestFirst {
+		orderBy = "id DESC"
+	}
+
+	rows, err := r.db.QueryContext(ctx, "SELECT "+galaxyColumns+" FROM galaxies ORDER BY "+orderBy)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	galaxies := []models.Galaxy{}
+	for rows.Next() {
+		galaxy, err := scanGalaxy(rows)
+		if err != nil {
+			return nil, err
+		}
+		galaxies = append(galaxies, galaxy)
+	}
+
+	return galaxies, rows.Err()
+}
+
+func (r *PostgresGalaxies) Get(ctx context.Context, id int) (models.Galaxy, error) {
+	row := r.db.QueryRowContext(ctx, "SELECT "+galaxyColumns+" FROM galaxies WHERE id = $1", id)
+	return scanGalaxy(row)
+}
+
+func (r *PostgresGalaxies) Count(ctx context.Context) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM galaxies").Scan(&count)
+	return count, err
+}
+
+func (r *PostgresGalaxies) Create(ctx context.Context, galaxy *models.Galaxy) error {
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO galaxies (name, type, description, diameter_ly, mass_suns,
+		                     distance_from_earth_ly, discovered_year)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at`,
+		galaxy.Name, galaxy.Type, galaxy.Description,
+		nullFloat(galaxy.DiameterLy), nullFloat(galaxy.MassSuns),
+		nullFloat(galaxy.DistanceFromEarthLy), nullInt(galaxy.DiscoveredYear),
+	).Scan(&galaxy.ID, &galaxy.CreatedAt)
+	return pgError(err)
+}
+
+func (r *PostgresGalaxies) Update(ctx context.Context, id int, galaxy *models.Galaxy) error {
+	err := r.db.QueryRowContext(ctx, `
+		UPDATE galaxies
+		SET name = $1, type = $2, description = $3, diameter_ly = $4,
+		    mass_suns = $5, distance_from_earth_ly = $6, discovered_year = $7,
+		    updated_at = CURRENT_TIMESTAMP
+		WHERE id = $8
+		RETURNING updated_at`,
+		galaxy.Name, galaxy.Type, galaxy.Description,
+		nullFloat(galaxy.DiameterLy), nullFloat(galaxy.MassSuns),
+		nullFloat(galaxy.DistanceFromEarthLy), nullInt(galaxy.DiscoveredYear),
+		id,
+	).Scan(&galaxy.UpdatedAt)
+	return pgError(err)
+}
+
+// DeleteDetachingPlanets повторяет ON DELETE SET NULL из схемы, но в одной
+// транзакции считает отвязанные планеты
+func (r *PostgresGalaxies) DeleteDetachingPlanets(ctx context.Context, id int) (int64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, tagQuery(ctx, "UPDATE planets SET galaxy_id = NULL, updated_at = CURRENT_TIMESTAMP WHERE galaxy_id = $1"), id)
+	if err != nil {
+		return 0, err
+	}
+	detached, _ := result.RowsAffected()
+
+	if err := execAffected(tx.ExecContext(ctx, tagQuery(ctx, "DELETE FROM galaxies WHERE id = $1"), id)); err != nil {
+		return 0, err
+	}
+
+	return detached, tx.Commit()
+}
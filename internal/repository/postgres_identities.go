@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"cosmos/internal/models"
+)
+
+// PostgresIdentities - привязки учетных записей SSO в PostgreSQL
+type PostgresIdentities struct {
+	db taggedDB
+}
+
+func NewPostgresIdentities(db *sql.DB) *PostgresIdentities {
+	return &PostgresIdentities{db: taggedDB{db}}
+}
+
+func (r *PostgresIdentities) GetUser(ctx context.Context, issuer, subject string) (models.User, bool, error) {
+	var user models.User
+	var provisioned bool
+	err := r.db.QueryRowContext(ctx, `
+		SELECT u.id, u.username, u.email, u.password_hash, u.role, u.created_at,
+		       u.totp_enabled_at IS NOT NULL, u.email_verified_at IS NOT NULL, i.provisioned
+		FROM user_identities i
+		JOIN users u ON u.id = i.user_id
+		WHERE i.issuer = $1 AND i.subject = $2`, issuer, subject,
+	).Scan(&user.ID, &user.Username, &user.Email, &user.PasswordHash, &user.Role, &user.CreatedAt,
+		&user.TOTPEnabled, &user.EmailVerified, &provisioned)
+	return user, provisioned, pgError(err)
+}
+
+func (r *PostgresIdentities) Link(ctx context.Context, identity Identity, userID int) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO user_identities (issuer, subject, user_id, email) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (issuer, subject) DO NOTHING`,
+		identity.Issuer, identity.Subject, userID, identity.Email)
+	return pgError(err)
+}
+
+func (r *PostgresIdentities) Provision(ctx context.Context, identity Identity, user *models.User) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, tagQuery(ctx, `
+		INSERT INTO users (username, email, password_hash, role, email_verified_at)
+		VALUES ($1, $2, $3, $4, CASE WHEN $5 THEN NOW() END)
+		RETURNING id, created_at`),
+		user.Username, user.Email, user.PasswordHash, user.Role, identity.EmailVerified,
+	).Scan(&user.ID, &user.CreatedAt)
+	if err != nil {
+		return pgError(err)
+	}
+	user.EmailVerified = identity.EmailVerified
+
+	if _, err := tx.ExecContext(ctx, tagQuery(ctx,
+		"INSERT INTO user_identities (issuer, subject, user_id, email, provisioned) VALUES ($1, $2, $3, $4, TRUE)"),
+		identity.Issuer, identity.Subject, user.ID, identity.Email,
+	); err != nil {
+		return pgError(err)
+	}
+
+	return tx.Commit()
+}
+
+func (r *PostgresIdentities) Sync(ctx context.Context, identity Identity, userID int, role string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, tagQuery(ctx, `
+		UPDATE users SET role = $2,
+		       email_verified_at = CASE
+		           WHEN $3 AND email = $4 AND EXISTS (
+		               SELECT 1 FROM user_identities
+		               WHERE issuer = $5 AND subject = $6 AND user_id = $1 AND provisioned
+		           ) THEN COALESCE(email_verified_at, NOW())
+		           ELSE email_verified_at
+		       END
+		WHERE id = $1`),
+		userID, role, identity.EmailVerified, identity.Email, identity.Issuer, identity.Subject,
+	); err != nil {
+		return pgError(err)
+	}
+
+	if _, err := tx.ExecContext(ctx, tagQuery(ctx,
+		"UPDATE user_identities SET email = $3, last_login_at = NOW() WHERE issuer = $1 AND subject = $2"),
+		identity.Issuer, identity.Subject, identity.Email,
+	); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
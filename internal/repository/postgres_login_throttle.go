@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"cosmos/internal/models"
+)
+
+// PostgresLoginThrottle - счетчики неудачных входов в PostgreSQL
+type PostgresLoginThrottle struct {
+	db taggedDB
+}
+
+func NewPostgresLoginThrottle(db *sql.DB) *PostgresLoginThrottle {
+	return &PostgresLoginThrottle{db: taggedDB{db}}
+}
+
+func (r *PostgresLoginThrottle) Locked(ctx context.Context, user, ip string) (bool, error) {
+	var locked bool
+	err := r.db.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM login_throttle
+			WHERE ((kind = $1 AND key = $2) OR (kind = $3 AND key = $4)) AND locked_until > NOW()
+		)`, ThrottleUser, user, ThrottleIP, ip,
+	).Scan(&locked)
+	return locked, err
+}
+
+func (r *PostgresLoginThrottle) RecordFailure(ctx context.Context, kind, key string, window time.Duration) (int, error) {
+	var failures int
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO login_throttle (kind, key, failures, last_failure_at)
+		VALUES ($1, $2, 1, NOW())
+		ON CONFLICT (kind, key) DO UPDATE SET
+			failures = CASE WHEN login_throttle.last_failure_at < NOW() - make_interval(secs => $3)
+			                THEN 1 ELSE login_throttle.failures + 1 END,
+			last_failure_at = NOW()
+		RETURNING failures`, kind, key, window.Seconds(),
+	).Scan(&failures)
+	return failures, err
+}
+
+func (r *PostgresLoginThrottle) Lock(ctx context.Context, kind, key string, d time.Duration) error {
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE login_throttle SET locked_until = NOW() + make_interval(secs => $3) WHERE kind = $1 AND key = $2",
+		kind, key, d.Seconds(),
+	)
+	return err
+}
+
+func (r *PostgresLoginThrottle) Reset(ctx context.Context, kind, key string) (bool, error) {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM login_throttle WHERE kind = $1 AND key = $2", kind, key)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+func (r *PostgresLoginThrottle) List(ctx context.Context, window time.Duration) ([]models.LoginLock, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT kind, key, failures, last_failure_at, locked_until, locked_until > NOW()
+		FROM login_throttle
+		WHERE locked_until > NOW() OR last_failure_at > NOW() - make_interval(secs => $1)
+		ORDER BY locked_until DESC`, window.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	locks := []models.LoginLock{}
+	for rows.Next() {
+		var l models.LoginLock
+		if err := rows.Scan(&l.Kind, &l.Key, &l.Failures, &l.LastFailureAt, &l.LockedUntil, &l.Locked); err != nil {
+			return nil, err
+		}
+		locks = append(locks, l)
+	}
+	return locks, rows.Err()
+}
+
+func (r *PostgresLoginThrottle) Purge(ctx context.Context, window time.Duration) error {
+	_, err := r.db.ExecContext(ctx, `
+		DELETE FROM login_throttle
+		WHERE locked_until < NOW() AND last_failure_at < NOW() - make_interval(secs => $1)`,
+		window.Seconds())
+	return err
+}
@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"cosmos/internal/models"
+)
+
+// planetColumns - полный набор колонок планеты для scanPlanet
+const planetColumns = `
+	p.id, p.name, p.galaxy_id, COALESCE(g.name, ''), COALESCE(p.type, ''),
+	COALESCE(p.diameter_km, 0), COALESCE(p.mass_kg, 0), COALESCE(p.orbital_period_days, 0),
+	COALESCE(p.has_life, false), COALESCE(p.is_habitable, false), p.discovered_year,
+	COALESCE(p.description, ''), p.created_at, COALESCE(p.updated_at, p.created_at)
+`
+
+// PostgresPlanets - планеты в PostgreSQL
+type PostgresPlanets struct {
+	db taggedDB
+}
+
+func NewPostgresPlanets(db *sql.DB) *PostgresPlanets {
+	return &PostgresPlanets{db: taggedDB{db}}
+}
+
+// scanPlanet - чтение планеты из строки, выбранной с planetColumns
+func scanPlanet(row rowScanner) (models.Planet, error) {
+	var planet models.Planet
+	var galaxyID, discoveredYear sql.NullInt64
+
+	err := row.Scan(
+		&planet.ID, &planet.Name, &galaxyID, &planet.GalaxyName, &planet.Type,
+		&planet.DiameterKm, &planet.MassKg, &planet.OrbitalPeriodDays,
+		&planet.HasLife, &planet.IsHabitable, &discoveredYear,
+		&planet.Description, &planet.CreatedAt, &planet.UpdatedAt,
+	)
+	if err != nil {
+		return planet, pgError(err)
+	}
+
+	planet.GalaxyID = intPtr(galaxyID)
+	planet.DiscoveredYear = intPtr(discoveredYear)
+	return planet, nil
+}
+
+func (r *PostgresPlanets) List(ctx context.Context, order Order) ([]models.Planet, error) {
+	orderBy := "p.name"
+	if order == NewestFirst {
+		orderBy = "p.id DESC"
+	}
+	return r.query(ctx, "", orderBy)
+}
+
+func (r *PostgresPlanets) ListByGalaxy(ctx context.Context, galaxyID int) ([]models.Planet, error) {
+	return r.query(ctx, "WHERE p.galaxy_id = $1", "p.name", galaxyID)
+}
+
+// query - выборка планет с произвольным условием WHERE
+func (r *PostgresPlanets) query(ctx context.Context, where, orderBy string, args ...any) ([]models.Planet, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+planetColumns+`
+		FROM planets p
+		LEFT JOIN galaxies g ON p.galaxy_id = g.id
+		`+where+`
+		ORDER BY `+orderBy, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	planets := []models.Planet{}
+	for rows.Next() {
+		planet, err := scanPlanet(rows)
+		if err != nil {
+			return nil, err
+		}
+		planets = append(planets, planet)
+	}
+
+	return planets, rows.Err()
+}
+
+func (r *PostgresPlanets) Get(ctx context.Context, id int) (models.Planet, error) {
+	row := r.db.QueryRowContext(ctx, `
+		SELECT `+planetColumns+`
+		FROM planets p
+		LEFT JOIN galaxies g ON p.galaxy_id = g.id
+		WHERE p.id = $1
+	`, id)
+	return scanPlanet(row)
+}
+
+func (r *PostgresPlanets) Count(ctx context.Context) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM planets").Scan(&count)
+	return count, err
+}
+
+func (r *PostgresPlanets) CountByGalaxy(ctx context.Context, galaxyID int) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM planets WHERE galaxy_id = $1", galaxyID).Scan(&count)
+	return count, err
+}
+
+func (r *PostgresPlanets) Create(ctx context.Context, planet *models.Planet) error {
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO planets (name, type, description, diameter_km, mass_kg,
+		                    orbital_period_days, discovered_year, galaxy_id,
+		                    has_life, is_habitable)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id, created_at`,
+		planet.Name, planet.Type, planet.Description,
+		planet.DiameterKm, planet.MassKg, planet.OrbitalPeriodDays,
+		nullNonZero(planet.DiscoveredYear), nullID(planet.GalaxyID),
+		planet.HasLife, planet.IsHabitable,
+	).Scan(&planet.ID, &planet.CreatedAt)
+	return pgError(err)
+}
+
+func (r *PostgresPlanets) Update(ctx context.Context, id int, planet *models.Planet) error {
+	err := r.db.QueryRowContext(ctx, `
+		UPDATE planets
+		SET name = $1, type = $2, description = $3, diameter_km = $4,
+		    mass_kg = $5, orbital_period_days = $6, discovered_year = $7,
+		    galaxy_id = $8, has_life = $9, is_habitable = $10,
+		    updated_at = CURRENT_TIMESTAMP
+		WHERE id = $11
+		RETURNING updated_at`,
+		planet.Name, planet.Type, planet.Description,
+		planet.DiameterKm, planet.MassKg, planet.OrbitalPeriodDays,
+		nullNonZero(planet.DiscoveredYear), nullID(planet.GalaxyID),
+		planet.HasLife, planet.IsHabitable,
+		id,
+	).Scan(&planet.UpdatedAt)
+	return pgError(err)
+}
+
+func (r *PostgresPlanets) Delete(ctx context.Context, id int) error {
+	return execAffected(r.db.ExecContext(ctx, "DELETE FROM planets WHERE id = $1", id))
+}
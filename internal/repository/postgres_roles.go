@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"cosmos/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// PostgresRoles - роли и права в PostgreSQL
+type PostgresRoles struct {
+	db taggedDB
+}
+
+func NewPostgresRoles(db *sql.DB) *PostgresRoles {
+	return &PostgresRoles{db: taggedDB{db}}
+}
+
+func (r *PostgresRoles) List(ctx context.Context) ([]models.Role, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT r.name, r.description, COALESCE(array_agg(rp.permission ORDER BY rp.permission)
+		       FILTER (WHERE rp.permission IS NOT NULL), '{}')
+		FROM roles r
+		LEFT JOIN role_permissions rp ON rp.role = r.name
+		GROUP BY r.name, r.description
+		ORDER BY r.name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []models.Role{}
+	for rows.Next() {
+		var role models.Role
+		if err := rows.Scan(&role.Name, &role.Description, pq.Array(&role.Permissions)); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
+
+func (r *PostgresRoles) Exists(ctx context.Context, role string) (bool, error) {
+	var ok bool
+	err := r.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM roles WHERE name = $1)", role).Scan(&ok)
+	return ok, err
+}
+
+func (r *PostgresRoles) Permissions(ctx context.Context, role string) (map[string]bool, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT permission FROM role_permissions WHERE role = $1", role)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	permissions := map[string]bool{}
+	for rows.Next() {
+		var permission string
+		if err := rows.Scan(&permission); err != nil {
+			return nil, err
+		}
+		permissions[permission] = true
+	}
+	return permissions, rows.Err()
+}
+
+func (r *PostgresRoles) HasPermission(ctx context.Context, role, permission string) (bool, error) {
+	var ok bool
+	err := r.db.QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM role_permissions WHERE role = $1 AND permission = $2)",
+		role, permission,
+	).Scan(&ok)
+	return ok, err
+}
@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"cosmos/internal/models"
+)
+
+// PostgresSessions - сессии в PostgreSQL
+type PostgresSessions struct {
+	db taggedDB
+}
+
+func NewPostgresSessions(db *sql.DB) *PostgresSessions {
+	return &PostgresSessions{db: taggedDB{db}}
+}
+
+func (r *PostgresSessions) Create(ctx context.Context, userID int, tokenHash, userAgent, ip string, expiresAt time.Time) (int64, error) {
+	var id int64
+	err := r.db.QueryRowContext(ctx,
+		`INSERT INTO sessions (user_id, refresh_token_hash, user_agent, ip_address, expires_at)
+		 VALUES ($1, $2, $3, $4, NOW() + make_interval(secs => $5)) RETURNING id`,
+		userID, tokenHash, userAgent, ip, expiresIn(expiresAt),
+	).Scan(&id)
+	return id, pgError(err)
+}
+
+func (r *PostgresSessions) Rotate(ctx context.Context, tokenHash, newHash, userAgent, ip string, expiresAt time.Time) (SessionOwner, error) {
+	var owner SessionOwner
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return owner, err
+	}
+	defer tx.Rollback()
+
+	// Строка сессии блокируется: два запроса с одним токеном не обменяют его оба
+	err = tx.QueryRowContext(ctx, tagQuery(ctx, `
+		SELECT s.id, u.id, u.username, u.role
+		FROM sessions s
+		JOIN users u ON u.id = s.user_id
+		WHERE s.refresh_token_hash = $1 AND s.revoked_at IS NULL AND s.expires_at > NOW()
+		FOR UPDATE OF s`), tokenHash,
+	).Scan(&owner.SessionID, &owner.UserID, &owner.Username, &owner.Role)
+	if err != nil {
+		return owner, pgError(err)
+	}
+
+	_, err = tx.ExecContext(ctx, tagQuery(ctx, `
+		UPDATE sessions
+		SET previous_token_hash = refresh_token_hash, refresh_token_hash = $1,
+		    last_used_at = NOW(), expires_at = NOW() + make_interval(secs => $2), user_agent = $3, ip_address = $4
+		WHERE id = $5`),
+		newHash, expiresIn(expiresAt), userAgent, ip, owner.SessionID,
+	)
+	if err != nil {
+		return owner, err
+	}
+
+	return owner, tx.Commit()
+}
+
+func (r *PostgresSessions) ByPreviousToken(ctx context.Context, tokenHash string, within time.Duration) (id int64, userID int, recent bool, err error) {
+	err = r.db.QueryRowContext(ctx, `
+		SELECT id, user_id, last_used_at > NOW() - make_interval(secs => $2)
+		FROM sessions
+		WHERE previous_token_hash = $1 AND revoked_at IS NULL`, tokenHash, within.Seconds(),
+	).Scan(&id, &userID, &recent)
+	return id, userID, recent, pgError(err)
+}
+
+func (r *PostgresSessions) Valid(ctx context.Context, id int64, userID int, role string) (bool, error) {
+	var valid bool
+	err := r.db.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM sessions s
+			JOIN users u ON u.id = s.user_id
+			WHERE s.id = $1 AND s.user_id = $2 AND u.role = $3
+			  AND s.revoked_at IS NULL AND s.expires_at > NOW()
+		)`, id, userID, role,
+	).Scan(&valid)
+	return valid, err
+}
+
+func (r *PostgresSessions) Revoke(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE sessions SET revoked_at = NOW() WHERE id = $1 AND revoked_at IS NULL", id)
+	return err
+}
+
+func (r *PostgresSessions) RevokeByToken(ctx context.Context, tokenHash string) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE sessions SET revoked_at = NOW() WHERE refresh_token_hash = $1 AND revoked_at IS NULL", tokenHash)
+	return err
+}
+
+func (r *PostgresSessions) RevokeUser(ctx context.Context, userID int) (int64, error) {
+	result, err := r.db.ExecContext(ctx, "UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL", userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (r *PostgresSessions) ListActive(ctx context.Context) ([]models.Session, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT s.id, s.user_id, u.username, COALESCE(s.user_agent, ''), COALESCE(s.ip_address, ''),
+		       s.created_at, s.last_used_at, s.expires_at
+		FROM sessions s
+		JOIN users u ON u.id = s.user_id
+		WHERE s.revoked_at IS NULL AND s.expires_at > NOW()
+		ORDER BY s.last_used_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []models.Session{}
+	for rows.Next() {
+		var s models.Session
+		if err := rows.Scan(&s.ID, &s.UserID, &s.Username, &s.UserAgent, &s.IPAddress,
+			&s.CreatedAt, &s.LastUsedAt, &s.ExpiresAt); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
@@ -0,0 +1,199 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+)
+
+// PostgresTOTP - TOTP и коды восстановления в PostgreSQL. Секрет хранится
+// зашифрованным secrets: по копии БД коды не сгенерировать.
+type PostgresTOTP struct {
+	db      taggedDB
+	secrets SecretCipher
+}
+
+func NewPostgresTOTP(db *sql.DB, secrets SecretCipher) *PostgresTOTP {
+	return &PostgresTOTP{db: taggedDB{db}, secrets: secrets}
+}
+
+// secretAD - associated data шифротекста: секрет расшифровывается только в записи своего пользователя
+func secretAD(userID int) string {
+	return "users.totp_secret:" + strconv.Itoa(userID)
+}
+
+func (r *PostgresTOTP) State(ctx context.Context, userID int) (TOTPState, error) {
+	var state TOTPState
+	var secret sql.NullString
+	err := r.db.QueryRowContext(ctx, `
+		SELECT u.totp_secret, u.totp_enabled_at IS NOT NULL, u.totp_last_step, COALESCE(r.require_totp, FALSE)
+		FROM users u
+		LEFT JOIN roles r ON r.name = u.role
+		WHERE u.id = $1`, userID,
+	).Scan(&secret, &state.Enabled, &state.LastStep, &state.Required)
+	if err != nil {
+		return state, pgError(err)
+	}
+
+	// Открытый текст остается только у секретов, сохраненных до шифрования,
+	// пока их не зашифрует EncryptPlaintextSecrets
+	state.Secret = secret.String
+	if state.Secret != "" && r.secrets.Sealed(state.Secret) {
+		if state.Secret, err = r.secrets.Open(state.Secret, secretAD(userID)); err != nil {
+			return TOTPState{}, fmt.Errorf("секрет TOTP пользователя %d: %w", userID, err)
+		}
+	}
+	return state, nil
+}
+
+func (r *PostgresTOTP) SetPendingSecret(ctx context.Context, userID int, secret string) error {
+	sealed, err := r.secrets.Seal(secret, secretAD(userID))
+	if err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(ctx,
+		"UPDATE users SET totp_secret = $1 WHERE id = $2 AND totp_enabled_at IS NULL",
+		sealed, userID,
+	)
+	return err
+}
+
+// EncryptPlaintextSecrets - шифрование секретов, сохраненных открытым текстом до
+// миграции 010; возвращает число зашифрованных. Запись обновляется, только если
+// секрет за это время не изменился.
+func (r *PostgresTOTP) EncryptPlaintextSecrets(ctx context.Context) (int, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT id, totp_secret FROM users WHERE totp_secret IS NOT NULL AND totp_secret NOT LIKE 'enc:%'")
+	if err != nil {
+		return 0, err
+	}
+	plain := map[int]string{}
+	for rows.Next() {
+		var id int
+		var secret string
+		if err := rows.Scan(&id, &secret); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		plain[id] = secret
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	encrypted := 0
+	for id, secret := range plain {
+		if r.secrets.Sealed(secret) {
+			continue
+		}
+		sealed, err := r.secrets.Seal(secret, secretAD(id))
+		if err != nil {
+			return encrypted, err
+		}
+		result, err := r.db.ExecContext(ctx,
+			"UPDATE users SET totp_secret = $1 WHERE id = $2 AND totp_secret = $3", sealed, id, secret)
+		if err != nil {
+			return encrypted, err
+		}
+		if n, _ := result.RowsAffected(); n == 1 {
+			encrypted++
+		}
+	}
+	return encrypted, nil
+}
+
+func (r *PostgresTOTP) Enable(ctx context.Context, userID int, step int64, codeHashes []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = execAffected(tx.ExecContext(ctx, tagQuery(ctx, `
+		UPDATE users SET totp_enabled_at = NOW(), totp_last_step = $1
+		WHERE id = $2 AND totp_secret IS NOT NULL AND totp_enabled_at IS NULL`), step, userID))
+	if err != nil {
+		return err
+	}
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, codeHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *PostgresTOTP) Disable(ctx context.Context, userID int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, tagQuery(ctx, `
+		UPDATE users SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = 0
+		WHERE id = $1`), userID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, tagQuery(ctx, "DELETE FROM recovery_codes WHERE user_id = $1"), userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *PostgresTOTP) ReplaceRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, codeHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userID int, codeHashes []string) error {
+	if _, err := tx.ExecContext(ctx, tagQuery(ctx, "DELETE FROM recovery_codes WHERE user_id = $1"), userID); err != nil {
+		return err
+	}
+	for _, hash := range codeHashes {
+		if _, err := tx.ExecContext(ctx, tagQuery(ctx, "INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2)"),
+			userID, hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *PostgresTOTP) CountRecoveryCodes(ctx context.Context, userID int) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM recovery_codes WHERE user_id = $1 AND used_at IS NULL",
+		userID).Scan(&count)
+	return count, err
+}
+
+func (r *PostgresTOTP) AcceptStep(ctx context.Context, userID int, step int64) (bool, error) {
+	// Условие на шаг защищает от одновременного предъявления одного кода
+	result, err := r.db.ExecContext(ctx,
+		"UPDATE users SET totp_last_step = $1 WHERE id = $2 AND totp_last_step < $1", step, userID)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n == 1, nil
+}
+
+func (r *PostgresTOTP) UseRecoveryCode(ctx context.Context, userID int, codeHash string) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE recovery_codes SET used_at = NOW()
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`,
+		userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n == 1, nil
+}
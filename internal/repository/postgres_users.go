@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"cosmos/internal/models"
+)
+
+// userColumns - колонки пользователя для scanUser
+const userColumns = `
+	id, username, email, password_hash, role, created_at,
+	totp_enabled_at IS NOT NULL, email_verified_at IS NOT NULL
+`
+
+// PostgresUsers - пользователи в PostgreSQL
+type PostgresUsers struct {
+	db taggedDB
+}
+
+func NewPostgresUsers(db *sql.DB) *PostgresUsers {
+	return &PostgresUsers{db: taggedDB{db}}
+}
+
+func scanUser(row rowScanner) (models.User, error) {
+	var user models.User
+	err := row.Scan(&user.ID, &user.Username, &user.Email, &user.PasswordHash, &user.Role,
+		&user.CreatedAt, &user.TOTPEnabled, &user.EmailVerified)
+	return user, pgError(err)
+}
+
+func (r *PostgresUsers) List(ctx context.Context) ([]models.User, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT "+userColumns+" FROM users ORDER BY id DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
+func (r *PostgresUsers) Get(ctx context.Context, id int) (models.User, error) {
+	return scanUser(r.db.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE id = $1", id))
+}
+
+func (r *PostgresUsers) GetByUsername(ctx context.Context, username string) (models.User, error) {
+	return scanUser(r.db.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE username = $1", username))
+}
+
+func (r *PostgresUsers) GetByEmail(ctx context.Context, email string) (models.User, error) {
+	return scanUser(r.db.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE email = $1", email))
+}
+
+func (r *PostgresUsers) Exists(ctx context.Context, username, email string, excludeID int) (bool, error) {
+	var exists bool
+	err := r.db.QueryRowContext(ctx,
+		`SELECT EXISTS(SELECT 1 FROM users WHERE (username = $1 OR email = $2) AND id != $3)`,
+		username, email, excludeID,
+	).Scan(&exists)
+	return exists, err
+}
+
+func (r *PostgresUsers) CountByRole(ctx context.Context, role string) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users WHERE role = $1", role).Scan(&count)
+	return count, err
+}
+
+func (r *PostgresUsers) Create(ctx context.Context, user *models.User) error {
+	err := r.db.QueryRowContext(ctx,
+		`INSERT INTO users (username, email, password_hash, role)
+		 VALUES ($1, $2, $3, $4) RETURNING id, created_at`,
+		user.Username, user.Email, user.PasswordHash, user.Role,
+	).Scan(&user.ID, &user.CreatedAt)
+	return pgError(err)
+}
+
+func (r *PostgresUsers) Update(ctx context.Context, user *models.User) error {
+	err := r.db.QueryRowContext(ctx,
+		`UPDATE users SET username = $1, email = $2, role = $3,
+		        password_hash = COALESCE(NULLIF($4, ''), password_hash),
+		        email_verified_at = CASE WHEN email = $2 THEN email_verified_at END
+		 WHERE id = $5
+		 RETURNING email_verified_at IS NOT NULL`,
+		user.Username, user.Email, user.Role, user.PasswordHash, user.ID,
+	).Scan(&user.EmailVerified)
+	return pgError(err)
+}
+
+func (r *PostgresUsers) SetPasswordHash(ctx context.Context, id int, hash string) error {
+	return execAffected(r.db.ExecContext(ctx, "UPDATE users SET password_hash = $1 WHERE id = $2", hash, id))
+}
+
+func (r *PostgresUsers) ReplacePasswordHash(ctx context.Context, id int, oldHash, newHash string) (bool, error) {
+	err := execAffected(r.db.ExecContext(ctx,
+		"UPDATE users SET password_hash = $1 WHERE id = $2 AND password_hash = $3", newHash, id, oldHash))
+	if err == ErrNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r *PostgresUsers) Delete(ctx context.Context, id int) error {
+	return execAffected(r.db.ExecContext(ctx, "DELETE FROM users WHERE id = $1", id))
+}
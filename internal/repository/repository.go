@@ -0,0 +1,240 @@
+// Package repository - доступ к данным каталога, пользователей и служебным таблицам
+// входа (роли, сессии, 2FA, API ключи, блокировки, токены из писем, привязки SSO).
+// Обработчики работают с интерфейсами, реализации: PostgreSQL (Postgres*)
+// и хранилище в памяти (Memory) для тестов и запуска без базы данных.
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"cosmos/internal/models"
+)
+
+var (
+	// ErrNotFound - записи с таким ID (логином, email) нет
+	ErrNotFound = errors.New("запись не найдена")
+	// ErrConflict - нарушена уникальность (имя планеты или галактики, логин, email)
+	ErrConflict = errors.New("запись с такими уникальными полями уже существует")
+	// ErrReference - ссылка на несуществующую запись (например, galaxy_id планеты)
+	ErrReference = errors.New("связанная запись не найдена")
+)
+
+// Order - порядок записей в списке
+type Order int
+
+const (
+	ByName      Order = iota // по имени
+	NewestFirst              // новые первыми (по убыванию ID)
+)
+
+// PlanetRepository - планеты. GalaxyName заполняется по galaxy_id при чтении.
+type PlanetRepository interface {
+	List(ctx context.Context, order Order) ([]models.Planet, error)
+	ListByGalaxy(ctx context.Context, galaxyID int) ([]models.Planet, error)
+	Get(ctx context.Context, id int) (models.Planet, error)
+	Count(ctx context.Context) (int, error)
+	CountByGalaxy(ctx context.Context, galaxyID int) (int, error)
+	// Create заполняет ID и CreatedAt
+	Create(ctx context.Context, planet *models.Planet) error
+	// Update заполняет UpdatedAt; ErrNotFound, если планеты нет
+	Update(ctx context.Context, id int, planet *models.Planet) error
+	Delete(ctx context.Context, id int) error
+}
+
+// GalaxyRepository - галактики
+type GalaxyRepository interface {
+	List(ctx context.Context, order Order) ([]models.Galaxy, error)
+	Get(ctx context.Context, id int) (models.Galaxy, error)
+	Count(ctx context.Context) (int, error)
+	// Create заполняет ID и CreatedAt
+	Create(ctx context.Context, galaxy *models.Galaxy) error
+	// Update заполняет UpdatedAt; ErrNotFound, если галактики нет
+	Update(ctx context.Context, id int, galaxy *models.Galaxy) error
+	// DeleteDetachingPlanets - удаление галактики с отвязкой ее планет;
+	// возвращает число отвязанных планет
+	DeleteDetachingPlanets(ctx context.Context, id int) (int64, error)
+}
+
+// UserRepository - пользователи. Пользователь читается вместе с хэшем пароля
+// (в JSON он не попадает), хэширование - забота вызывающего.
+type UserRepository interface {
+	List(ctx context.Context) ([]models.User, error) // новые первыми
+	Get(ctx context.Context, id int) (models.User, error)
+	GetByUsername(ctx context.Context, username string) (models.User, error)
+	GetByEmail(ctx context.Context, email string) (models.User, error)
+	// Exists - занят ли логин или email кем-то, кроме excludeID
+	Exists(ctx context.Context, username, email string, excludeID int) (bool, error)
+	CountByRole(ctx context.Context, role string) (int, error)
+	// Create сохраняет user.PasswordHash и заполняет ID и CreatedAt
+	Create(ctx context.Context, user *models.User) error
+	// Update меняет логин, email и роль, а также хэш пароля, если он не пустой.
+	// Со сменой email адрес снова считается неподтвержденным.
+	Update(ctx context.Context, user *models.User) error
+	SetPasswordHash(ctx context.Context, id int, hash string) error
+	// ReplacePasswordHash - замена хэша, только если он все еще равен oldHash;
+	// false, если пароль успели сменить
+	ReplacePasswordHash(ctx context.Context, id int, oldHash, newHash string) (bool, error)
+	Delete(ctx context.Context, id int) error
+}
+
+// RoleRepository - роли и их права
+type RoleRepository interface {
+	List(ctx context.Context) ([]models.Role, error) // с правами, по имени
+	Exists(ctx context.Context, role string) (bool, error)
+	Permissions(ctx context.Context, role string) (map[string]bool, error)
+	HasPermission(ctx context.Context, role, permission string) (bool, error)
+}
+
+// SessionOwner - сессия и ее пользователь
+type SessionOwner struct {
+	SessionID int64
+	UserID    int
+	Username  string
+	Role      string
+}
+
+// SessionRepository - сессии входа. Refresh токены хранятся только в виде хэша.
+type SessionRepository interface {
+	// Create - новая сессия; возвращает ее ID
+	Create(ctx context.Context, userID int, tokenHash, userAgent, ip string, expiresAt time.Time) (int64, error)
+	// Rotate - замена refresh токена живой сессии; прежний хэш запоминается, чтобы
+	// распознать его повторное предъявление. ErrNotFound, если живой сессии с таким
+	// токеном нет.
+	Rotate(ctx context.Context, tokenHash, newHash, userAgent, ip string, expiresAt time.Time) (SessionOwner, error)
+	// ByPreviousToken - неотозванная сессия, у которой tokenHash - предыдущий refresh
+	// токен; recent - сессия обновлялась за последние within. ErrNotFound, если такой нет.
+	ByPreviousToken(ctx context.Context, tokenHash string, within time.Duration) (id int64, userID int, recent bool, err error)
+	// Valid - жива ли сессия пользователя и по-прежнему ли у него роль role
+	Valid(ctx context.Context, id int64, userID int, role string) (bool, error)
+	Revoke(ctx context.Context, id int64) error
+	RevokeByToken(ctx context.Context, tokenHash string) error
+	// RevokeUser - отзыв всех сессий пользователя; возвращает число отозванных
+	RevokeUser(ctx context.Context, userID int) (int64, error)
+	ListActive(ctx context.Context) ([]models.Session, error) // недавно использованные первыми
+}
+
+// TOTPState - состояние второго фактора пользователя
+type TOTPState struct {
+	Secret   string // пусто, если настройка не начиналась
+	Enabled  bool
+	LastStep int64
+	Required bool // обязателен для роли пользователя
+}
+
+// SecretCipher - шифрование секретов перед записью в БД (auth.SecretBox). associated
+// привязывает шифротекст к записи, чтобы его нельзя было перенести другому пользователю.
+type SecretCipher interface {
+	Seal(plaintext, associated string) (string, error)
+	Open(sealed, associated string) (string, error)
+	// Sealed - зашифровано ли значение, а не сохранено открытым текстом до шифрования
+	Sealed(value string) bool
+}
+
+// TOTPRepository - секреты TOTP (в PostgreSQL - зашифрованные SecretCipher) и коды
+// восстановления (хранятся в виде хэша). Секреты передаются и возвращаются открытым текстом.
+type TOTPRepository interface {
+	// State - ErrNotFound, если пользователя нет
+	State(ctx context.Context, userID int) (TOTPState, error)
+	// SetPendingSecret - секрет для настройки; у включенного TOTP секрет не меняется
+	SetPendingSecret(ctx context.Context, userID int, secret string) error
+	// Enable - включение с шагом подтвердившего кода и новыми кодами восстановления;
+	// ErrNotFound, если настройка не начиналась или TOTP уже включен
+	Enable(ctx context.Context, userID int, step int64, codeHashes []string) error
+	// Disable - удаление секрета и кодов восстановления
+	Disable(ctx context.Context, userID int) error
+	ReplaceRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error
+	CountRecoveryCodes(ctx context.Context, userID int) (int, error) // неиспользованные
+	// AcceptStep - запоминание шага принятого кода, только если он новее прежнего;
+	// false - код этого шага уже принимался (в том числе параллельным запросом)
+	AcceptStep(ctx context.Context, userID int, step int64) (bool, error)
+	// UseRecoveryCode - погашение кода; false, если кода нет или он уже использован
+	UseRecoveryCode(ctx context.Context, userID int, codeHash string) (bool, error)
+}
+
+// APIKeyRepository - API ключи. Сам ключ не хранится, только его хэш.
+type APIKeyRepository interface {
+	// Create сохраняет хэш ключа и заполняет ID и CreatedAt
+	Create(ctx context.Context, key *models.APIKey, keyHash string) error
+	List(ctx context.Context) ([]models.APIKey, error) // с логинами владельцев, новые первыми
+	Delete(ctx context.Context, id int) error
+	// GetByHash - неистекший ключ с логином владельца и его ролью; ErrNotFound, если нет
+	GetByHash(ctx context.Context, keyHash string) (key models.APIKey, role string, err error)
+	// Touch - обновление last_used_at, если он старше interval
+	Touch(ctx context.Context, id int, interval time.Duration) error
+}
+
+// Виды счетчиков неудачных входов
+const (
+	ThrottleUser = "user" // по логину
+	ThrottleIP   = "ip"   // по адресу клиента
+)
+
+// LoginThrottleRepository - счетчики неудачных входов и блокировки входа
+type LoginThrottleRepository interface {
+	// Locked - закрыт ли сейчас вход для логина или IP
+	Locked(ctx context.Context, user, ip string) (bool, error)
+	// RecordFailure - учет неудачи; неудачи старше window не считаются и счетчик
+	// начинается заново. Возвращает число неудач подряд.
+	RecordFailure(ctx context.Context, kind, key string, window time.Duration) (int, error)
+	// Lock - закрыть вход по счетчику на d
+	Lock(ctx context.Context, kind, key string, d time.Duration) error
+	// Reset - удаление счетчика; false, если его не было
+	Reset(ctx context.Context, kind, key string) (bool, error)
+	// List - действующие блокировки и неудачи за последние window
+	List(ctx context.Context, window time.Duration) ([]models.LoginLock, error)
+	// Purge - удаление счетчиков без блокировки и без неудач за последние window
+	Purge(ctx context.Context, window time.Duration) error
+}
+
+// ActionToken - одноразовый токен из письма (сброс пароля, подтверждение email)
+type ActionToken struct {
+	JTI     string
+	UserID  int
+	Purpose string
+	Email   string // адрес, на который отправлено письмо
+}
+
+// ActionTokenRepository - выданные токены из писем
+type ActionTokenRepository interface {
+	// Issue - сохранение нового токена; прежние неиспользованные токены того же
+	// назначения перестают действовать
+	Issue(ctx context.Context, token ActionToken, expiresAt time.Time) error
+	// Usable - не использован и не истек ли токен и не сменился ли адрес пользователя
+	Usable(ctx context.Context, token ActionToken) (bool, error)
+	// IssuedWithin - выдавался ли пользователю токен этого назначения за последние d
+	IssuedWithin(ctx context.Context, userID int, purpose string, d time.Duration) (bool, error)
+	// ResetPassword - погашение токена и замена хэша пароля; адрес заодно считается
+	// подтвержденным. ErrNotFound, если токен недействителен или адрес пользователя
+	// сменился; во втором случае токен все равно гасится, а пароль не меняется.
+	ResetPassword(ctx context.Context, token ActionToken, passwordHash string) error
+	// VerifyEmail - погашение токена и подтверждение адреса; ошибки как у ResetPassword
+	VerifyEmail(ctx context.Context, token ActionToken) error
+}
+
+// Identity - учетная запись у внешнего провайдера (OpenID Connect)
+type Identity struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+}
+
+// IdentityRepository - привязки учетных записей провайдеров к пользователям
+type IdentityRepository interface {
+	// GetUser - пользователь учетной записи; provisioned - создан при входе через SSO,
+	// а не привязан к существующему. ErrNotFound, если привязки нет.
+	GetUser(ctx context.Context, issuer, subject string) (user models.User, provisioned bool, err error)
+	// Link - привязка к существующему пользователю; повторная привязка ничего не меняет
+	Link(ctx context.Context, identity Identity, userID int) error
+	// Provision - создание пользователя вместе с привязкой; user.PasswordHash
+	// сохраняется как есть, адрес подтвержден, если его подтвердил провайдер.
+	// Заполняет ID, CreatedAt и EmailVerified.
+	Provision(ctx context.Context, identity Identity, user *models.User) error
+	// Sync - роль пользователя, время входа через учетную запись и подтверждение
+	// адреса, если провайдер подтвердил тот же адрес, что у пользователя. Адрес
+	// подтверждается только у созданного через SSO: у привязанного локального
+	// пользователя владение адресом доказывает его собственное подтверждение.
+	Sync(ctx context.Context, identity Identity, userID int, role string) error
+}
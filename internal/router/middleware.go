@@ -0,0 +1,156 @@
+package router
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"cosmos/internal/logging"
+)
+
+// StatusRecorder - ResponseWriter, запоминающий код ответа и размер тела.
+// Общий для middleware, которым нужен результат запроса (лог, метрики, трассировка).
+type StatusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+// NewStatusRecorder - обертка над w
+func NewStatusRecorder(w http.ResponseWriter) *StatusRecorder {
+	return &StatusRecorder{ResponseWriter: w}
+}
+
+// Status - код ответа; 200, если обработчик ничего не записал
+func (w *StatusRecorder) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// Bytes - сколько байт тела записано
+func (w *StatusRecorder) Bytes() int {
+	return w.bytes
+}
+
+func (w *StatusRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *StatusRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+	return n, err
+}
+
+// Unwrap - доступ http.ResponseController к исходному ResponseWriter (Flush и т.п.)
+func (w *StatusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// RequestIDHeader - заголовок с ID запроса. Принятый от прокси ID сохраняется,
+// иначе создается новый; в ответе заголовок возвращается клиенту.
+const RequestIDHeader = "X-Request-ID"
+
+// RequestID - middleware: ID запроса в контексте (для логов и запросов к БД) и в ответе
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !logging.ValidRequestID(id) {
+			id = logging.NewRequestID()
+		}
+
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(logging.WithRequestID(r.Context(), id)))
+	})
+}
+
+// Logger - middleware: access log, по записи на каждый запрос с кодом ответа,
+// временем обработки и пользователем. Ответы 5xx пишутся с уровнем ERROR.
+func Logger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := NewStatusRecorder(w)
+
+		next.ServeHTTP(rec, r)
+
+		status := rec.Status()
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+
+		slog.LogAttrs(r.Context(), level, "HTTP запрос",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", status),
+			slog.Int("bytes", rec.Bytes()),
+			slog.Duration("latency", time.Since(start)),
+		)
+	})
+}
+
+// Recover - middleware: паника в обработчике превращается в ответ 500 вместо
+// обрыва соединения, стек вызовов пишется в лог
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+			// ErrAbortHandler - штатный способ прервать ответ, net/http обработает его сам
+			if err == http.ErrAbortHandler {
+				panic(err)
+			}
+			slog.ErrorContext(r.Context(), "Паника при обработке запроса",
+				"method", r.Method, "path", r.URL.Path, "panic", err, "stack", string(debug.Stack()))
+			http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Observe - middleware для сбора статистики: после ответа fn получает шаблон
+// маршрута без метода ("/planets/{id}"; пустая строка, если маршрут не найден),
+// код ответа и время обработки. Шаблон вместо пути ограничивает число
+// различных значений, поэтому подходит для меток метрик.
+func Observe(fn func(r *http.Request, route string, status int, elapsed time.Duration)) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			ctx := TrackRoute(r.Context())
+			rec := NewStatusRecorder(w)
+
+			next.ServeHTTP(rec, r.WithContext(ctx))
+
+			fn(r, Route(ctx), rec.Status(), time.Since(start))
+		})
+	}
+}
+
+// Timeout - middleware: дедлайн контекста запроса. Запросы к БД с контекстом
+// запроса прерываются по дедлайну, а при обрыве соединения клиентом - сразу,
+// вместо того чтобы работать, когда ответ уже никто не ждет. d <= 0 - без ограничения.
+func Timeout(d time.Duration) Middleware {
+	return func(next http.Handler) http.Handler {
+		if d <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// Middleware - обертка над обработчиком: проверка доступа, логирование и т.п.
+type Middleware func(http.Handler) http.Handler
+
+// Chain - применение middleware к обработчику. Первый в списке оказывается
+// внешним, то есть видит запрос раньше остальных.
+func Chain(h http.Handler, middleware ...Middleware) http.Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		h = middleware[i](h)
+	}
+	return h
+}
+
+// Router - маршрутизатор поверх http.ServeMux с шаблонами Go 1.22
+// ("GET /planets/{id}", параметры через r.PathValue), группами маршрутов
+// с общим префиксом и цепочками middleware.
+//
+// Middleware группы применяются только к ее маршрутам: на 404 и 405 от ServeMux
+// они не срабатывают, поэтому проверка доступа не раскрывает существование путей.
+type Router struct {
+	mux        *http.ServeMux
+	prefix     string
+	middleware []Middleware
+}
+
+func New() *Router {
+	return &Router{mux: http.NewServeMux()}
+}
+
+// Use - добавление middleware ко всем маршрутам, зарегистрированным после вызова
+func (rt *Router) Use(middleware ...Middleware) {
+	rt.middleware = append(rt.middleware, middleware...)
+}
+
+// Group - группа маршрутов с префиксом пути и дополнительными middleware.
+// Группа наследует префикс и middleware родителя и регистрирует маршруты в тот же ServeMux.
+func (rt *Router) Group(prefix string, middleware ...Middleware) *Router {
+	return &Router{
+		mux:        rt.mux,
+		prefix:     rt.prefix + strings.TrimSuffix(prefix, "/"),
+		middleware: append(append([]Middleware{}, rt.middleware...), middleware...),
+	}
+}
+
+// With - группа без префикса: те же маршруты, но с дополнительными middleware
+func (rt *Router) With(middleware ...Middleware) *Router {
+	return rt.Group("", middleware...)
+}
+
+// Handle - регистрация обработчика. Шаблон в формате http.ServeMux:
+// "[METHOD ]/path", путь дополняется префиксом группы. Путь "/" в группе
+// обозначает сам префикс: Group("/admin").HandleFunc("GET /", ...) обслуживает ровно /admin.
+func (rt *Router) Handle(pattern string, h http.Handler) {
+	middleware := append([]Middleware{recordRoute}, rt.middleware...)
+	rt.mux.Handle(rt.pattern(pattern), Chain(h, middleware...))
+}
+
+func (rt *Router) HandleFunc(pattern string, fn http.HandlerFunc) {
+	rt.Handle(pattern, fn)
+}
+
+// pattern - полный шаблон маршрута с префиксом группы
+func (rt *Router) pattern(pattern string) string {
+	if rt.prefix == "" {
+		return pattern
+	}
+
+	method, path, ok := strings.Cut(pattern, " ")
+	if !ok {
+		method, path = "", pattern
+	}
+	path = strings.TrimLeft(path, " ")
+	if path == "/" {
+		path = ""
+	}
+	if method == "" {
+		return rt.prefix + path
+	}
+	return method + " " + rt.prefix + path
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rt.mux.ServeHTTP(w, r)
+}
+
+// routeHolder - шаблон маршрута, выбранный ServeMux. Хранится в контексте по указателю:
+// ServeMux заполняет r.Pattern у копии запроса, которую внешние middleware не видят.
+type routeHolder struct {
+	pattern string
+}
+
+type routeKey struct{}
+
+// TrackRoute - контекст, в который Router запишет шаблон маршрута для Route.
+// Подключается внешним middleware до вызова Router; повторный вызов ничего не меняет.
+func TrackRoute(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(routeKey{}).(*routeHolder); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, routeKey{}, &routeHolder{})
+}
+
+// Route - шаблон маршрута без метода ("/planets/{id}"), выбранный Router для
+// запроса с контекстом из TrackRoute. Пустая строка, если маршрут не найден или
+// обработчик еще не вызван.
+func Route(ctx context.Context) string {
+	holder, ok := ctx.Value(routeKey{}).(*routeHolder)
+	if !ok {
+		return ""
+	}
+	if _, path, ok := strings.Cut(holder.pattern, " "); ok {
+		return path
+	}
+	return holder.pattern
+}
+
+// recordRoute - запоминание шаблона маршрута до middleware группы, чтобы
+// запросы, отклоненные проверкой прав, тоже учитывались по маршруту
+func recordRoute(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if holder, ok := r.Context().Value(routeKey{}).(*routeHolder); ok {
+			holder.pattern = r.Pattern
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,208 @@
+package router
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"cosmos/internal/logging"
+)
+
+func TestMain(m *testing.M) {
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError + 1})))
+	os.Exit(m.Run())
+}
+
+// trace - middleware, дописывающий name в журнал вызовов
+func trace(calls *[]string, name string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*calls = append(*calls, name)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func serve(h http.Handler, method, target string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+	return rec
+}
+
+// Middleware вызываются от внешнего к внутреннему: Use, затем Group, затем With;
+// Use действует только на маршруты, зарегистрированные после него
+func TestMiddlewareOrder(t *testing.T) {
+	var calls []string
+	rt := New()
+	rt.Use(trace(&calls, "root"))
+	api := rt.Group("/api", trace(&calls, "group"))
+	api.With(trace(&calls, "with")).HandleFunc("GET /planets", func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, "handler")
+	})
+	api.HandleFunc("GET /moons", func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, "handler")
+	})
+	rt.Use(trace(&calls, "late"))
+	rt.HandleFunc("GET /late", func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, "handler")
+	})
+
+	for _, tc := range []struct {
+		target string
+		want   []string
+	}{
+		{"/api/planets", []string{"root", "group", "with", "handler"}},
+		{"/api/moons", []string{"root", "group", "handler"}},
+		{"/late", []string{"root", "late", "handler"}},
+	} {
+		calls = nil
+		if rec := serve(rt, http.MethodGet, tc.target); rec.Code != http.StatusOK {
+			t.Errorf("%s: статус %d", tc.target, rec.Code)
+		}
+		if !slices.Equal(calls, tc.want) {
+			t.Errorf("%s: вызовы %q, want %q", tc.target, calls, tc.want)
+		}
+	}
+}
+
+// Middleware группы не срабатывают на 404 и 405: проверка доступа не раскрывает пути
+func TestGroupMiddlewareSkipsUnmatched(t *testing.T) {
+	var calls []string
+	rt := New()
+	admin := rt.Group("/admin", trace(&calls, "auth"))
+	admin.HandleFunc("GET /", func(w http.ResponseWriter, r *http.Request) {})
+	admin.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.PathValue("id")))
+	})
+
+	if rec := serve(rt, http.MethodGet, "/admin"); rec.Code != http.StatusOK {
+		t.Errorf("GET /admin: статус %d", rec.Code)
+	}
+	if rec := serve(rt, http.MethodGet, "/admin/users/7"); rec.Code != http.StatusOK || rec.Body.String() != "7" {
+		t.Errorf("GET /admin/users/7: статус %d, тело %q", rec.Code, rec.Body.String())
+	}
+	if len(calls) != 2 {
+		t.Fatalf("вызовы %q", calls)
+	}
+
+	calls = nil
+	if rec := serve(rt, http.MethodGet, "/admin/missing"); rec.Code != http.StatusNotFound {
+		t.Errorf("GET /admin/missing: статус %d", rec.Code)
+	}
+	if rec := serve(rt, http.MethodPost, "/admin/users/7"); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST /admin/users/7: статус %d", rec.Code)
+	}
+	if len(calls) != 0 {
+		t.Errorf("middleware группы вызваны для 404/405: %q", calls)
+	}
+}
+
+// Паника под Recover дает 500, а внешние middleware видят этот код
+func TestRecover(t *testing.T) {
+	var status int
+	rt := New()
+	rt.Use(
+		func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				rec := NewStatusRecorder(w)
+				next.ServeHTTP(rec, r)
+				status = rec.Status()
+			})
+		},
+		Recover,
+	)
+	rt.HandleFunc("GET /panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("сбой")
+	})
+	rt.HandleFunc("GET /abort", func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	})
+
+	rec := serve(rt, http.MethodGet, "/panic")
+	if rec.Code != http.StatusInternalServerError || status != http.StatusInternalServerError {
+		t.Errorf("статус %d, во внешнем middleware %d", rec.Code, status)
+	}
+	if strings.Contains(rec.Body.String(), "сбой") {
+		t.Errorf("текст паники в ответе: %q", rec.Body.String())
+	}
+
+	// ErrAbortHandler не перехватывается: net/http сам обрывает ответ
+	defer func() {
+		if err := recover(); err != http.ErrAbortHandler {
+			t.Errorf("recover() = %v, want http.ErrAbortHandler", err)
+		}
+	}()
+	serve(rt, http.MethodGet, "/abort")
+	t.Error("ErrAbortHandler перехвачен")
+}
+
+// RequestID принимает допустимый ID от прокси, заменяет недопустимый и кладет его в контекст
+func TestRequestID(t *testing.T) {
+	var ctxID string
+	h := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctxID = logging.RequestID(r.Context())
+	}))
+
+	for header, keep := range map[string]bool{"proxy-1": true, "": false, "bad id\n": false} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(RequestIDHeader, header)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		id := rec.Header().Get(RequestIDHeader)
+		if id != ctxID || !logging.ValidRequestID(id) || (id == header) != keep {
+			t.Errorf("заголовок %q: в ответе %q, в контексте %q", header, id, ctxID)
+		}
+	}
+}
+
+// Observe получает шаблон маршрута, в том числе для запроса, отклоненного middleware группы
+func TestObserveRoute(t *testing.T) {
+	var routes []string
+	rt := New()
+	deny := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "Доступ запрещен", http.StatusForbidden)
+		})
+	}
+	rt.HandleFunc("GET /planets/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	rt.Group("/admin", deny).HandleFunc("DELETE /planets/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	h := Observe(func(r *http.Request, route string, status int, _ time.Duration) {
+		routes = append(routes, route+" "+strconv.Itoa(status))
+	})(rt)
+
+	serve(h, http.MethodGet, "/planets/42")
+	serve(h, http.MethodDelete, "/admin/planets/42")
+	serve(h, http.MethodGet, "/stars/1")
+
+	want := []string{"/planets/{id} 200", "/admin/planets/{id} 403", " 404"}
+	if !slices.Equal(routes, want) {
+		t.Errorf("маршруты %q, want %q", routes, want)
+	}
+}
+
+// Timeout задает дедлайн контексту запроса; при d <= 0 дедлайна нет
+func TestTimeout(t *testing.T) {
+	var deadline time.Time
+	var ok bool
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deadline, ok = r.Context().Deadline()
+	})
+
+	start := time.Now()
+	serve(Timeout(time.Minute)(h), http.MethodGet, "/")
+	if !ok || deadline.Before(start.Add(time.Minute)) || deadline.After(time.Now().Add(time.Minute)) {
+		t.Errorf("дедлайн %v (%v), want через минуту", deadline, ok)
+	}
+
+	serve(Timeout(0)(h), http.MethodGet, "/")
+	if ok {
+		t.Errorf("дедлайн %v при Timeout(0)", deadline)
+	}
+}
@@ -0,0 +1,168 @@
+package tracing
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+
+	"github.com/XSAM/otelsql"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.39.0"
+)
+
+// SQLOptions - настройки otelsql для пула PostgreSQL: спан на каждый запрос
+// с именем по команде (SELECT, INSERT...) и очищенным текстом запроса.
+// Значения параметров ($1, $2...) в спаны не попадают.
+func SQLOptions() []otelsql.Option {
+	return []otelsql.Option{
+		otelsql.WithAttributes(semconv.DBSystemNamePostgreSQL),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{
+			DisableQuery:         true, // вместо исходного текста - SanitizeSQL
+			DisableErrSkip:       true,
+			OmitConnResetSession: true,
+			OmitRows:             true,
+		}),
+		otelsql.WithSpanNameFormatter(func(_ context.Context, method otelsql.Method, query string) string {
+			if op := sqlOperation(query); op != "" {
+				return op
+			}
+			return string(method)
+		}),
+		otelsql.WithAttributesGetter(func(_ context.Context, _ otelsql.Method, query string, _ []driver.NamedValue) []attribute.KeyValue {
+			if query == "" {
+				return nil
+			}
+			return []attribute.KeyValue{
+				semconv.DBQueryText(SanitizeSQL(query)),
+				semconv.DBOperationName(sqlOperation(query)),
+			}
+		}),
+	}
+}
+
+// sqlOperation - первое слово запроса после комментариев (SELECT, INSERT, WITH...)
+func sqlOperation(query string) string {
+	fields := strings.Fields(SanitizeSQL(query))
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.ToUpper(fields[0])
+}
+
+// SanitizeSQL - текст запроса для спана: без комментариев (в том числе
+// request_id), строковые (включая $$...$$) и числовые литералы заменены на ?,
+// пробелы схлопнуты. Параметры $1, $2... остаются как есть.
+func SanitizeSQL(query string) string {
+	var b strings.Builder
+	b.Grow(len(query))
+	space := false
+
+	write := func(s string) {
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+		b.WriteString(s)
+	}
+
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			space = true
+			i++
+
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query) - i
+			}
+			space = true
+			i += end
+
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				i = len(query)
+			} else {
+				i += end + 4
+			}
+			space = true
+
+		case c == '\'':
+			// Строка до закрывающей кавычки; '' внутри - экранированная кавычка
+			i++
+			for i < len(query) {
+				if query[i] == '\'' {
+					if i+1 < len(query) && query[i+1] == '\'' {
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				i++
+			}
+			write("?")
+
+		case c >= '0' && c <= '9':
+			for i < len(query) && (query[i] >= '0' && query[i] <= '9' || query[i] == '.') {
+				i++
+			}
+			write("?")
+
+		case c == '$':
+			if tag, ok := dollarTag(query[i:]); ok {
+				// $$...$$ или $tag$...$tag$ - строка без экранирования, до такого же тега
+				end := strings.Index(query[i+len(tag):], tag)
+				if end < 0 {
+					i = len(query)
+				} else {
+					i += len(tag) + end + len(tag)
+				}
+				write("?")
+				break
+			}
+			// Параметр $N целиком: его номер - не литерал
+			start := i
+			i++
+			for i < len(query) && query[i] >= '0' && query[i] <= '9' {
+				i++
+			}
+			write(query[start:i])
+
+		case isIdentChar(c):
+			// Идентификатор целиком: цифры и $ в нем - не литералы и не параметры
+			start := i
+			i++
+			for i < len(query) && (isIdentChar(query[i]) || query[i] == '$') {
+				i++
+			}
+			write(query[start:i])
+
+		default:
+			write(query[i : i+1])
+			i++
+		}
+	}
+	return b.String()
+}
+
+// dollarTag - открывающий тег строки в долларах в начале s: "$$" или "$tag$".
+// После $ с цифрой идет параметр, а не тег.
+func dollarTag(s string) (string, bool) {
+	j := 1
+	if j < len(s) && isIdentChar(s[j]) && !(s[j] >= '0' && s[j] <= '9') {
+		for j < len(s) && isIdentChar(s[j]) {
+			j++
+		}
+	}
+	if j < len(s) && s[j] == '$' {
+		return s[:j+1], true
+	}
+	return "", false
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
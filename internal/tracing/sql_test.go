@@ -0,0 +1,48 @@
+package tracing
+
+import "testing"
+
+func TestSanitizeSQL(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"параметры", "SELECT * FROM planets WHERE id = $1 AND galaxy_id = $12", "SELECT * FROM planets WHERE id = $1 AND galaxy_id = $12"},
+		{"строка", "SELECT * FROM users WHERE email = 'a@b.c'", "SELECT * FROM users WHERE email = ?"},
+		{"экранированная кавычка", "SELECT 'it''s', 'x'", "SELECT ?, ?"},
+		{"незакрытая строка", "SELECT 'secret", "SELECT ?"},
+		{"целое", "SELECT * FROM planets LIMIT 10 OFFSET 20", "SELECT * FROM planets LIMIT ? OFFSET ?"},
+		{"дробное", "UPDATE planets SET mass = 5.97 WHERE id = $1", "UPDATE planets SET mass = ? WHERE id = $1"},
+		{"цифры в идентификаторе", "SELECT col1, t2.x FROM t2", "SELECT col1, t2.x FROM t2"},
+		{"строчный комментарий", "SELECT 1 -- request_id=abc\nFROM t", "SELECT ? FROM t"},
+		{"блочный комментарий", "/* request_id=abc */ SELECT * FROM t", "SELECT * FROM t"},
+		{"незакрытый комментарий", "SELECT 1 /* secret", "SELECT ?"},
+		{"кавычка в комментарии", "SELECT a -- it's\nFROM t", "SELECT a FROM t"},
+		{"пробелы", "SELECT\n\t a ,\r\n  b   FROM t", "SELECT a , b FROM t"},
+		{"доллары", "SELECT $$secret 'text'$$, $1", "SELECT ?, $1"},
+		{"доллары с тегом", "SELECT $body$it's $$ nested$body$ FROM t", "SELECT ? FROM t"},
+		{"незакрытые доллары", "SELECT $tag$secret", "SELECT ?"},
+		{"доллар в идентификаторе", "SELECT a$b FROM t", "SELECT a$b FROM t"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SanitizeSQL(tt.query); got != tt.want {
+				t.Errorf("SanitizeSQL(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSQLOperation(t *testing.T) {
+	tests := map[string]string{
+		"/* request_id=abc */ select 1":   "SELECT",
+		"-- x\nINSERT INTO t VALUES ($1)": "INSERT",
+		"  ":                              "",
+	}
+	for query, want := range tests {
+		if got := sqlOperation(query); got != want {
+			t.Errorf("sqlOperation(%q) = %q, want %q", query, got, want)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+// Package tracing - трассировка OpenTelemetry: спаны HTTP запросов, запросов к БД
+// и выполнения шаблонов с экспортом по OTLP или в stdout.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"cosmos/internal/buildinfo"
+	"cosmos/internal/router"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.39.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName - имя, под которым приложение создает спаны
+const instrumentationName = "cosmos"
+
+// Tracer - трассировщик приложения. До Setup и при экспорте none спаны не записываются.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Setup - глобальный провайдер трассировки. exporter:
+//   - otlp - OTLP/HTTP, адрес и заголовки из стандартных OTEL_EXPORTER_OTLP_*;
+//   - stdout - спаны в stdout в JSON, для локальной отладки;
+//   - none или пусто - трассировка выключена.
+//
+// Доля записываемых трасс задается стандартными OTEL_TRACES_SAMPLER и
+// OTEL_TRACES_SAMPLER_ARG, имя сервиса - OTEL_SERVICE_NAME.
+// Возвращаемая функция отправляет накопленные спаны; ее нужно вызвать при остановке.
+func Setup(ctx context.Context, exporter string) (func(context.Context) error, error) {
+	// Заголовки traceparent принимаются и передаются дальше даже без экспорта
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{},
+	))
+
+	var spanExporter sdktrace.SpanExporter
+	var err error
+	switch strings.ToLower(exporter) {
+	case "", "none":
+		return func(context.Context) error { return nil }, nil
+	case "otlp":
+		spanExporter, err = otlptracehttp.New(ctx)
+	case "stdout", "console":
+		spanExporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	default:
+		return nil, fmt.Errorf("неизвестный экспорт трасс %q (otlp, stdout или none)", exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("не удалось создать экспорт трасс: %w", err)
+	}
+
+	// Переменные окружения (OTEL_SERVICE_NAME, OTEL_RESOURCE_ATTRIBUTES) важнее значений по умолчанию
+	res, err := resource.New(ctx,
+		resource.WithSchemaURL(semconv.SchemaURL),
+		resource.WithAttributes(
+			semconv.ServiceName("cosmos"),
+			semconv.ServiceVersion(buildinfo.Get().Version),
+		),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось описать сервис для трасс: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(spanExporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Middleware - спан на каждый HTTP запрос. Родительская трасса берется из
+// заголовка traceparent. Имя спана - метод и шаблон маршрута ("GET /planets/{id}"),
+// чтобы запросы к разным планетам группировались вместе.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx = router.TrackRoute(ctx)
+		ctx, span := Tracer().Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.URLPath(r.URL.Path),
+				semconv.UserAgentOriginal(r.UserAgent()),
+			),
+		)
+		defer span.End()
+
+		rec := router.NewStatusRecorder(w)
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		status := rec.Status()
+		if route := router.Route(ctx); route != "" {
+			span.SetName(r.Method + " " + route)
+			span.SetAttributes(semconv.HTTPRoute(route))
+		}
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	})
+}
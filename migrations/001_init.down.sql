@@ -0,0 +1,4 @@
+-- Откат 001_init: удаляет каталог и пользователей вместе со всеми данными
+DROP TABLE IF EXISTS planets;
+DROP TABLE IF EXISTS galaxies;
+DROP TABLE IF EXISTS users CASCADE;
@@ -1,5 +1,5 @@
 -- Создание таблиц с явным указанием кодировки
-SET client_encoding = 'UTF8';git add .
+SET client_encoding = 'UTF8';
 
 -- Создание таблиц
 CREATE TABLE IF NOT EXISTS galaxies (
@@ -14,15 +14,17 @@ CREATE TABLE IF NOT EXISTS galaxies (
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
-DROP TABLE IF EXISTS planets CASCADE;
 
--- Вставка тестовых данных для галактик
-INSERT INTO galaxies (name, type, diameter_ly, mass_suns, distance_from_earth_ly, discovered_year, description) VALUES
+-- Вставка тестовых данных для галактик (только в пустую таблицу)
+INSERT INTO galaxies (name, type, diameter_ly, mass_suns, distance_from_earth_ly, discovered_year, description)
+SELECT * FROM (VALUES
 ('Млечный Путь', 'спиральная', 100000, 1500000000000, 0, -1, 'Наша родная галактика, содержащая Солнечную систему.'),
 ('Андромеда', 'спиральная', 220000, 1200000000000, 2537000, 964, 'Ближайшая к Млечному Пути крупная галактика.'),
 ('Треугольник', 'спиральная', 60000, 50000000000, 3000000, 1654, 'Третья по величине галактика в Местной группе.'),
 ('Сомбреро', 'спиральная', 50000, 800000000000, 29000000, 1781, 'Галактика в созвездии Девы, известная своим ярким ядром.'),
-('Сигара', 'неправильная', 37000, 30000000000, 12000000, 1774, 'Галактика со вспышкой звездообразования в созвездии Большой Медведицы.');
+('Сигара', 'неправильная', 37000, 30000000000, 12000000, 1774, 'Галактика со вспышкой звездообразования в созвездии Большой Медведицы.')
+) AS seed
+WHERE NOT EXISTS (SELECT 1 FROM galaxies);
 
 CREATE TABLE IF NOT EXISTS planets (
     id SERIAL PRIMARY KEY,
@@ -40,8 +42,9 @@ CREATE TABLE IF NOT EXISTS planets (
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
--- Вставка тестовых данных для планет
-INSERT INTO planets (name, galaxy_id, type, diameter_km, mass_kg, orbital_period_days, has_life, is_habitable, discovered_year, description) VALUES
+-- Вставка тестовых данных для планет (только в пустую таблицу)
+INSERT INTO planets (name, galaxy_id, type, diameter_km, mass_kg, orbital_period_days, has_life, is_habitable, discovered_year, description)
+SELECT * FROM (VALUES
 ('Земля', 1, 'землеподобная', 12742, 5.972e24, 365.25, true, true, -1, 'Третья планета от Солнца, единственная известная планета с жизнью.'),
 ('Марс', 1, 'землеподобная', 6779, 6.39e23, 687, false, true, -1, 'Красная планета, четвертая от Солнца. Имеет два спутника.'),
 ('Юпитер', 1, 'газовый гигант', 139820, 1.898e27, 4333, false, false, -1, 'Крупнейшая планета Солнечной системы.'),
@@ -49,7 +52,9 @@ INSERT INTO planets (name, galaxy_id, type, diameter_km, mass_kg, orbital_period
 ('Венера', 1, 'землеподобная', 12104, 4.867e24, 225, false, false, -1, 'Вторая планета от Солнца, самая горячая планета системы.'),
 ('Кеплер-186f', 2, 'землеподобная', 14800, 5.5e24, 130, true, true, 2014, 'Первая землеподобная планета в обитаемой зоне другой звезды.'),
 ('TRAPPIST-1e', 3, 'землеподобная', 10500, 4.0e24, 6.1, true, true, 2017, 'Планета в системе TRAPPIST-1, потенциально пригодная для жизни.'),
-('HD 209458 b', 4, 'газовый гигант', 218000, 2.2e27, 3.5, false, false, 1999, 'Первая планета, обнаруженная методом транзита.');
+('HD 209458 b', 4, 'газовый гигант', 218000, 2.2e27, 3.5, false, false, 1999, 'Первая планета, обнаруженная методом транзита.')
+) AS seed
+WHERE NOT EXISTS (SELECT 1 FROM planets);
 
 CREATE TABLE IF NOT EXISTS users (
     id SERIAL PRIMARY KEY,
@@ -60,11 +65,12 @@ CREATE TABLE IF NOT EXISTS users (
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
--- Создание администратора (пароль: admin123)
-INSERT INTO users (username, email, password_hash, role) VALUES
-('admin', 'admin@cosmos.ru', '$2a$10$N9qo8uLOickgx2ZMRZoMye1G3YZ5QzYbhFgJYVVpQp6.6dQ2Z7W6y', 'admin');
+-- Создание администратора (пароль: admin123), только если пользователей еще нет
+INSERT INTO users (username, email, password_hash, role)
+SELECT 'admin', 'admin@cosmos.ru', '$2a$10$N9qo8uLOickgx2ZMRZoMye1G3YZ5QzYbhFgJYVVpQp6.6dQ2Z7W6y', 'admin'
+WHERE NOT EXISTS (SELECT 1 FROM users);
 
 -- Создание индексов для ускорения поиска
-CREATE INDEX idx_planets_galaxy_id ON planets(galaxy_id);
-CREATE INDEX idx_planets_name ON planets(name);
-CREATE INDEX idx_galaxies_name ON galaxies(name);
+CREATE INDEX IF NOT EXISTS idx_planets_galaxy_id ON planets(galaxy_id);
+CREATE INDEX IF NOT EXISTS idx_planets_name ON planets(name);
+CREATE INDEX IF NOT EXISTS idx_galaxies_name ON galaxies(name);
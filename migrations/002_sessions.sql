@@ -0,0 +1,20 @@
+-- Серверные сессии: refresh токены и отзыв входа
+SET client_encoding = 'UTF8';
+
+CREATE TABLE IF NOT EXISTS sessions (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    -- Храним только хэши токенов
+    refresh_token_hash TEXT UNIQUE NOT NULL,
+    -- Хэш предыдущего токена: его повторное предъявление означает кражу
+    previous_token_hash TEXT,
+    user_agent TEXT,
+    ip_address VARCHAR(64),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id);
+CREATE INDEX IF NOT EXISTS idx_sessions_previous_token_hash ON sessions(previous_token_hash);
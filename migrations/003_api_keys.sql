@@ -0,0 +1,18 @@
+-- API ключи для доступа сервисов без пароля пользователя
+SET client_encoding = 'UTF8';
+
+CREATE TABLE IF NOT EXISTS api_keys (
+    id SERIAL PRIMARY KEY,
+    -- Ключ действует с правами владельца, но не шире выданных scopes
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    -- Первые символы ключа для узнавания в списке; сам ключ хранится только как хэш
+    prefix VARCHAR(20) NOT NULL,
+    key_hash TEXT UNIQUE NOT NULL,
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    expires_at TIMESTAMP,
+    last_used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys(user_id);
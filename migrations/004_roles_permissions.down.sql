@@ -0,0 +1,9 @@
+-- Возврат к роли из ограничения CHECK. NOT VALID: пользователи с ролями editor и viewer
+-- остаются как есть, ограничение действует для новых и измененных записей.
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_fkey;
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_check;
+ALTER TABLE users ADD CONSTRAINT users_role_check CHECK (role IN ('admin', 'user')) NOT VALID;
+
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS permissions;
+DROP TABLE IF EXISTS roles;
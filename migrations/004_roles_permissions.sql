@@ -0,0 +1,63 @@
+-- Роли и права доступа
+SET client_encoding = 'UTF8';
+
+CREATE TABLE IF NOT EXISTS roles (
+    name VARCHAR(20) PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS permissions (
+    name VARCHAR(50) PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role VARCHAR(20) NOT NULL REFERENCES roles(name) ON UPDATE CASCADE ON DELETE CASCADE,
+    permission VARCHAR(50) NOT NULL REFERENCES permissions(name) ON UPDATE CASCADE ON DELETE CASCADE,
+    PRIMARY KEY (role, permission)
+);
+
+INSERT INTO roles (name, description) VALUES
+('admin', 'Администратор'),
+('editor', 'Редактор'),
+('viewer', 'Наблюдатель'),
+('user', 'Пользователь')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO permissions (name, description) VALUES
+('admin.access', 'Вход в админ-панель и просмотр каталога'),
+('planets.write', 'Создание и изменение планет'),
+('planets.delete', 'Удаление планет'),
+('galaxies.write', 'Создание и изменение галактик'),
+('galaxies.delete', 'Удаление галактик'),
+('users.view', 'Просмотр пользователей'),
+('users.manage', 'Создание, изменение и удаление пользователей'),
+('sessions.manage', 'Просмотр и завершение сессий'),
+('api_keys.manage', 'Выпуск и отзыв API ключей')
+ON CONFLICT (name) DO NOTHING;
+
+-- Администратор получает все права
+INSERT INTO role_permissions (role, permission)
+SELECT 'admin', name FROM permissions
+ON CONFLICT DO NOTHING;
+
+INSERT INTO role_permissions (role, permission) VALUES
+('editor', 'admin.access'),
+('editor', 'planets.write'),
+('editor', 'planets.delete'),
+('editor', 'galaxies.write'),
+('editor', 'galaxies.delete'),
+('viewer', 'admin.access'),
+('viewer', 'users.view')
+ON CONFLICT DO NOTHING;
+
+-- Допустимые роли теперь задаются таблицей roles, а не ограничением CHECK
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_check;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'users_role_fkey') THEN
+        ALTER TABLE users ADD CONSTRAINT users_role_fkey
+            FOREIGN KEY (role) REFERENCES roles(name) ON UPDATE CASCADE;
+    END IF;
+END $$;
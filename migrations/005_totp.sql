@@ -0,0 +1,21 @@
+-- Двухфакторная аутентификация (TOTP, RFC 6238)
+SET client_encoding = 'UTF8';
+
+-- Секрет появляется при начале настройки; totp_enabled_at - после подтверждения кодом
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_secret VARCHAR(64);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_enabled_at TIMESTAMP;
+-- Шаг времени последнего принятого кода: один и тот же код дважды не принимается
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_last_step BIGINT NOT NULL DEFAULT 0;
+
+-- Одноразовые коды восстановления (хэш SHA-256)
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash VARCHAR(64) NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, code_hash)
+);
+
+-- Роли, для которых второй фактор обязателен
+ALTER TABLE roles ADD COLUMN IF NOT EXISTS require_totp BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,13 @@
+-- Учет неудачных попыток входа по логину и по IP
+SET client_encoding = 'UTF8';
+
+CREATE TABLE IF NOT EXISTS login_throttle (
+    kind VARCHAR(10) NOT NULL CHECK (kind IN ('user', 'ip')),
+    key VARCHAR(255) NOT NULL,
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    locked_until TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (kind, key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_throttle_locked_until ON login_throttle(locked_until);
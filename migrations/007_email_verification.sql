@@ -0,0 +1,17 @@
+-- Подтверждение email и одноразовые токены из писем
+SET client_encoding = 'UTF8';
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMP;
+
+-- Выпущенные токены действий (сброс пароля, подтверждение email). Сам токен подписан
+-- и в БД не хранится; запись нужна, чтобы токен можно было использовать только один раз.
+CREATE TABLE IF NOT EXISTS user_tokens (
+    jti VARCHAR(32) PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    purpose VARCHAR(30) NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_tokens_user_purpose ON user_tokens(user_id, purpose);
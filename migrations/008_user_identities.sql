@@ -0,0 +1,16 @@
+-- Вход через OpenID Connect: привязка учетных записей провайдера к пользователям
+SET client_encoding = 'UTF8';
+
+-- Пара (issuer, subject) однозначно определяет пользователя у провайдера. Email может
+-- меняться, поэтому пользователь ищется по subject, а email хранится для справки.
+CREATE TABLE IF NOT EXISTS user_identities (
+    issuer VARCHAR(255) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    email VARCHAR(100),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_login_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (issuer, subject)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities(user_id);
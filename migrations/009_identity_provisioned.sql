@@ -0,0 +1,12 @@
+-- Пользователи, созданные при входе через SSO, и привязанные к провайдеру локальные учетные записи
+SET client_encoding = 'UTF8';
+
+-- provisioned - пользователь создан провайдером, и его роль задается группами при каждом входе.
+-- У привязанной по email локальной учетной записи роль назначается в cosmos, а вход
+-- через SSO проходит локальный второй фактор.
+ALTER TABLE user_identities ADD COLUMN IF NOT EXISTS provisioned BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Созданные до миграции пользователи SSO узнаются по хэшу пароля-заглушке
+UPDATE user_identities i SET provisioned = TRUE
+FROM users u
+WHERE u.id = i.user_id AND u.password_hash = '!sso';
@@ -0,0 +1,9 @@
+-- Прежняя версия не расшифрует секреты: у таких пользователей 2FA сбрасывается,
+-- ее нужно настроить заново. Секреты открытым текстом остаются как есть.
+DELETE FROM recovery_codes
+WHERE user_id IN (SELECT id FROM users WHERE totp_secret LIKE 'enc:%');
+
+UPDATE users SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = 0
+WHERE totp_secret LIKE 'enc:%';
+
+ALTER TABLE users ALTER COLUMN totp_secret TYPE VARCHAR(64);
@@ -0,0 +1,6 @@
+-- Секрет TOTP хранится зашифрованным (AES-256-GCM, ключ TOTP_ENCRYPTION_KEY):
+-- шифротекст длиннее base32 секрета. Сохраненные ранее открытым текстом секреты
+-- шифрует приложение при запуске.
+SET client_encoding = 'UTF8';
+
+ALTER TABLE users ALTER COLUMN totp_secret TYPE TEXT;
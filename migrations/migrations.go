@@ -0,0 +1,10 @@
+// Package migrations - SQL миграции схемы, встроенные в бинарник.
+// NNN_name.sql применяет миграцию, NNN_name.down.sql откатывает ее.
+package migrations
+
+import "embed"
+
+// FS - файлы миграций
+//
+//go:embed *.sql
+var FS embed.FS
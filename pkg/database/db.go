@@ -1,48 +1,120 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"time"
 
 	"cosmos/config"
+	"cosmos/internal/tracing"
 
-	_ "github.com/lib/pq"
+	"github.com/XSAM/otelsql"
+	"github.com/lib/pq"
 )
 
-var db *sql.DB // приватная переменная
-
-func Connect(cfg *config.Config) error {
-	connStr := fmt.Sprintf(
-		"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
-		cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPassword, cfg.DBName, cfg.DBSSLMode,
-	)
+// Паузы между попытками подключения при запуске
+const (
+	connectRetryDelay    = 500 * time.Millisecond
+	connectRetryMaxDelay = 5 * time.Second
+)
 
-	var err error
-	db, err = sql.Open("postgres", connStr)
+// Open - пул соединений с PostgreSQL по настройкам cfg. Пул не хранится в пакете:
+// вызывающий передает его обработчикам, репозиториям и мигратору и закрывает сам.
+//
+// Пока БД недоступна (например, ее контейнер еще запускается), подключение
+// повторяется с растущей паузой, но не дольше cfg.DBConnectTimeout и не больше
+// cfg.DBConnectAttempts раз. Ошибки, на которые сервер ответил сам (неверный
+// пароль, нет базы), не повторяются.
+func Open(ctx context.Context, cfg *config.Config) (*sql.DB, error) {
+	// Запросы пишутся в трассировку через otelsql; без настроенного экспорта это бесплатно
+	db, err := otelsql.Open("postgres", cfg.DSN(), tracing.SQLOptions()...)
 	if err != nil {
-		return fmt.Errorf("не удалось подключиться к базе данных: %v", err)
+		return nil, fmt.Errorf("неверные параметры подключения к БД: %w", err)
 	}
+	configurePool(db, cfg)
 
-	if err = db.Ping(); err != nil {
-		return fmt.Errorf("не удалось проверить подключение: %v", err)
+	if err := connectRetryPolicy(cfg).ping(ctx, db); err != nil {
+		db.Close()
+		return nil, err
 	}
 
-	log.Println("Подключение к PostgreSQL установлено")
-	return nil
+	slog.Info("Подключение к PostgreSQL установлено", "max_open_conns", cfg.DBMaxOpenConns)
+	return db, nil
+}
+
+// configurePool - размер пула и время жизни соединений из cfg
+func configurePool(db *sql.DB, cfg *config.Config) {
+	db.SetMaxOpenConns(cfg.DBMaxOpenConns)
+	db.SetMaxIdleConns(cfg.DBMaxIdleConns)
+	db.SetConnMaxLifetime(cfg.DBConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.DBConnMaxIdleTime)
+}
+
+// retryPolicy - повторы подключения при запуске
+type retryPolicy struct {
+	Attempts int           // не больше стольких попыток; 0 - без ограничения
+	Timeout  time.Duration // общее время на все попытки
+	Delay    time.Duration // пауза после первой неудачи, дальше удваивается
+	MaxDelay time.Duration // предел паузы
 }
 
-// GetDB возвращает соединение с БД
-func GetDB() *sql.DB {
-	if db == nil {
-		log.Fatal("БД не подключена! Сначала вызовите Connect()")
+// connectRetryPolicy - повторы по настройкам cfg с паузой от connectRetryDelay
+// до connectRetryMaxDelay
+func connectRetryPolicy(cfg *config.Config) retryPolicy {
+	return retryPolicy{
+		Attempts: cfg.DBConnectAttempts,
+		Timeout:  cfg.DBConnectTimeout,
+		Delay:    connectRetryDelay,
+		MaxDelay: connectRetryMaxDelay,
 	}
-	return db
 }
 
-func Close() {
-	if db != nil {
-		db.Close()
-		log.Println("Подключение к базе данных закрыто")
+// ping - проверка подключения с повторами, пока не кончатся попытки или время
+// либо не будет отменен ctx
+func (p retryPolicy) ping(ctx context.Context, db *sql.DB) error {
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+
+	delay := p.Delay
+	for attempt := 1; ; attempt++ {
+		err := db.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+		if !retryable(err) {
+			return fmt.Errorf("не удалось подключиться к БД: %w", err)
+		}
+		if p.Attempts > 0 && attempt >= p.Attempts {
+			return fmt.Errorf("БД недоступна после %d попыток: %w", attempt, err)
+		}
+
+		slog.Warn("БД недоступна, повторное подключение", "attempt", attempt, "retry_in", delay, "err", err)
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.Canceled) {
+				return fmt.Errorf("подключение к БД прервано после %d попыток: %w", attempt, ctx.Err())
+			}
+			return fmt.Errorf("БД недоступна после %d попыток за %s: %w", attempt, p.Timeout, err)
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, p.MaxDelay)
+	}
+}
+
+// retryable - имеет ли смысл повторить подключение. Ответ сервера с ошибкой
+// означает, что БД работает и повтор не поможет; исключение - БД еще запускается
+// или останавливается.
+func retryable(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return true
+	}
+	switch pqErr.Code.Name() {
+	case "cannot_connect_now", "too_many_connections", "admin_shutdown":
+		return true
 	}
+	return false
 }
@@ -0,0 +1,168 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"cosmos/config"
+
+	"github.com/lib/pq"
+)
+
+var errRefused = errors.New("connection refused")
+
+// testRetryPolicy - повторы без заметных пауз
+func testRetryPolicy(attempts int) retryPolicy {
+	return retryPolicy{Attempts: attempts, Timeout: 5 * time.Second, Delay: time.Millisecond, MaxDelay: 2 * time.Millisecond}
+}
+
+// Пул ограничен DBMaxOpenConns, из освобожденных соединений открытыми остаются DBMaxIdleConns
+func TestConfigurePool(t *testing.T) {
+	db := newFakeDB().open()
+	t.Cleanup(func() { db.Close() })
+	configurePool(db, &config.Config{DBMaxOpenConns: 7, DBMaxIdleConns: 2, DBConnMaxLifetime: time.Hour})
+
+	var conns []*sql.Conn
+	for range 3 {
+		conn, err := db.Conn(t.Context())
+		if err != nil {
+			t.Fatal(err)
+		}
+		conns = append(conns, conn)
+	}
+	if stats := db.Stats(); stats.MaxOpenConnections != 7 || stats.OpenConnections != 3 {
+		t.Fatalf("Stats = %+v", stats)
+	}
+
+	for _, conn := range conns {
+		conn.Close()
+	}
+	if stats := db.Stats(); stats.Idle != 2 || stats.MaxIdleClosed != 1 {
+		t.Errorf("Stats после освобождения = %+v", stats)
+	}
+}
+
+func TestConnectRetryPolicy(t *testing.T) {
+	got := connectRetryPolicy(&config.Config{DBConnectAttempts: 4, DBConnectTimeout: time.Minute})
+	want := retryPolicy{Attempts: 4, Timeout: time.Minute, Delay: connectRetryDelay, MaxDelay: connectRetryMaxDelay}
+	if got != want {
+		t.Errorf("connectRetryPolicy = %+v, want %+v", got, want)
+	}
+}
+
+// Подключение повторяется, пока БД не станет доступна
+func TestPingRetriesUntilAvailable(t *testing.T) {
+	fake := newFakeDB()
+	fake.connectErr = errRefused
+	fake.onConnect = func() {
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		if fake.connects == 2 {
+			fake.connectErr = nil
+		}
+	}
+	db := fake.open()
+	t.Cleanup(func() { db.Close() })
+
+	if err := testRetryPolicy(5).ping(t.Context(), db); err != nil {
+		t.Fatal(err)
+	}
+	if n := fake.attempts(); n != 3 {
+		t.Errorf("попыток %d, want 3", n)
+	}
+}
+
+func TestPingStopsAfterAttempts(t *testing.T) {
+	fake := newFakeDB()
+	fake.connectErr = errRefused
+	db := fake.open()
+	t.Cleanup(func() { db.Close() })
+
+	err := testRetryPolicy(3).ping(t.Context(), db)
+	if !errors.Is(err, errRefused) || !strings.Contains(err.Error(), "после 3 попыток") {
+		t.Errorf("ping = %v", err)
+	}
+	if n := fake.attempts(); n != 3 {
+		t.Errorf("попыток %d, want 3", n)
+	}
+}
+
+// Без ограничения попыток повторы идут до конца Timeout
+func TestPingStopsAfterTimeout(t *testing.T) {
+	fake := newFakeDB()
+	fake.connectErr = errRefused
+	db := fake.open()
+	t.Cleanup(func() { db.Close() })
+
+	policy := testRetryPolicy(0)
+	policy.Timeout = 50 * time.Millisecond
+	start := time.Now()
+	err := policy.ping(t.Context(), db)
+	if err == nil || !strings.Contains(err.Error(), "за 50ms") {
+		t.Errorf("ping = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("ping занял %s", elapsed)
+	}
+	if n := fake.attempts(); n < 2 {
+		t.Errorf("попыток %d, ожидались повторы", n)
+	}
+}
+
+// Отмена контекста (например, остановка сервиса при запуске) прерывает ожидание паузы
+func TestPingStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	fake := newFakeDB()
+	fake.connectErr = errRefused
+	fake.onConnect = cancel
+	db := fake.open()
+	t.Cleanup(func() { db.Close() })
+
+	policy := retryPolicy{Timeout: time.Hour, Delay: time.Hour, MaxDelay: time.Hour}
+	done := make(chan error, 1)
+	go func() { done <- policy.ping(ctx, db) }()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("ping = %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ping не остановился после отмены контекста")
+	}
+	if n := fake.attempts(); n != 1 {
+		t.Errorf("попыток %d, want 1", n)
+	}
+}
+
+// Ответ сервера с ошибкой (неверный пароль) не повторяется, БД в процессе запуска - повторяется
+func TestPingRetryableErrors(t *testing.T) {
+	for _, tc := range []struct {
+		code pq.ErrorCode
+		want int
+	}{
+		{"28P01", 1}, // invalid_password
+		{"3D000", 1}, // invalid_catalog_name
+		{"57P03", 3}, // cannot_connect_now
+		{"53300", 3}, // too_many_connections
+	} {
+		fake := newFakeDB()
+		fake.connectErr = &pq.Error{Code: tc.code}
+		db := fake.open()
+
+		err := testRetryPolicy(3).ping(t.Context(), db)
+		db.Close()
+		var pqErr *pq.Error
+		if !errors.As(err, &pqErr) {
+			t.Errorf("%s: ping = %v", tc.code, err)
+		}
+		if n := fake.attempts(); n != tc.want {
+			t.Errorf("%s: попыток %d, want %d", tc.code, n, tc.want)
+		}
+	}
+}
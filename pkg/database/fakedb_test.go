@@ -0,0 +1,200 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fakeDB - драйвер database/sql в памяти, который понимает запросы мигратора к
+// schema_migrations. Остальные запросы (тексты миграций, advisory lock)
+// записываются в executed; транзакция применяет их только при Commit.
+type fakeDB struct {
+	mu       sync.Mutex
+	applied  map[int]appliedMigration
+	executed []string
+	failOn   string // запрос, содержащий эту строку, завершается ошибкой
+
+	connectErr error  // ошибка каждого нового соединения
+	onConnect  func() // вызывается при каждой попытке соединения
+	connects   int
+}
+
+func newFakeDB() *fakeDB {
+	return &fakeDB{applied: map[int]appliedMigration{}}
+}
+
+// open - пул над fakeDB; закрывается вместе с тестом вызывающим
+func (f *fakeDB) open() *sql.DB {
+	return sql.OpenDB(f)
+}
+
+// log - выполненные вне schema_migrations запросы
+func (f *fakeDB) log() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.executed)
+}
+
+func (f *fakeDB) resetLog() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.executed = nil
+}
+
+// attempts - сколько раз пул открывал соединение
+func (f *fakeDB) attempts() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.connects
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) {
+	f.mu.Lock()
+	f.connects++
+	onConnect, err := f.onConnect, f.connectErr
+	f.mu.Unlock()
+
+	if onConnect != nil {
+		onConnect()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &fakeConn{db: f}, nil
+}
+
+func (f *fakeDB) Driver() driver.Driver { return fakeDriver{} }
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("fakeDriver: используйте sql.OpenDB")
+}
+
+// fakeState - schema_migrations и журнал запросов: общие или копия в транзакции
+type fakeState struct {
+	applied  map[int]appliedMigration
+	executed []string
+}
+
+type fakeConn struct {
+	db *fakeDB
+	tx *fakeState
+}
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("fakeConn: подготовленные запросы не поддерживаются")
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *fakeConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.tx = &fakeState{applied: maps.Clone(c.db.applied), executed: slices.Clone(c.db.executed)}
+	return c, nil
+}
+
+func (c *fakeConn) Commit() error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.db.applied, c.db.executed = c.tx.applied, c.tx.executed
+	c.tx = nil
+	return nil
+}
+
+func (c *fakeConn) Rollback() error {
+	c.tx = nil
+	return nil
+}
+
+// state - состояние, которое видит соединение; вызывается под c.db.mu
+func (c *fakeConn) state() *fakeState {
+	if c.tx != nil {
+		return c.tx
+	}
+	return &fakeState{applied: c.db.applied, executed: c.db.executed}
+}
+
+func (c *fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+
+	query = strings.TrimSpace(query)
+	if c.db.failOn != "" && strings.Contains(query, c.db.failOn) {
+		return nil, errors.New("fakeDB: ошибка выполнения " + c.db.failOn)
+	}
+
+	st := c.state()
+	switch {
+	case strings.Contains(query, "CREATE TABLE IF NOT EXISTS schema_migrations"):
+	case strings.HasPrefix(query, "INSERT INTO schema_migrations"):
+		st.applied[int(args[0].Value.(int64))] = appliedMigration{
+			Name: args[1].Value.(string), Checksum: args[2].Value.(string), AppliedAt: time.Now(),
+		}
+	case strings.HasPrefix(query, "DELETE FROM schema_migrations"):
+		delete(st.applied, int(args[0].Value.(int64)))
+	default:
+		st.executed = append(st.executed, query)
+	}
+
+	if c.tx == nil {
+		c.db.applied, c.db.executed = st.applied, st.executed
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+
+	st := c.state()
+	versions := slices.Sorted(maps.Keys(st.applied))
+	rows := &fakeRows{}
+	switch {
+	case strings.HasPrefix(query, "SELECT version, name, checksum, applied_at FROM schema_migrations"):
+		rows.columns = []string{"version", "name", "checksum", "applied_at"}
+		for _, v := range versions {
+			r := st.applied[v]
+			rows.values = append(rows.values, []driver.Value{int64(v), r.Name, r.Checksum, r.AppliedAt})
+		}
+	case strings.HasPrefix(query, "SELECT version, checksum FROM schema_migrations"):
+		rows.columns = []string{"version", "checksum"}
+		for _, v := range versions {
+			rows.values = append(rows.values, []driver.Value{int64(v), st.applied[v].Checksum})
+		}
+	default:
+		return nil, errors.New("fakeDB: неизвестный запрос " + query)
+	}
+	return rows, nil
+}
+
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
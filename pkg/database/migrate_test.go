@@ -0,0 +1,205 @@
+package database
+
+import (
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// testMigrations - две миграции с файлами отката и посторонний файл
+func testMigrations() fstest.MapFS {
+	return fstest.MapFS{
+		"001_planets.sql":      {Data: []byte("CREATE TABLE planets ();")},
+		"001_planets.down.sql": {Data: []byte("DROP TABLE planets;")},
+		"002_moons.sql":        {Data: []byte("CREATE TABLE moons ();")},
+		"002_moons.down.sql":   {Data: []byte("DROP TABLE moons;")},
+		"README.md":            {Data: []byte("не миграция")},
+	}
+}
+
+func TestMain(m *testing.M) {
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn + 1})))
+	os.Exit(m.Run())
+}
+
+func newTestMigrator(t *testing.T, db *fakeDB, fsys fstest.MapFS) *Migrator {
+	t.Helper()
+	pool := db.open()
+	t.Cleanup(func() { pool.Close() })
+
+	m, err := NewMigrator(pool, fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// migrationLog - выполненные тексты миграций без advisory lock
+func migrationLog(db *fakeDB) []string {
+	var log []string
+	for _, q := range db.log() {
+		if !strings.Contains(q, "pg_advisory") {
+			log = append(log, q)
+		}
+	}
+	return log
+}
+
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := LoadMigrations(testMigrations())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) != 2 || migrations[0].Version != 1 || migrations[1].Name != "moons" {
+		t.Fatalf("миграции = %+v", migrations)
+	}
+	if migrations[0].Down != "DROP TABLE planets;" || len(migrations[0].Checksum) != 64 {
+		t.Errorf("001 = %+v", migrations[0])
+	}
+
+	for name, fsys := range map[string]fstest.MapFS{
+		"только откат": {"001_planets.down.sql": {Data: []byte("DROP TABLE planets;")}},
+		"разные имена": {
+			"001_planets.sql":    {Data: []byte("CREATE TABLE planets ();")},
+			"001_stars.down.sql": {Data: []byte("DROP TABLE stars;")},
+		},
+	} {
+		if _, err := LoadMigrations(fsys); err == nil {
+			t.Errorf("%s: без ошибки", name)
+		}
+	}
+}
+
+// Примененные миграции не выполняются повторно; новые применяются по порядку под блокировкой
+func TestMigratorUpSkipsApplied(t *testing.T) {
+	db := newFakeDB()
+	fsys := testMigrations()
+
+	n, err := newTestMigrator(t, db, fsys).Up(t.Context())
+	if err != nil || n != 2 {
+		t.Fatalf("Up = %d, %v", n, err)
+	}
+	log := db.log()
+	want := []string{"CREATE TABLE planets ();", "CREATE TABLE moons ();"}
+	if !slices.Equal(migrationLog(db), want) {
+		t.Errorf("выполнено %q, want %q", migrationLog(db), want)
+	}
+	if !strings.Contains(log[0], "pg_advisory_lock") || !strings.Contains(log[len(log)-1], "pg_advisory_unlock") {
+		t.Errorf("миграции выполнены не под advisory lock: %q", log)
+	}
+
+	db.resetLog()
+	if n, err := newTestMigrator(t, db, fsys).Up(t.Context()); err != nil || n != 0 {
+		t.Errorf("повторный Up = %d, %v", n, err)
+	}
+	if log := migrationLog(db); len(log) != 0 {
+		t.Errorf("повторно выполнено %q", log)
+	}
+
+	fsys["003_stars.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE stars ();")}
+	m := newTestMigrator(t, db, fsys)
+	if err := m.Current(t.Context()); err == nil || !strings.Contains(err.Error(), "не применено миграций: 1") {
+		t.Errorf("Current до применения 003 = %v", err)
+	}
+	if n, err := m.Up(t.Context()); err != nil || n != 1 {
+		t.Fatalf("Up новой миграции = %d, %v", n, err)
+	}
+	if log := migrationLog(db); !slices.Equal(log, []string{"CREATE TABLE stars ();"}) {
+		t.Errorf("выполнено %q", log)
+	}
+	if err := m.Current(t.Context()); err != nil {
+		t.Errorf("Current = %v", err)
+	}
+}
+
+// Изменение файла уже примененной миграции останавливает up и down и видно в status и readyz
+func TestMigratorChecksumDrift(t *testing.T) {
+	db := newFakeDB()
+	fsys := testMigrations()
+	if _, err := newTestMigrator(t, db, fsys).Up(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	fsys["001_planets.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE planets (id INT);")}
+	fsys["003_stars.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE stars ();")}
+	m := newTestMigrator(t, db, fsys)
+	db.resetLog()
+
+	if _, err := m.Up(t.Context()); err == nil || !strings.Contains(err.Error(), "001_planets изменена") {
+		t.Errorf("Up = %v, ожидалась ошибка контрольной суммы", err)
+	}
+	if _, err := m.Down(t.Context(), 1); err == nil {
+		t.Error("Down без ошибки")
+	}
+	if log := migrationLog(db); len(log) != 0 {
+		t.Errorf("при измененной миграции выполнено %q", log)
+	}
+	if err := m.Current(t.Context()); err == nil || !strings.Contains(err.Error(), "изменена") {
+		t.Errorf("Current = %v", err)
+	}
+
+	statuses, err := m.Status(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !statuses[0].Modified || statuses[1].Modified || statuses[2].AppliedAt != nil {
+		t.Errorf("Status = %+v", statuses)
+	}
+}
+
+// Откат идет от последней примененной миграции и останавливается на миграции без файла отката
+func TestMigratorDown(t *testing.T) {
+	db := newFakeDB()
+	fsys := testMigrations()
+	fsys["003_stars.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE stars ();")}
+	m := newTestMigrator(t, db, fsys)
+	if _, err := m.Up(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	db.resetLog()
+	if n, err := m.Down(t.Context(), 1); err == nil || n != 0 || !strings.Contains(err.Error(), "не поддерживает откат") {
+		t.Errorf("Down миграции без отката = %d, %v", n, err)
+	}
+	if log := migrationLog(db); len(log) != 0 {
+		t.Errorf("выполнено %q", log)
+	}
+
+	fsys["003_stars.down.sql"] = &fstest.MapFile{Data: []byte("DROP TABLE stars;")}
+	m = newTestMigrator(t, db, fsys)
+	if n, err := m.Down(t.Context(), 2); err != nil || n != 2 {
+		t.Fatalf("Down = %d, %v", n, err)
+	}
+	if log := migrationLog(db); !slices.Equal(log, []string{"DROP TABLE stars;", "DROP TABLE moons;"}) {
+		t.Errorf("выполнено %q", log)
+	}
+	statuses, err := m.Status(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statuses[0].AppliedAt == nil || statuses[1].AppliedAt != nil || statuses[2].AppliedAt != nil {
+		t.Errorf("Status после отката = %+v", statuses)
+	}
+}
+
+// Ошибка миграции откатывает ее транзакцию: запись о ней не появляется, предыдущие остаются
+func TestMigratorFailedMigration(t *testing.T) {
+	db := newFakeDB()
+	db.failOn = "moons"
+	m := newTestMigrator(t, db, testMigrations())
+
+	n, err := m.Up(t.Context())
+	if err == nil || n != 1 || !strings.Contains(err.Error(), "002_moons") {
+		t.Fatalf("Up = %d, %v", n, err)
+	}
+	statuses, err := m.Status(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statuses[0].AppliedAt == nil || statuses[1].AppliedAt != nil {
+		t.Errorf("Status = %+v", statuses)
+	}
+}
@@ -0,0 +1,172 @@
+// Интерактивная документация: читает /api/openapi.json и позволяет отправлять запросы
+(function () {
+    "use strict";
+
+    var TOKEN_KEY = "cosmos_api_token";
+    var tokenInput = document.getElementById("docs-token-input");
+    var container = document.getElementById("docs-operations");
+
+    tokenInput.value = localStorage.getItem(TOKEN_KEY) || "";
+    tokenInput.addEventListener("change", function () {
+        localStorage.setItem(TOKEN_KEY, tokenInput.value.trim());
+    });
+
+    function el(tag, attrs, children) {
+        var node = document.createElement(tag);
+        Object.keys(attrs || {}).forEach(function (key) {
+            if (key === "text") {
+                node.textContent = attrs[key];
+            } else {
+                node.setAttribute(key, attrs[key]);
+            }
+        });
+        (children || []).forEach(function (child) {
+            node.appendChild(child);
+        });
+        return node;
+    }
+
+    function resolve(spec, schema) {
+        if (schema && schema.$ref) {
+            return spec.components.schemas[schema.$ref.split("/").pop()];
+        }
+        return schema;
+    }
+
+    // Пример значения по схеме для заполнения тела запроса
+    function example(spec, schema, depth) {
+        schema = resolve(spec, schema) || {};
+        if (depth > 4) {
+            return null;
+        }
+        switch (schema.type) {
+            case "object":
+                var obj = {};
+                Object.keys(schema.properties || {}).forEach(function (name) {
+                    if (name === "id" || name === "created_at" || name === "updated_at") {
+                        return;
+                    }
+                    obj[name] = example(spec, schema.properties[name], depth + 1);
+                });
+                return obj;
+            case "array":
+                return [example(spec, schema.items, depth + 1)];
+            case "integer":
+            case "number":
+                return schema.nullable ? null : 0;
+            case "boolean":
+                return false;
+            case "string":
+                return schema.format === "date-time" ? new Date().toISOString() : "";
+        }
+        return null;
+    }
+
+    function renderOperation(spec, path, method, op) {
+        var params = op.parameters || [];
+        var inputs = {};
+        var body = null;
+        var result = el("div", { "class": "docs-result", hidden: "hidden" });
+
+        var children = [];
+        params.forEach(function (param) {
+            var input = el("input", { type: "text", placeholder: param.name });
+            inputs[param.name] = input;
+            children.push(el("label", { text: param.name + " (" + param.in + ")" }));
+            children.push(input);
+        });
+
+        if (op.requestBody) {
+            var schema = op.requestBody.content["application/json"].schema;
+            body = el("textarea", {});
+            body.value = JSON.stringify(example(spec, schema, 0), null, 2);
+            children.push(el("label", { text: "Тело запроса (JSON)" }));
+            children.push(body);
+        }
+
+        var codes = Object.keys(op.responses).map(function (code) {
+            return code + " " + op.responses[code].description;
+        });
+        children.push(el("div", { "class": "docs-responses", text: "Ответы: " + codes.join(", ") }));
+
+        var button = el("button", { type: "button", "class": "btn btn-primary", text: "Отправить" });
+        children.push(button);
+        children.push(result);
+
+        button.addEventListener("click", function () {
+            var url = path.replace(/\{([^}]+)\}/g, function (_, name) {
+                return encodeURIComponent(inputs[name].value.trim());
+            });
+            var headers = { "Accept": "application/json" };
+            var token = tokenInput.value.trim();
+            if (token.indexOf("cosmos_") === 0) {
+                headers["X-API-Key"] = token;
+            } else if (token) {
+                headers["Authorization"] = "Bearer " + token;
+            }
+            var options = { method: method.toUpperCase(), headers: headers, credentials: "omit" };
+            if (body) {
+                headers["Content-Type"] = "application/json";
+                options.body = body.value;
+            }
+
+            result.hidden = false;
+            result.textContent = "Отправка...";
+            fetch(url, options).then(function (response) {
+                return response.text().then(function (text) {
+                    var pretty = text;
+                    try {
+                        pretty = JSON.stringify(JSON.parse(text), null, 2);
+                    } catch (e) {
+                        // не JSON - показываем как есть
+                    }
+                    result.textContent = response.status + " " + response.statusText + "\n\n" + pretty;
+                });
+            }).catch(function (err) {
+                result.textContent = "Ошибка сети: " + err;
+            });
+        });
+
+        var summary = el("summary", {}, [
+            el("span", { "class": "docs-method docs-method-" + method, text: method.toUpperCase() }),
+            el("span", { "class": "docs-path", text: path }),
+            el("span", { text: op.summary || "" })
+        ]);
+        if (op.security) {
+            summary.appendChild(el("span", { "class": "docs-lock", title: "Требуется токен", text: "🔒" }));
+        }
+
+        return el("details", { "class": "docs-op" }, [summary, el("div", { "class": "docs-body" }, children)]);
+    }
+
+    function render(spec) {
+        document.getElementById("docs-title").textContent = "📖 " + spec.info.title + " " + spec.info.version;
+        document.getElementById("docs-description").textContent = spec.info.description || "";
+
+        var byTag = {};
+        Object.keys(spec.paths).sort().forEach(function (path) {
+            Object.keys(spec.paths[path]).forEach(function (method) {
+                var op = spec.paths[path][method];
+                var tag = (op.tags && op.tags[0]) || "default";
+                (byTag[tag] = byTag[tag] || []).push(renderOperation(spec, path, method, op));
+            });
+        });
+
+        container.textContent = "";
+        Object.keys(byTag).forEach(function (tag) {
+            container.appendChild(el("h2", { "class": "docs-tag", text: tag }));
+            byTag[tag].forEach(function (node) {
+                container.appendChild(node);
+            });
+        });
+    }
+
+    fetch("/api/openapi.json")
+        .then(function (response) {
+            return response.json();
+        })
+        .then(render)
+        .catch(function (err) {
+            container.textContent = "Не удалось загрузить спецификацию: " + err;
+        });
+})();
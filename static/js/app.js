@@ -0,0 +1,20 @@
+// Общие скрипты сайта. Обработчики вешаются здесь, а не атрибутами onclick/onsubmit,
+// которые Content-Security-Policy запрещает.
+(function () {
+    "use strict";
+
+    // Подтверждение действия: <form data-confirm="Текст вопроса"> и <a data-confirm="...">
+    document.addEventListener("submit", function (event) {
+        var form = event.target;
+        if (form.dataset && form.dataset.confirm && !window.confirm(form.dataset.confirm)) {
+            event.preventDefault();
+        }
+    });
+
+    document.addEventListener("click", function (event) {
+        var link = event.target.closest ? event.target.closest("a[data-confirm]") : null;
+        if (link && !window.confirm(link.dataset.confirm)) {
+            event.preventDefault();
+        }
+    });
+})();